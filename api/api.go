@@ -52,7 +52,10 @@ type spotifyAPI struct {
 	Auth    SpotifyAPIAuth
 	Client  http.Client
 	Metrics MetricHandler
-	opts    APIOptions
+	// Archiver is handed every successful GET response body when set
+	Archiver ResponseArchiver
+	source   ResponseSource
+	opts     APIOptions
 }
 
 type SpotifyAPIAuth struct {
@@ -82,6 +85,25 @@ type MetricHandler interface {
 	AddApiRequestIndex(method string, url string, reqBody string, timeTakenMs int64, bodySize int) error
 }
 
+// ResponseArchiver keeps raw response bodies so a run can be replayed later
+type ResponseArchiver interface {
+	Archive(method string, url string, body []byte) error
+}
+
+// ResponseSource answers requests in place of the network, eg. from archived responses
+type ResponseSource interface {
+	Response(method string, url string) ([]byte, error)
+}
+
+// NewReplayAPI returns an api answering every request from source, nothing is sent over the network so it needs
+// no auth or metrics
+func NewReplayAPI(source ResponseSource, options APIOptions) spotifyAPI {
+	return spotifyAPI{
+		source: source,
+		opts:   options,
+	}
+}
+
 func NewSpotifyAPI(baseURL string, Metrics MetricHandler, auth SpotifyAPIAuth, options APIOptions) spotifyAPI {
 	return spotifyAPI{
 		BaseURL: baseURL,
@@ -110,6 +132,10 @@ func safeCloneReader(body io.Reader) (string, io.Reader, error) {
 }
 
 func (api *spotifyAPI) Request(method string, url string, body io.Reader) ([]byte, error) {
+	if api.source != nil {
+		return api.source.Response(method, url)
+	}
+
 	bodyString, bodyReader, err := safeCloneReader(body)
 	if err != nil {
 		return []byte{}, err
@@ -147,10 +173,18 @@ func (api *spotifyAPI) Request(method string, url string, body io.Reader) ([]byt
 		return []byte{}, err
 	}
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return []byte{}, newBadRespError(resp.StatusCode, string(bytes))
 	}
 
+	// only GETs are archived, POSTs go to the token endpoint and hold credentials
+	if api.Archiver != nil && method == "GET" {
+		err = api.Archiver.Archive(method, url, bytes)
+		if err != nil {
+			return []byte{}, err
+		}
+	}
+
 	return bytes, nil
 }
 
@@ -187,44 +221,338 @@ func (api *spotifyAPI) RecentlyPlayedByUser() (RecentlyPlayedResponse, error) {
 	return recentlyPlayedResp, nil
 }
 
-func (api *spotifyAPI) TopArtistsForUser(period string) (TopArtistsResponse, error) {
+// TopArtistsForUser pages through the users top artists for the period until it has depth of them or spotify runs out
+func (api *spotifyAPI) TopArtistsForUser(period string, depth int) (TopArtistsResponse, error) {
 	data := url.Values{}
 	data.Set("time_range", period)
-	data.Set("limit", "50")
+	data.Set("limit", fmt.Sprint(topPageLimit(depth)))
 
-	url := fmt.Sprintf("https://api.spotify.com/v1/me/top/artists?%s", data.Encode())
-	bytes, err := api.Request("GET", url, nil)
+	topArtists := TopArtistsResponse{}
+	seen := map[string]bool{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/top/artists?%s", data.Encode()), func(bytes []byte) (string, error) {
+		topPlayedResp := TopArtistsResponse{}
+		err := json.Unmarshal(bytes, &topPlayedResp)
+		if err != nil {
+			return "", err
+		}
+
+		topArtists.Items = appendUnseen(topArtists.Items, topPlayedResp.Items, seen, func(artist Artist) string { return artist.ID })
+		topArtists.Href = topPlayedResp.Href
+		if len(topArtists.Items) >= depth {
+			return "", nil
+		}
+		return topPlayedResp.Next, nil
+	})
 	if err != nil {
 		return TopArtistsResponse{}, err
 	}
 
-	topPlayedResp := TopArtistsResponse{}
-	err = json.Unmarshal(bytes, &topPlayedResp)
+	topArtists.Items = truncate(topArtists.Items, depth)
+	topArtists.Limit = float64(len(topArtists.Items))
+	return topArtists, nil
+}
+
+// TopTracksForUser is TopArtistsForUser for tracks
+func (api *spotifyAPI) TopTracksForUser(period string, depth int) (TopTracksResponse, error) {
+	data := url.Values{}
+	data.Set("time_range", period)
+	data.Set("limit", fmt.Sprint(topPageLimit(depth)))
+
+	topTracks := TopTracksResponse{}
+	seen := map[string]bool{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/top/tracks?%s", data.Encode()), func(bytes []byte) (string, error) {
+		topPlayedResp := TopTracksResponse{}
+		err := json.Unmarshal(bytes, &topPlayedResp)
+		if err != nil {
+			return "", err
+		}
+
+		topTracks.Items = appendUnseen(topTracks.Items, topPlayedResp.Items, seen, func(song Song) string { return song.ID })
+		topTracks.Href = topPlayedResp.Href
+		if len(topTracks.Items) >= depth {
+			return "", nil
+		}
+		return topPlayedResp.Next, nil
+	})
 	if err != nil {
-		return TopArtistsResponse{}, err
+		return TopTracksResponse{}, err
 	}
 
-	return topPlayedResp, nil
+	topTracks.Items = truncate(topTracks.Items, depth)
+	topTracks.Limit = float64(len(topTracks.Items))
+	return topTracks, nil
+}
+
+// topPageLimit is the page size to request for a top list depth, spotify allows at most 50 per page
+func topPageLimit(depth int) int {
+	if depth > 50 {
+		return 50
+	}
+	return depth
 }
 
-func (api *spotifyAPI) TopTracksForUser(period string) (TopTracksResponse, error) {
+// appendUnseen adds the page items not already in items, spotify can repeat the item at a page boundary when the
+// ranking shifts between requests, which would otherwise skew every rank after it
+func appendUnseen[T any](items []T, page []T, seen map[string]bool, id func(T) string) []T {
+	for _, item := range page {
+		if seen[id(item)] {
+			continue
+		}
+		seen[id(item)] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+func truncate[T any](items []T, length int) []T {
+	if len(items) > length {
+		return items[:length]
+	}
+	return items
+}
+
+// paginate follows the next url of a paged endpoint until spotify stops returning one,
+// handing each page body to the page func which returns the next url
+func (api *spotifyAPI) paginate(url string, page func(bytes []byte) (string, error)) error {
+	for url != "" {
+		bytes, err := api.Request("GET", url, nil)
+		if err != nil {
+			return err
+		}
+
+		url, err = page(bytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (api *spotifyAPI) SavedTracks() (SavedTracksResponse, error) {
 	data := url.Values{}
-	data.Set("time_range", period)
 	data.Set("limit", "50")
 
-	url := fmt.Sprintf("https://api.spotify.com/v1/me/top/tracks?%s", data.Encode())
+	savedTracks := SavedTracksResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/tracks?%s", data.Encode()), func(bytes []byte) (string, error) {
+		savedTracksResp := SavedTracksResponse{}
+		err := json.Unmarshal(bytes, &savedTracksResp)
+		if err != nil {
+			return "", err
+		}
+
+		savedTracks.Items = append(savedTracks.Items, savedTracksResp.Items...)
+		savedTracks.Total = savedTracksResp.Total
+		return savedTracksResp.Next, nil
+	})
+	if err != nil {
+		return SavedTracksResponse{}, err
+	}
+
+	return savedTracks, nil
+}
+
+func (api *spotifyAPI) SavedAlbums() (SavedAlbumsResponse, error) {
+	data := url.Values{}
+	data.Set("limit", "50")
+
+	savedAlbums := SavedAlbumsResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/albums?%s", data.Encode()), func(bytes []byte) (string, error) {
+		savedAlbumsResp := SavedAlbumsResponse{}
+		err := json.Unmarshal(bytes, &savedAlbumsResp)
+		if err != nil {
+			return "", err
+		}
+
+		savedAlbums.Items = append(savedAlbums.Items, savedAlbumsResp.Items...)
+		savedAlbums.Total = savedAlbumsResp.Total
+		return savedAlbumsResp.Next, nil
+	})
+	if err != nil {
+		return SavedAlbumsResponse{}, err
+	}
+
+	return savedAlbums, nil
+}
+
+func (api *spotifyAPI) FollowedArtists() (FollowedArtistsResponse, error) {
+	data := url.Values{}
+	data.Set("type", "artist")
+	data.Set("limit", "50")
+
+	followedArtists := FollowedArtistsResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/following?%s", data.Encode()), func(bytes []byte) (string, error) {
+		followedArtistsResp := FollowedArtistsResponse{}
+		err := json.Unmarshal(bytes, &followedArtistsResp)
+		if err != nil {
+			return "", err
+		}
+
+		followedArtists.Artists.Items = append(followedArtists.Artists.Items, followedArtistsResp.Artists.Items...)
+		followedArtists.Artists.Total = followedArtistsResp.Artists.Total
+		return followedArtistsResp.Artists.Next, nil
+	})
+	if err != nil {
+		return FollowedArtistsResponse{}, err
+	}
+
+	return followedArtists, nil
+}
+
+func (api *spotifyAPI) Playlists() (PlaylistsResponse, error) {
+	data := url.Values{}
+	data.Set("limit", "50")
+
+	playlists := PlaylistsResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/playlists?%s", data.Encode()), func(bytes []byte) (string, error) {
+		playlistsResp := PlaylistsResponse{}
+		err := json.Unmarshal(bytes, &playlistsResp)
+		if err != nil {
+			return "", err
+		}
+
+		playlists.Items = append(playlists.Items, playlistsResp.Items...)
+		playlists.Total = playlistsResp.Total
+		return playlistsResp.Next, nil
+	})
+	if err != nil {
+		return PlaylistsResponse{}, err
+	}
+
+	return playlists, nil
+}
+
+func (api *spotifyAPI) PlaylistTracks(playlistID string) (PlaylistTracksResponse, error) {
+	data := url.Values{}
+	data.Set("limit", "100")
+
+	playlistTracks := PlaylistTracksResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?%s", playlistID, data.Encode()), func(bytes []byte) (string, error) {
+		playlistTracksResp := PlaylistTracksResponse{}
+		err := json.Unmarshal(bytes, &playlistTracksResp)
+		if err != nil {
+			return "", err
+		}
+
+		playlistTracks.Items = append(playlistTracks.Items, playlistTracksResp.Items...)
+		playlistTracks.Total = playlistTracksResp.Total
+		return playlistTracksResp.Next, nil
+	})
+	if err != nil {
+		return PlaylistTracksResponse{}, err
+	}
+
+	return playlistTracks, nil
+}
+
+// CurrentlyPlaying returns an empty response with no item when nothing is playing (spotify replies 204)
+func (api *spotifyAPI) CurrentlyPlaying() (CurrentlyPlayingResponse, error) {
+	data := url.Values{}
+	data.Set("additional_types", "episode")
+
+	bytes, err := api.Request("GET", fmt.Sprintf("https://api.spotify.com/v1/me/player/currently-playing?%s", data.Encode()), nil)
+	if err != nil {
+		return CurrentlyPlayingResponse{}, err
+	}
+
+	if len(bytes) == 0 {
+		return CurrentlyPlayingResponse{}, nil
+	}
+
+	return parseCurrentlyPlaying(bytes)
+}
+
+// parseCurrentlyPlaying decodes the item as an episode rather than a song when an episode is playing
+func parseCurrentlyPlaying(bytes []byte) (CurrentlyPlayingResponse, error) {
+	currentlyPlayingResp := CurrentlyPlayingResponse{}
+	err := json.Unmarshal(bytes, &currentlyPlayingResp)
+	if err != nil {
+		return CurrentlyPlayingResponse{}, err
+	}
+
+	if currentlyPlayingResp.CurrentlyPlayingType != "episode" {
+		return currentlyPlayingResp, nil
+	}
+
+	episodeResp := struct {
+		Item *Episode `json:"item"`
+	}{}
+	err = json.Unmarshal(bytes, &episodeResp)
+	if err != nil {
+		return CurrentlyPlayingResponse{}, err
+	}
+
+	currentlyPlayingResp.Item = nil
+	currentlyPlayingResp.Episode = episodeResp.Item
+	return currentlyPlayingResp, nil
+}
+
+func (api *spotifyAPI) SavedShows() (SavedShowsResponse, error) {
+	data := url.Values{}
+	data.Set("limit", "50")
+
+	savedShows := SavedShowsResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/shows?%s", data.Encode()), func(bytes []byte) (string, error) {
+		savedShowsResp := SavedShowsResponse{}
+		err := json.Unmarshal(bytes, &savedShowsResp)
+		if err != nil {
+			return "", err
+		}
+
+		savedShows.Items = append(savedShows.Items, savedShowsResp.Items...)
+		savedShows.Total = savedShowsResp.Total
+		return savedShowsResp.Next, nil
+	})
+	if err != nil {
+		return SavedShowsResponse{}, err
+	}
+
+	return savedShows, nil
+}
+
+func (api *spotifyAPI) SavedEpisodes() (SavedEpisodesResponse, error) {
+	data := url.Values{}
+	data.Set("limit", "50")
+
+	savedEpisodes := SavedEpisodesResponse{}
+	err := api.paginate(fmt.Sprintf("https://api.spotify.com/v1/me/episodes?%s", data.Encode()), func(bytes []byte) (string, error) {
+		savedEpisodesResp := SavedEpisodesResponse{}
+		err := json.Unmarshal(bytes, &savedEpisodesResp)
+		if err != nil {
+			return "", err
+		}
+
+		savedEpisodes.Items = append(savedEpisodes.Items, savedEpisodesResp.Items...)
+		savedEpisodes.Total = savedEpisodesResp.Total
+		return savedEpisodesResp.Next, nil
+	})
+	if err != nil {
+		return SavedEpisodesResponse{}, err
+	}
+
+	return savedEpisodes, nil
+}
+
+// SearchTracks searches the catalog for tracks by name and artist, returning the top few results
+func (api *spotifyAPI) SearchTracks(track string, artist string) ([]Song, error) {
+	data := url.Values{}
+	data.Set("q", fmt.Sprintf("track:%s artist:%s", track, artist))
+	data.Set("type", "track")
+	data.Set("limit", "5")
+
+	url := fmt.Sprintf("https://api.spotify.com/v1/search?%s", data.Encode())
 	bytes, err := api.Request("GET", url, nil)
 	if err != nil {
-		return TopTracksResponse{}, err
+		return nil, err
 	}
 
-	topPlayedResp := TopTracksResponse{}
-	err = json.Unmarshal(bytes, &topPlayedResp)
+	searchResp := SearchResponse{}
+	err = json.Unmarshal(bytes, &searchResp)
 	if err != nil {
-		return TopTracksResponse{}, err
+		return nil, err
 	}
 
-	return topPlayedResp, nil
+	return searchResp.Tracks.Items, nil
 }
 
 func (api *spotifyAPI) ArtistsBySpotifyID(ids []string) ([]Artist, error) {
@@ -310,6 +638,56 @@ func (api *spotifyAPI) albumsBySpotifyID(ids []string) (AlbumResponse, error) {
 	return albumResp, nil
 }
 
+func (api *spotifyAPI) ShowsBySpotifyID(ids []string) ([]Show, error) {
+	chunkedIDs := utils.ChunkSlice(ids, 50)
+	showList := []Show{}
+
+	for _, chunk := range chunkedIDs {
+		data := url.Values{}
+		data.Set("ids", strings.Join(chunk, ","))
+		url := fmt.Sprintf("https://api.spotify.com/v1/shows?%s", data.Encode())
+		bytes, err := api.Request("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		showsResp := ShowsResponse{}
+		err = json.Unmarshal(bytes, &showsResp)
+		if err != nil {
+			return nil, err
+		}
+
+		showList = append(showList, showsResp.Shows...)
+	}
+
+	return showList, nil
+}
+
+func (api *spotifyAPI) EpisodesBySpotifyID(ids []string) ([]Episode, error) {
+	chunkedIDs := utils.ChunkSlice(ids, 50)
+	episodeList := []Episode{}
+
+	for _, chunk := range chunkedIDs {
+		data := url.Values{}
+		data.Set("ids", strings.Join(chunk, ","))
+		url := fmt.Sprintf("https://api.spotify.com/v1/episodes?%s", data.Encode())
+		bytes, err := api.Request("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		episodesResp := EpisodesResponse{}
+		err = json.Unmarshal(bytes, &episodesResp)
+		if err != nil {
+			return nil, err
+		}
+
+		episodeList = append(episodeList, episodesResp.Episodes...)
+	}
+
+	return episodeList, nil
+}
+
 func (api *spotifyAPI) Authorize(code string) error {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
@@ -134,6 +134,218 @@ type TopTracksResponse struct {
 	Next     string      `json:"next"`
 }
 
+type SavedTracksResponse struct {
+	Items []SavedTrack `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type SavedTrack struct {
+	AddedAt time.Time `json:"added_at"`
+	Track   Song      `json:"track"`
+}
+
+type SavedAlbumsResponse struct {
+	Items []SavedAlbum `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type SavedAlbum struct {
+	AddedAt time.Time `json:"added_at"`
+	Album   Album     `json:"album"`
+}
+
+type FollowedArtistsResponse struct {
+	Artists struct {
+		Items   []Artist `json:"items"`
+		Next    string   `json:"next"`
+		Total   float64  `json:"total"`
+		Cursors struct {
+			After  string `json:"after"`
+			Before string `json:"before"`
+		} `json:"cursors"`
+		Limit float64 `json:"limit"`
+		Href  string  `json:"href"`
+	} `json:"artists"`
+}
+
+type PlaylistsResponse struct {
+	Items []Playlist `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type Playlist struct {
+	Collaborative bool   `json:"collaborative"`
+	Description   string `json:"description"`
+	ExternalUrls  struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Href   string  `json:"href"`
+	ID     string  `json:"id"`
+	Images []Image `json:"images"`
+	Name   string  `json:"name"`
+	Owner  struct {
+		DisplayName string `json:"display_name"`
+		ID          string `json:"id"`
+		Type        string `json:"type"`
+		URI         string `json:"uri"`
+	} `json:"owner"`
+	Public     bool   `json:"public"`
+	SnapshotID string `json:"snapshot_id"`
+	Tracks     struct {
+		Href  string  `json:"href"`
+		Total float64 `json:"total"`
+	} `json:"tracks"`
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+}
+
+type PlaylistTracksResponse struct {
+	Items []PlaylistTrack `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type PlaylistTrack struct {
+	AddedAt time.Time `json:"added_at"`
+	AddedBy struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"added_by"`
+	IsLocal bool `json:"is_local"`
+	Track   Song `json:"track"`
+}
+
+type CurrentlyPlayingResponse struct {
+	Timestamp            float64 `json:"timestamp"`
+	ProgressMs           float64 `json:"progress_ms"`
+	IsPlaying            bool    `json:"is_playing"`
+	CurrentlyPlayingType string  `json:"currently_playing_type"`
+	Item                 *Song   `json:"item"`
+	// Episode is set instead of Item when an episode is playing
+	Episode *Episode `json:"-"`
+	Context struct {
+		ExternalUrls struct {
+			Spotify string `json:"spotify"`
+		} `json:"external_urls"`
+		Href string `json:"href"`
+		Type string `json:"type"`
+		URI  string `json:"uri"`
+	} `json:"context"`
+}
+
+type SavedShowsResponse struct {
+	Items []SavedShow `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type SavedShow struct {
+	AddedAt time.Time `json:"added_at"`
+	Show    Show      `json:"show"`
+}
+
+type SavedEpisodesResponse struct {
+	Items []SavedEpisode `json:"items"`
+
+	Limit    float64     `json:"limit"`
+	Offset   float64     `json:"offset"`
+	Total    float64     `json:"total"`
+	Href     string      `json:"href"`
+	Previous interface{} `json:"previous"`
+	Next     string      `json:"next"`
+}
+
+type SavedEpisode struct {
+	AddedAt time.Time `json:"added_at"`
+	Episode Episode   `json:"episode"`
+}
+
+type Show struct {
+	AvailableMarkets []string `json:"available_markets"`
+	Description      string   `json:"description"`
+	Explicit         bool     `json:"explicit"`
+	ExternalUrls     struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Href               string   `json:"href"`
+	ID                 string   `json:"id"`
+	Images             []Image  `json:"images"`
+	IsExternallyHosted bool     `json:"is_externally_hosted"`
+	Languages          []string `json:"languages"`
+	MediaType          string   `json:"media_type"`
+	Name               string   `json:"name"`
+	Publisher          string   `json:"publisher"`
+	TotalEpisodes      float64  `json:"total_episodes"`
+	Type               string   `json:"type"`
+	URI                string   `json:"uri"`
+}
+
+type Episode struct {
+	AudioPreviewURL string  `json:"audio_preview_url"`
+	Description     string  `json:"description"`
+	DurationMs      float64 `json:"duration_ms"`
+	Explicit        bool    `json:"explicit"`
+	ExternalUrls    struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Href                 string  `json:"href"`
+	ID                   string  `json:"id"`
+	Images               []Image `json:"images"`
+	IsPlayable           bool    `json:"is_playable"`
+	Language             string  `json:"language"`
+	Name                 string  `json:"name"`
+	ReleaseDate          string  `json:"release_date"`
+	ReleaseDatePrecision string  `json:"release_date_precision"`
+	ResumePoint          struct {
+		FullyPlayed      bool    `json:"fully_played"`
+		ResumePositionMs float64 `json:"resume_position_ms"`
+	} `json:"resume_point"`
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+	Show Show   `json:"show"`
+}
+
+type SearchResponse struct {
+	Tracks struct {
+		Items []Song `json:"items"`
+
+		Limit    float64     `json:"limit"`
+		Offset   float64     `json:"offset"`
+		Total    float64     `json:"total"`
+		Href     string      `json:"href"`
+		Next     string      `json:"next"`
+		Previous interface{} `json:"previous"`
+	} `json:"tracks"`
+}
+
 type TracksResponse struct {
 	Tracks []Song `json:"tracks"`
 }
@@ -142,6 +354,14 @@ type ArtistsResponse struct {
 	Artists []Artist `json:"artists"`
 }
 
+type ShowsResponse struct {
+	Shows []Show `json:"shows"`
+}
+
+type EpisodesResponse struct {
+	Episodes []Episode `json:"episodes"`
+}
+
 type Image struct {
 	Height float64 `json:"height"`
 	URL    string  `json:"url"`
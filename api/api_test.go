@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAppendUnseen(t *testing.T) {
+	id := func(song Song) string { return song.ID }
+	songs := func(ids ...string) []Song {
+		items := []Song{}
+		for _, id := range ids {
+			items = append(items, Song{ID: id})
+		}
+		return items
+	}
+
+	seen := map[string]bool{}
+	items := appendUnseen(nil, songs("a", "b", "c"), seen, id)
+	// the ranking shifted between pages so c is repeated as the first item of the second page
+	items = appendUnseen(items, songs("c", "d", "e"), seen, id)
+	items = truncate(items, 4)
+
+	actual := []string{}
+	for _, item := range items {
+		actual = append(actual, item.ID)
+	}
+	if fmt.Sprint(actual) != "[a b c d]" {
+		t.Errorf("Expected continuous unique ranks a b c d, got %v", actual)
+	}
+}
+
+func TestTopPageLimit(t *testing.T) {
+	for depth, expected := range map[int]int{10: 10, 50: 50, 120: 50} {
+		if limit := topPageLimit(depth); limit != expected {
+			t.Errorf("Expected a page limit of %d for depth %d, got %d", expected, depth, limit)
+		}
+	}
+}
@@ -50,7 +50,7 @@ func (mockAPI *MockSpotifyAPI) RecentlyPlayedByUser() (RecentlyPlayedResponse, e
 	return recentlyPlayedResponse, nil
 }
 
-func (mockAPI *MockSpotifyAPI) TopArtistsForUser(period string) (TopArtistsResponse, error) {
+func (mockAPI *MockSpotifyAPI) TopArtistsForUser(period string, depth int) (TopArtistsResponse, error) {
 	data := mockAPI.loader(fmt.Sprintf("get-top-artists-%s", period))
 
 	topArtistsResponse := TopArtistsResponse{}
@@ -59,10 +59,11 @@ func (mockAPI *MockSpotifyAPI) TopArtistsForUser(period string) (TopArtistsRespo
 		return TopArtistsResponse{}, err
 	}
 
+	topArtistsResponse.Items = truncate(topArtistsResponse.Items, depth)
 	return topArtistsResponse, nil
 }
 
-func (mockAPI *MockSpotifyAPI) TopTracksForUser(period string) (TopTracksResponse, error) {
+func (mockAPI *MockSpotifyAPI) TopTracksForUser(period string, depth int) (TopTracksResponse, error) {
 	data := mockAPI.loader(fmt.Sprintf("get-top-tracks-%s", period))
 
 	topTracksResponse := TopTracksResponse{}
@@ -71,9 +72,112 @@ func (mockAPI *MockSpotifyAPI) TopTracksForUser(period string) (TopTracksRespons
 		return TopTracksResponse{}, err
 	}
 
+	topTracksResponse.Items = truncate(topTracksResponse.Items, depth)
 	return topTracksResponse, nil
 }
 
+func (mockAPI *MockSpotifyAPI) SavedTracks() (SavedTracksResponse, error) {
+	data := mockAPI.loader("get-saved-tracks")
+
+	savedTracksResponse := SavedTracksResponse{}
+	err := json.Unmarshal(data, &savedTracksResponse)
+	if err != nil {
+		return SavedTracksResponse{}, err
+	}
+
+	return savedTracksResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) SavedAlbums() (SavedAlbumsResponse, error) {
+	data := mockAPI.loader("get-saved-albums")
+
+	savedAlbumsResponse := SavedAlbumsResponse{}
+	err := json.Unmarshal(data, &savedAlbumsResponse)
+	if err != nil {
+		return SavedAlbumsResponse{}, err
+	}
+
+	return savedAlbumsResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) FollowedArtists() (FollowedArtistsResponse, error) {
+	data := mockAPI.loader("get-followed-artists")
+
+	followedArtistsResponse := FollowedArtistsResponse{}
+	err := json.Unmarshal(data, &followedArtistsResponse)
+	if err != nil {
+		return FollowedArtistsResponse{}, err
+	}
+
+	return followedArtistsResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) Playlists() (PlaylistsResponse, error) {
+	data := mockAPI.loader("get-playlists")
+
+	playlistsResponse := PlaylistsResponse{}
+	err := json.Unmarshal(data, &playlistsResponse)
+	if err != nil {
+		return PlaylistsResponse{}, err
+	}
+
+	return playlistsResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) PlaylistTracks(playlistID string) (PlaylistTracksResponse, error) {
+	data := mockAPI.loader(fmt.Sprintf("get-playlist-tracks-%s", playlistID))
+
+	playlistTracksResponse := PlaylistTracksResponse{}
+	err := json.Unmarshal(data, &playlistTracksResponse)
+	if err != nil {
+		return PlaylistTracksResponse{}, err
+	}
+
+	return playlistTracksResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) CurrentlyPlaying() (CurrentlyPlayingResponse, error) {
+	data := mockAPI.loader("get-currently-playing")
+
+	return parseCurrentlyPlaying(data)
+}
+
+func (mockAPI *MockSpotifyAPI) SavedShows() (SavedShowsResponse, error) {
+	data := mockAPI.loader("get-saved-shows")
+
+	savedShowsResponse := SavedShowsResponse{}
+	err := json.Unmarshal(data, &savedShowsResponse)
+	if err != nil {
+		return SavedShowsResponse{}, err
+	}
+
+	return savedShowsResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) SavedEpisodes() (SavedEpisodesResponse, error) {
+	data := mockAPI.loader("get-saved-episodes")
+
+	savedEpisodesResponse := SavedEpisodesResponse{}
+	err := json.Unmarshal(data, &savedEpisodesResponse)
+	if err != nil {
+		return SavedEpisodesResponse{}, err
+	}
+
+	return savedEpisodesResponse, nil
+}
+
+func (mockAPI *MockSpotifyAPI) SearchTracks(track string, artist string) ([]Song, error) {
+	data := mockAPI.loader("get-search")
+
+	searchResponse := SearchResponse{}
+	err := json.Unmarshal(data, &searchResponse)
+	if err != nil {
+		return []Song{}, err
+	}
+
+	return searchResponse.Tracks.Items, nil
+}
+
 func (mockAPI *MockSpotifyAPI) TracksBySpotifyID(ids []string) ([]Song, error) {
 	data := mockAPI.loader("get-tracks")
 
@@ -110,6 +214,30 @@ func (mockAPI *MockSpotifyAPI) AlbumsBySpotifyID(ids []string) ([]Album, error)
 	return albumResponse.Albums, nil
 }
 
+func (mockAPI *MockSpotifyAPI) ShowsBySpotifyID(ids []string) ([]Show, error) {
+	data := mockAPI.loader("get-shows")
+
+	showsResponse := ShowsResponse{}
+	err := json.Unmarshal(data, &showsResponse)
+	if err != nil {
+		return []Show{}, err
+	}
+
+	return showsResponse.Shows, nil
+}
+
+func (mockAPI *MockSpotifyAPI) EpisodesBySpotifyID(ids []string) ([]Episode, error) {
+	data := mockAPI.loader("get-episodes")
+
+	episodesResponse := EpisodesResponse{}
+	err := json.Unmarshal(data, &episodesResponse)
+	if err != nil {
+		return []Episode{}, err
+	}
+
+	return episodesResponse.Episodes, nil
+}
+
 func (mockAPI *MockSpotifyAPI) Authorize(code string) error {
 	return nil
 }
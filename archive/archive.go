@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"spotify/blob"
+	"spotify/models"
+	"spotify/utils"
+	"sync"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+type ArchiveDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	Commit()
+}
+
+// Archiver stores every response body of a run gzipped in a blob store, keyed by the sha256 of the body so identical
+// responses across runs are only stored once. The raw_responses index is written on Close, in its own transaction so
+// it survives a run that fails and is rolled back
+type Archiver struct {
+	database  ArchiveDatabase
+	store     blob.Store
+	runID     string
+	username  string
+	lock      sync.Mutex
+	responses []models.RawResponse
+}
+
+func NewArchiver(database ArchiveDatabase, store blob.Store, runID string, username string) *Archiver {
+	return &Archiver{
+		database: database,
+		store:    store,
+		runID:    runID,
+		username: username,
+	}
+}
+
+// Key is where a response body is stored
+func Key(body []byte) string {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	return fmt.Sprintf("responses/%s/%s.json.gz", hash[:2], hash)
+}
+
+func (a *Archiver) Archive(method string, url string, body []byte) error {
+	key := Key(body)
+	exists, err := a.store.Exists(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		compressed, err := compress(body)
+		if err != nil {
+			return err
+		}
+
+		err = a.store.Put(key, "application/gzip", compressed)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.responses = append(a.responses, models.NewRawResponse(a.runID, a.username, method, url, len(a.responses), key, len(body)))
+	return nil
+}
+
+// Close writes the index of every response archived and commits it
+func (a *Archiver) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if len(a.responses) == 0 {
+		return nil
+	}
+
+	values := []interface{}{}
+	for _, response := range a.responses {
+		values = append(values, utils.ReflectValues(response)...)
+	}
+
+	for _, chunk := range utils.ChunkSlice(values, 1000*len(utils.ReflectColumns(&models.RawResponse{}))) {
+		err := a.database.Create(&models.RawResponse{}, chunk)
+		if err != nil {
+			return err
+		}
+	}
+	a.database.Commit()
+
+	logger.Log(fmt.Sprintf("Archived %d responses for run %s", len(a.responses), a.runID), logger.Info)
+	a.responses = nil
+	return nil
+}
+
+func compress(body []byte) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"spotify/api"
+	"spotify/blob"
+	"spotify/models"
+	"testing"
+)
+
+type mockArchiveDatabase struct {
+	responses []models.RawResponse
+	commits   int
+}
+
+func (db *mockArchiveDatabase) Create(model models.Model, values []interface{}) error {
+	for i := 0; i < len(values); i += 11 {
+		db.responses = append(db.responses, models.RawResponse{
+			Method:     values[i+3].(string),
+			Endpoint:   values[i+4].(string),
+			Sequence:   values[i+5].(int),
+			StorageKey: values[i+6].(string),
+		})
+	}
+	return nil
+}
+
+func (db *mockArchiveDatabase) Commit() {
+	db.commits++
+}
+
+func TestArchiveAndReplay(t *testing.T) {
+	store := blob.NewFileStore(t.TempDir())
+	db := &mockArchiveDatabase{}
+	archiver := NewArchiver(db, store, "run", "user")
+
+	me := "https://api.spotify.com/v1/me"
+	recents := "https://api.spotify.com/v1/me/player/recently-played?limit=50"
+	for _, response := range []struct {
+		url  string
+		body string
+	}{
+		{me, `{"id": "first"}`},
+		{recents, `{"items": [{"track": {"id": "track"}}]}`},
+		{me, `{"id": "second"}`},
+	} {
+		err := archiver.Archive("GET", response.url, []byte(response.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := archiver.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.responses) != 3 || db.commits != 1 || db.responses[2].Sequence != 2 {
+		t.Fatalf("Expected 3 indexed responses committed once, got %+v with %d commits", db.responses, db.commits)
+	}
+
+	replayAPI := api.NewReplayAPI(NewReplay(store, "run", db.responses), api.NewAPIOptions(0))
+	for _, expected := range []string{"first", "second", "second"} {
+		meResp, err := replayAPI.Me()
+		if err != nil || meResp.ID != expected {
+			t.Errorf("Expected the archived me response %q, got %q %v", expected, meResp.ID, err)
+		}
+	}
+
+	recentsResp, err := replayAPI.RecentlyPlayedByUser()
+	if err != nil || len(recentsResp.Items) != 1 || recentsResp.Items[0].Track.ID != "track" {
+		t.Errorf("Expected the archived recently played response, got %+v %v", recentsResp, err)
+	}
+
+	if _, err := replayAPI.SavedTracks(); err == nil {
+		t.Errorf("Expected a request missing from the archive to fail")
+	}
+}
+
+func TestKey(t *testing.T) {
+	key := Key([]byte("body"))
+	if key != "responses/23/230d8358dc8e8890b4c58deeb62912ee2f20357ae92a5cc861b98e68fe31acb5.json.gz" {
+		t.Errorf("Unexpected key %s", key)
+	}
+	if Key([]byte("body")) != key || Key([]byte("other")) == key {
+		t.Errorf("Expected keys to depend only on the body")
+	}
+}
@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"spotify/blob"
+	"spotify/models"
+	"sync"
+)
+
+// Replay answers requests with the responses archived during a run. An endpoint requested more than once gets its
+// responses back in the order they were archived, then the last one again once they run out
+type Replay struct {
+	store     blob.Store
+	runID     string
+	lock      sync.Mutex
+	responses map[string][]models.RawResponse
+}
+
+// NewReplay expects responses ordered by sequence, as FetchRawResponsesByRunID returns them
+func NewReplay(store blob.Store, runID string, responses []models.RawResponse) *Replay {
+	replay := Replay{
+		store:     store,
+		runID:     runID,
+		responses: map[string][]models.RawResponse{},
+	}
+
+	for _, response := range responses {
+		key := requestKey(response.Method, response.Endpoint)
+		replay.responses[key] = append(replay.responses[key], response)
+	}
+	return &replay
+}
+
+func requestKey(method string, url string) string {
+	return fmt.Sprintf("%s %s", method, url)
+}
+
+func (r *Replay) Response(method string, url string) ([]byte, error) {
+	r.lock.Lock()
+	key := requestKey(method, url)
+	queue := r.responses[key]
+	if len(queue) == 0 {
+		r.lock.Unlock()
+		return nil, fmt.Errorf("no response to %s %s archived in run %s", method, url, r.runID)
+	}
+
+	response := queue[0]
+	if len(queue) > 1 {
+		r.responses[key] = queue[1:]
+	}
+	r.lock.Unlock()
+
+	compressed, err := r.store.Get(response.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
@@ -2,25 +2,94 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"spotify/ingest"
+	"strconv"
+	"strings"
 )
 
 func parseArgs() ingest.SpotifyIngestOptions {
-	recentListen := flag.Bool("r", false, "Parse and ingest user data regarding a users recently listened tracks")
-	topSongs := flag.Bool("t", false, "Parse and ingest user data regarding a users top songs")
-	topArtists := flag.Bool("a", false, "Parse and ingest user data regarding a users top artists")
 	user := flag.String("u", "", "Username to query the spotify API for, must have relevant refresh_token in env")
+	resume := flag.String("resume", "", "Id of a failed run to resume from the stage it failed at, needs checkpoint_dir set")
+	options := ingestFlags(flag.CommandLine)
 	flag.Parse()
 
 	if *user == "" {
 		log.Fatalf("UserID must be specified!")
 	}
 
-	return ingest.SpotifyIngestOptions{
-		RecentListen: *recentListen,
-		TopSongs:     *topSongs,
-		TopArtists:   *topArtists,
-		UserID:       *user,
+	args := options()
+	args.UserID = *user
+	args.RunID = *resume
+	return args
+}
+
+// ingestFlags registers the flags choosing what an ingest run covers, shared by the default ingest and reprocess. The
+// returned func builds the options once the flags are parsed
+func ingestFlags(flags *flag.FlagSet) func() ingest.SpotifyIngestOptions {
+	recentListen := flags.Bool("r", false, "Parse and ingest user data regarding a users recently listened tracks")
+	topSongs := flags.Bool("t", false, "Parse and ingest user data regarding a users top songs")
+	topArtists := flags.Bool("a", false, "Parse and ingest user data regarding a users top artists")
+	savedLibrary := flags.Bool("l", false, "Parse and ingest a users saved library (liked tracks and saved albums)")
+	followedArtists := flags.Bool("f", false, "Parse and ingest the artists a user follows, tracking follows and unfollows")
+	playlists := flags.Bool("p", false, "Parse and ingest snapshots of the playlists a user owns")
+	podcasts := flags.Bool("e", false, "Parse and ingest a users saved shows and episodes, including resume points")
+	refreshAge := flags.Duration("refresh-age", 0, "Re-fetch artists, albums and songs not updated for this long, eg. 720h")
+	refreshBudget := flags.Int("refresh-budget", 100, "Most stale artists, albums and songs to re-fetch per run when -refresh-age is set")
+	policy := flags.String("policy", ingest.PolicyStrict, "What a failed insert step does, strict fails the run while best-effort rolls back only that step and commits the rest")
+	depth := flags.String("depth", "", "How many top songs and artists to fetch, either one number for every term or per term eg. short=50,medium=100,long=200")
+
+	return func() ingest.SpotifyIngestOptions {
+		topDepths, err := parseTopDepths(*depth)
+		if err != nil {
+			log.Fatalf("Invalid -depth: %s", err.Error())
+		}
+
+		if !ingest.ValidPolicy(*policy) {
+			log.Fatalf("Invalid -policy %q, expected %s or %s", *policy, ingest.PolicyStrict, ingest.PolicyBestEffort)
+		}
+
+		return ingest.SpotifyIngestOptions{
+			RecentListen:    *recentListen,
+			TopSongs:        *topSongs,
+			TopArtists:      *topArtists,
+			SavedLibrary:    *savedLibrary,
+			FollowedArtists: *followedArtists,
+			Playlists:       *playlists,
+			Podcasts:        *podcasts,
+			TopDepths:       topDepths,
+
+			CatalogMaxAge:        *refreshAge,
+			CatalogRefreshBudget: *refreshBudget,
+			FailurePolicy:        *policy,
+		}
 	}
 }
+
+// parseTopDepths reads the -depth flag, a bare number applies to every term
+func parseTopDepths(value string) (map[string]int, error) {
+	depths := map[string]int{}
+	if value == "" {
+		return depths, nil
+	}
+
+	if depth, err := strconv.Atoi(value); err == nil {
+		value = fmt.Sprintf("short=%d,medium=%d,long=%d", depth, depth, depth)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		term, count, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || (term != "short" && term != "medium" && term != "long") {
+			return nil, fmt.Errorf("expected term=depth with a term of short, medium or long, got %q", pair)
+		}
+
+		depth, err := strconv.Atoi(count)
+		if err != nil || depth < 1 {
+			return nil, fmt.Errorf("expected a positive depth for the %s term, got %q", term, count)
+		}
+		depths[term] = depth
+	}
+
+	return depths, nil
+}
@@ -0,0 +1,42 @@
+package blob
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Store keeps content addressed blobs, a key is only ever written with the same bytes so Put can be skipped when it Exists
+type Store interface {
+	Exists(key string) (bool, error)
+	Put(key string, contentType string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+// S3Credentials are needed for s3:// stores
+type S3Credentials struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// Open picks the store for a location, file:///some/dir for the local filesystem or s3://bucket/prefix for an
+// s3 compatible object store
+func Open(location string, credentials S3Credentials) (Store, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		return NewFileStore(parsed.Path), nil
+	case "s3":
+		if credentials.Endpoint == "" {
+			return nil, fmt.Errorf("an endpoint is needed for s3 store %s", location)
+		}
+		return NewS3Store(credentials, parsed.Host, parsed.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q, expected file:// or s3://", location)
+	}
+}
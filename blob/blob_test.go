@@ -0,0 +1,109 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// minioStandIn is just enough of an s3 api to store, head and get objects, checking requests are signed
+func minioStandIn(t *testing.T) (*httptest.Server, map[string][]byte) {
+	objects := map[string][]byte{}
+	lock := sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case "HEAD":
+			if _, exists := objects[r.URL.Path]; !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "PUT":
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+		case "GET":
+			body, exists := objects[r.URL.Path]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, objects
+}
+
+func TestStores(t *testing.T) {
+	server, objects := minioStandIn(t)
+
+	s3, err := Open("s3://bucket/mirror/", S3Credentials{Endpoint: server.URL, Region: "eu-west-1", AccessKey: "access", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open("file://"+t.TempDir(), S3Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"s3": s3, "file": file} {
+		t.Run(name, func(t *testing.T) {
+			exists, err := store.Exists("thumbnails/ab/abc.jpg")
+			if err != nil || exists {
+				t.Fatalf("Expected no blob before putting it, got %t %v", exists, err)
+			}
+
+			err = store.Put("thumbnails/ab/abc.jpg", "image/jpeg", []byte("image"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exists, err = store.Exists("thumbnails/ab/abc.jpg")
+			if err != nil || !exists {
+				t.Errorf("Expected the blob to exist after putting it, got %t %v", exists, err)
+			}
+
+			data, err := store.Get("thumbnails/ab/abc.jpg")
+			if err != nil || string(data) != "image" {
+				t.Errorf("Expected to get the blob back, got %q %v", data, err)
+			}
+
+			if _, err := store.Get("thumbnails/ab/missing.jpg"); err == nil {
+				t.Errorf("Expected getting a missing blob to fail")
+			}
+		})
+	}
+
+	if string(objects["/bucket/mirror/thumbnails/ab/abc.jpg"]) != "image" {
+		t.Errorf("Expected a path style object under the prefix, got %v", objects)
+	}
+}
+
+func TestOpenUnknownStore(t *testing.T) {
+	if _, err := Open("ftp://somewhere", S3Credentials{}); err == nil {
+		t.Error("Expected an unknown scheme to fail")
+	}
+	if _, err := Open("s3://bucket", S3Credentials{}); err == nil {
+		t.Error("Expected s3 without an endpoint to fail")
+	}
+}
@@ -0,0 +1,56 @@
+package blob
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type FileStore struct {
+	Root string
+}
+
+func NewFileStore(root string) *FileStore {
+	return &FileStore{Root: root}
+}
+
+func (s *FileStore) Exists(key string) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.Root, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put writes through a temporary file so a crash never leaves a partial blob under its final key
+func (s *FileStore) Put(key string, contentType string, data []byte) error {
+	path := filepath.Join(s.Root, filepath.FromSlash(key))
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.Root, filepath.FromSlash(key)))
+}
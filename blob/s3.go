@@ -0,0 +1,169 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store talks to an s3 compatible api (aws, minio, r2...) with path style requests signed with signature v4
+type S3Store struct {
+	credentials S3Credentials
+	bucket      string
+	prefix      string
+	Client      *http.Client
+	now         func() time.Time
+}
+
+func NewS3Store(credentials S3Credentials, bucket string, prefix string) *S3Store {
+	if credentials.Region == "" {
+		credentials.Region = "us-east-1"
+	}
+	return &S3Store{
+		credentials: credentials,
+		bucket:      bucket,
+		prefix:      strings.Trim(prefix, "/"),
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		now:         time.Now,
+	}
+}
+
+func (s *S3Store) Exists(key string) (bool, error) {
+	resp, err := s.do("HEAD", key, "", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("s3 HEAD %s returned %s", key, resp.Status)
+	}
+}
+
+func (s *S3Store) Put(key string, contentType string, data []byte) error {
+	resp, err := s.do("PUT", key, contentType, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("s3 PUT %s returned %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(key string) ([]byte, error) {
+	resp, err := s.do("GET", key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 GET %s returned %s: %s", key, resp.Status, body)
+	}
+	return body, nil
+}
+
+func (s *S3Store) objectPath(key string) string {
+	parts := []string{s.bucket}
+	if s.prefix != "" {
+		parts = append(parts, s.prefix)
+	}
+	parts = append(parts, key)
+
+	escaped := []string{}
+	for _, segment := range strings.Split(strings.Join(parts, "/"), "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+func (s *S3Store) do(method string, key string, contentType string, data []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(s.credentials.Endpoint, "/")+s.objectPath(key), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, data)
+	return s.Client.Do(req)
+}
+
+// sign adds signature v4 headers, https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (s *S3Store) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	canonicalHeaders := ""
+	for _, header := range signedHeaders {
+		value := req.Header.Get(header)
+		if header == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += fmt.Sprintf("%s:%s\n", header, strings.TrimSpace(value))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", day, s.credentials.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.credentials.SecretKey), day)
+	for _, part := range []string{s.credentials.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.credentials.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
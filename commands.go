@@ -0,0 +1,70 @@
+package main
+
+import (
+	"spotify/api"
+	"spotify/database"
+	"spotify/ingest"
+	"spotify/metrics"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// commands are run instead of the default ingest when named as the first argument, eg. `spotify poll -u user`
+var commands = map[string]func(args []string){
+	"poll":                pollCommand,
+	"import-history":      importHistoryCommand,
+	"import-scrobbles":    importScrobblesCommand,
+	"export-listenbrainz": exportListenBrainzCommand,
+	"export":              exportCommand,
+	"serve":               serveCommand,
+	"stats":               statsCommand,
+	"report":              reportCommand,
+	"compare":             compareCommand,
+	"mirror-thumbnails":   mirrorThumbnailsCommand,
+	"resolve":             resolveCommand,
+	"doctor":              doctorCommand,
+	"reprocess":           reprocessCommand,
+}
+
+func mustConnectDatabase(env SpotifyIngestEnv) database.Database {
+	return mustConnect(env.DbAuth)
+}
+
+func mustConnect(auth database.DatabaseAuth) database.Database {
+	database := database.Database{Auth: auth}
+	err := database.Connect()
+	if err != nil {
+		logger.Log("Failed to connect to database", logger.Error)
+		panic(err)
+	}
+
+	return database
+}
+
+// mustBootstrapIngest refreshes the users access token and returns an ingest bootstrapped for them,
+// along with the metric handler the api reports to which the caller must close
+func mustBootstrapIngest(env SpotifyIngestEnv, db *database.Database, args ingest.SpotifyIngestOptions) (ingest.SpotifyIngest, metrics.MetricHandler) {
+	ingestContext := ingest.NewIngestContext(args)
+	args.RunID = ingestContext.Id
+	metricHandler, err := metrics.NewMetricHandler(env.LogstashAuth, env.ElasticAuth, ingestContext)
+	if err != nil {
+		logger.Log("Failed to make metrics handler", logger.Error)
+		panic(err)
+	}
+
+	api := api.NewSpotifyAPI("https://accounts.spotify.com/", &metricHandler, env.ApiAuth, api.NewAPIOptions(3))
+	err = Refresh(&api)
+	if err != nil {
+		logger.Log(err.Error(), logger.Error)
+		metricHandler.AddNewFailure("REFRESH_TOKEN", err)
+		panic(err)
+	}
+
+	preingest := ingest.NewPreIngest(db, env.Users)
+	return ingest.BootstrapSpotifyingest(db, &api, &preingest, args), metricHandler
+}
+
+// silenceLogger stops log lines being printed, for commands writing their output to stdout
+func silenceLogger() {
+	logger.Setup(logger.Trace+1, nil, logger.NewLoggerOptions("2006-01-02 15:04:05"))
+}
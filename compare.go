@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"spotify/compare"
+	"spotify/stats"
+	"strings"
+	"time"
+)
+
+func compareCommand(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	users := flags.String("users", "", "Comma separated usernames to compare, defaults to the users env list")
+	from := flags.String("from", "", "Start of the window (YYYY-MM-DD), defaults to 30 days ago")
+	to := flags.String("to", "", "End of the window, exclusive (YYYY-MM-DD), defaults to tomorrow")
+	limit := flags.Int("n", 20, "Number of top artists and songs the jaccard scores use, and shared items to list")
+	asJSON := flags.Bool("json", false, "Print the comparison as json")
+	flags.Parse(args)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if *from == "" {
+		*from = today.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if *to == "" {
+		*to = today.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	fromTime, toTime := mustParseDateRange(*from, *to)
+	if *asJSON {
+		silenceLogger()
+	}
+
+	dbAuth, envUsers := LoadUsersEnv()
+	usernames := envUsers
+	if *users != "" {
+		usernames = strings.Split(*users, ",")
+	}
+	if len(usernames) < 2 {
+		log.Fatalf("At least two users are needed to compare!")
+	}
+
+	database := mustConnect(dbAuth)
+	dailyStats := stats.NewStats(&database)
+	for _, username := range usernames {
+		dbUser, err := database.FetchUserByName(username)
+		if err != nil {
+			log.Fatalf("Failed to find user %s: %s", username, err.Error())
+		}
+
+		err = dailyStats.Refresh(dbUser.ID)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	comparison, err := compare.Compare(&database, usernames, fromTime.Time, toTime.Time, *limit)
+	if err != nil {
+		panic(err)
+	}
+	database.Rollback()
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(comparison)
+		return
+	}
+
+	printComparison(comparison)
+}
+
+func printComparison(comparison compare.Comparison) {
+	fmt.Printf("%s, %s to %s\n", strings.Join(comparison.Users, ", "), comparison.From.Format("2006-01-02"), comparison.To.AddDate(0, 0, -1).Format("2006-01-02"))
+
+	for _, pair := range comparison.Pairs {
+		fmt.Printf("\n%s and %s\n", pair.UserA, pair.UserB)
+		fmt.Printf("Artists: jaccard %.2f, cosine %.2f\n", pair.ArtistJaccard, pair.ArtistCosine)
+		fmt.Printf("Songs:   jaccard %.2f, cosine %.2f\n", pair.SongJaccard, pair.SongCosine)
+		for _, shared := range []struct {
+			title string
+			items []compare.SharedItem
+		}{
+			{"Shared artists", pair.SharedArtists},
+			{"Shared songs", pair.SharedSongs},
+		} {
+			if len(shared.items) == 0 {
+				continue
+			}
+			fmt.Printf("%s\n", shared.title)
+			for _, item := range shared.items {
+				fmt.Printf("  %s (%d/%d plays, found first by %s on %s)\n", item.Name, item.PlaysA, item.PlaysB, item.FirstBy, item.FirstAt.Format("2006-01-02"))
+			}
+		}
+	}
+
+	for _, week := range comparison.Weeks {
+		fmt.Printf("\nWeek of %s\n", week.Week.Format("2006-01-02"))
+		fmt.Printf("  Minutes:        %s\n", leaderboard(week.Minutes))
+		fmt.Printf("  Unique artists: %s\n", leaderboard(week.UniqueArtists))
+	}
+}
+
+func leaderboard(entries []compare.LeaderboardEntry) string {
+	places := []string{}
+	for i, entry := range entries {
+		places = append(places, fmt.Sprintf("%d. %s (%.0f)", i+1, entry.User, entry.Value))
+	}
+	return strings.Join(places, "  ")
+}
@@ -0,0 +1,243 @@
+package compare
+
+import (
+	"math"
+	"sort"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+)
+
+type CompareDatabase interface {
+	FetchUserByName(name string) (models.User, error)
+	FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error)
+	FetchFirstListensByUserID(userID string) ([]models.FirstListen, error)
+	FetchWeeklyTotals(userID string, from utils.Time, to utils.Time) ([]models.WeeklyTotal, error)
+}
+
+// SharedItem is an artist or song both users of a pair played in the window
+type SharedItem struct {
+	Name      string `json:"name"`
+	SpotifyID string `json:"spotify_id"`
+	PlaysA    int    `json:"plays_a"`
+	PlaysB    int    `json:"plays_b"`
+	// FirstBy is whichever user ever played it first, going by their earliest listen
+	FirstBy string    `json:"first_by"`
+	FirstAt time.Time `json:"first_at"`
+}
+
+// Pair is how alike two users taste is. The jaccard scores compare each users top items as sets, the cosine scores
+// compare plays of every item either user played, all four range from 0 (nothing in common) to 1
+type Pair struct {
+	UserA         string       `json:"user_a"`
+	UserB         string       `json:"user_b"`
+	ArtistJaccard float64      `json:"artist_jaccard"`
+	ArtistCosine  float64      `json:"artist_cosine"`
+	SongJaccard   float64      `json:"song_jaccard"`
+	SongCosine    float64      `json:"song_cosine"`
+	SharedArtists []SharedItem `json:"shared_artists"`
+	SharedSongs   []SharedItem `json:"shared_songs"`
+}
+
+type LeaderboardEntry struct {
+	User  string  `json:"user"`
+	Value float64 `json:"value"`
+}
+
+// Week ranks users by minutes listened and distinct artists played in the week starting Week
+type Week struct {
+	Week          time.Time          `json:"week"`
+	Minutes       []LeaderboardEntry `json:"minutes"`
+	UniqueArtists []LeaderboardEntry `json:"unique_artists"`
+}
+
+type Comparison struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Users []string  `json:"users"`
+	Pairs []Pair    `json:"pairs"`
+	Weeks []Week    `json:"weeks"`
+}
+
+type userData struct {
+	name    string
+	items   map[string][]models.TopItemRow
+	firsts  map[string]time.Time
+	weekly  []models.WeeklyTotal
+	topSets map[string]map[string]bool
+}
+
+// Compare works out every pair of users similarity over [from, to) along with weekly leaderboards, all from the
+// daily stats tables so they should be refreshed beforehand. limit caps the top items used for the jaccard scores
+// and the shared items listed per pair
+func Compare(database CompareDatabase, usernames []string, from time.Time, to time.Time, limit int) (Comparison, error) {
+	comparison := Comparison{From: from, To: to, Users: usernames, Pairs: []Pair{}, Weeks: []Week{}}
+
+	users := []userData{}
+	for _, username := range usernames {
+		user, err := load(database, username, from, to, limit)
+		if err != nil {
+			return Comparison{}, err
+		}
+		users = append(users, user)
+	}
+
+	for i := range users {
+		for j := i + 1; j < len(users); j++ {
+			comparison.Pairs = append(comparison.Pairs, compare(users[i], users[j], limit))
+		}
+	}
+
+	comparison.Weeks = leaderboards(users)
+	return comparison, nil
+}
+
+func load(database CompareDatabase, username string, from time.Time, to time.Time, limit int) (userData, error) {
+	user, err := database.FetchUserByName(username)
+	if err != nil {
+		return userData{}, err
+	}
+
+	data := userData{name: username, items: map[string][]models.TopItemRow{}, firsts: map[string]time.Time{}, topSets: map[string]map[string]bool{}}
+	for _, itemType := range []string{models.StatsItemArtist, models.StatsItemSong} {
+		items, err := database.FetchTopItems(user.ID, itemType, utils.Time{Time: from}, utils.Time{Time: to}, 0)
+		if err != nil {
+			return userData{}, err
+		}
+
+		data.items[itemType] = items
+		data.topSets[itemType] = map[string]bool{}
+		for i, item := range items {
+			if i < limit {
+				data.topSets[itemType][item.ItemID] = true
+			}
+		}
+	}
+
+	firsts, err := database.FetchFirstListensByUserID(user.ID)
+	if err != nil {
+		return userData{}, err
+	}
+	for _, first := range firsts {
+		data.firsts[first.ItemType+first.ItemID] = first.PlayedAt.Time
+	}
+
+	data.weekly, err = database.FetchWeeklyTotals(user.ID, utils.Time{Time: from}, utils.Time{Time: to})
+	if err != nil {
+		return userData{}, err
+	}
+
+	return data, nil
+}
+
+func compare(a userData, b userData, limit int) Pair {
+	pair := Pair{UserA: a.name, UserB: b.name}
+	pair.ArtistJaccard = jaccard(a.topSets[models.StatsItemArtist], b.topSets[models.StatsItemArtist])
+	pair.SongJaccard = jaccard(a.topSets[models.StatsItemSong], b.topSets[models.StatsItemSong])
+	pair.ArtistCosine = cosine(plays(a.items[models.StatsItemArtist]), plays(b.items[models.StatsItemArtist]))
+	pair.SongCosine = cosine(plays(a.items[models.StatsItemSong]), plays(b.items[models.StatsItemSong]))
+	pair.SharedArtists = shared(a, b, models.StatsItemArtist, limit)
+	pair.SharedSongs = shared(a, b, models.StatsItemSong, limit)
+	return pair
+}
+
+func plays(items []models.TopItemRow) map[string]float64 {
+	vector := map[string]float64{}
+	for _, item := range items {
+		vector[item.ItemID] = float64(item.Plays)
+	}
+	return vector
+}
+
+func jaccard(a map[string]bool, b map[string]bool) float64 {
+	intersection := 0
+	for id := range a {
+		if b[id] {
+			intersection++
+		}
+	}
+
+	union := len(a) + len(b) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+func cosine(a map[string]float64, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for id, value := range a {
+		dot += value * b[id]
+		normA += value * value
+	}
+	for _, value := range b {
+		normB += value * value
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// shared lists the items both users played, most combined plays first
+func shared(a userData, b userData, itemType string, limit int) []SharedItem {
+	playsB := map[string]int{}
+	for _, item := range b.items[itemType] {
+		playsB[item.ItemID] = item.Plays
+	}
+
+	items := []SharedItem{}
+	for _, item := range a.items[itemType] {
+		otherPlays, exists := playsB[item.ItemID]
+		if !exists {
+			continue
+		}
+
+		sharedItem := SharedItem{Name: item.Name, SpotifyID: item.SpotifyID, PlaysA: item.Plays, PlaysB: otherPlays}
+		firstA, firstB := a.firsts[itemType+item.ItemID], b.firsts[itemType+item.ItemID]
+		sharedItem.FirstBy, sharedItem.FirstAt = a.name, firstA
+		if firstA.IsZero() || (!firstB.IsZero() && firstB.Before(firstA)) {
+			sharedItem.FirstBy, sharedItem.FirstAt = b.name, firstB
+		}
+		items = append(items, sharedItem)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PlaysA+items[i].PlaysB > items[j].PlaysA+items[j].PlaysB
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func leaderboards(users []userData) []Week {
+	weeks := map[time.Time]*Week{}
+	for _, user := range users {
+		for _, total := range user.weekly {
+			start := total.Week.UTC()
+			week, exists := weeks[start]
+			if !exists {
+				week = &Week{Week: start, Minutes: []LeaderboardEntry{}, UniqueArtists: []LeaderboardEntry{}}
+				weeks[start] = week
+			}
+			week.Minutes = append(week.Minutes, LeaderboardEntry{User: user.name, Value: float64(total.EstimatedMs/1000) / 60})
+			week.UniqueArtists = append(week.UniqueArtists, LeaderboardEntry{User: user.name, Value: float64(total.UniqueArtists)})
+		}
+	}
+
+	ordered := []Week{}
+	for _, week := range weeks {
+		for _, entries := range [][]LeaderboardEntry{week.Minutes, week.UniqueArtists} {
+			sort.SliceStable(entries, func(i, j int) bool {
+				return entries[i].Value > entries[j].Value
+			})
+		}
+		ordered = append(ordered, *week)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Week.Before(ordered[j].Week)
+	})
+	return ordered
+}
@@ -0,0 +1,95 @@
+package compare
+
+import (
+	"math"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+type mockCompareDatabase struct {
+	artists map[string][]models.TopItemRow
+	firsts  map[string][]models.FirstListen
+	weekly  map[string][]models.WeeklyTotal
+}
+
+func (db *mockCompareDatabase) FetchUserByName(name string) (models.User, error) {
+	return models.User{ID: name, Username: name}, nil
+}
+
+func (db *mockCompareDatabase) FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	if itemType != models.StatsItemArtist {
+		return nil, nil
+	}
+	return db.artists[userID], nil
+}
+
+func (db *mockCompareDatabase) FetchFirstListensByUserID(userID string) ([]models.FirstListen, error) {
+	return db.firsts[userID], nil
+}
+
+func (db *mockCompareDatabase) FetchWeeklyTotals(userID string, from utils.Time, to utils.Time) ([]models.WeeklyTotal, error) {
+	return db.weekly[userID], nil
+}
+
+func TestCompare(t *testing.T) {
+	week := utils.Time{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	artist := func(id string, plays int) models.TopItemRow {
+		return models.TopItemRow{ItemID: id, Name: id, Plays: plays}
+	}
+	first := func(id string, daysAgo int) models.FirstListen {
+		return models.FirstListen{ItemType: models.StatsItemArtist, ItemID: id, PlayedAt: utils.Time{Time: week.AddDate(0, 0, -daysAgo)}}
+	}
+
+	db := &mockCompareDatabase{
+		artists: map[string][]models.TopItemRow{
+			"alice": {artist("x", 4), artist("y", 2), artist("z", 1)},
+			"bob":   {artist("y", 4), artist("x", 2)},
+		},
+		firsts: map[string][]models.FirstListen{
+			"alice": {first("x", 10), first("y", 1), first("z", 1)},
+			"bob":   {first("x", 5), first("y", 30)},
+		},
+		weekly: map[string][]models.WeeklyTotal{
+			"alice": {{Week: week, EstimatedMs: 600000, UniqueArtists: 3}},
+			"bob":   {{Week: week, EstimatedMs: 1200000, UniqueArtists: 2}},
+		},
+	}
+
+	comparison, err := Compare(db, []string{"alice", "bob"}, week.Time, week.AddDate(0, 0, 7), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comparison.Pairs) != 1 {
+		t.Fatalf("Expected one pair, got %d", len(comparison.Pairs))
+	}
+	pair := comparison.Pairs[0]
+
+	// top 2 of alice is x y and of bob is y x
+	if pair.ArtistJaccard != 1 {
+		t.Errorf("Expected identical top artists, got jaccard %f", pair.ArtistJaccard)
+	}
+	// (4*2 + 2*4 + 1*0) / (sqrt(21) * sqrt(20))
+	if expected := 16 / (math.Sqrt(21) * math.Sqrt(20)); math.Abs(pair.ArtistCosine-expected) > 1e-9 {
+		t.Errorf("Expected cosine %f, got %f", expected, pair.ArtistCosine)
+	}
+	if pair.SongJaccard != 0 || pair.SongCosine != 0 {
+		t.Errorf("Expected no song similarity without songs, got %f %f", pair.SongJaccard, pair.SongCosine)
+	}
+
+	if len(pair.SharedArtists) != 2 {
+		t.Fatalf("Expected x and y to be shared, got %+v", pair.SharedArtists)
+	}
+	for _, shared := range pair.SharedArtists {
+		expected := map[string]string{"x": "alice", "y": "bob"}[shared.Name]
+		if shared.FirstBy != expected {
+			t.Errorf("Expected %s to have found %s first, got %s", expected, shared.Name, shared.FirstBy)
+		}
+	}
+
+	if len(comparison.Weeks) != 1 || comparison.Weeks[0].Minutes[0].User != "bob" || comparison.Weeks[0].UniqueArtists[0].User != "alice" {
+		t.Errorf("Expected bob to lead minutes and alice unique artists, got %+v", comparison.Weeks)
+	}
+}
@@ -2,9 +2,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"regexp"
 	"spotify/models"
 	"spotify/utils"
+	"strings"
+	"time"
 
 	"github.com/batzz-00/goutils/logger"
 
@@ -37,6 +41,7 @@ func (database *Database) Rollback() {
 	if database.Tx != nil {
 		logger.Log("Rolling back changes", logger.Info)
 		database.Tx.Rollback()
+		database.Tx = nil
 		return
 	}
 	logger.Log("No transaction instance to rollback!", logger.Warning)
@@ -46,11 +51,61 @@ func (database *Database) Commit() {
 	if database.Tx != nil {
 		logger.Log("Committing changes", logger.Info)
 		database.Tx.Commit()
+		database.Tx = nil
 		return
 	}
 	logger.Log("No transaction instance to commit!", logger.Warning)
 }
 
+var savepointName = regexp.MustCompile(`^[a-z_]+$`)
+
+// Savepoint marks a point in the transaction that RollbackToSavepoint can return to, names can't be bound as
+// parameters so they are limited to lowercase letters and underscores
+func (database *Database) Savepoint(name string) error {
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	_, err := database.MustGetTx().Exec("SAVEPOINT " + name)
+	return err
+}
+
+// RollbackToSavepoint undoes everything since the named savepoint, leaving the rest of the transaction usable
+func (database *Database) RollbackToSavepoint(name string) error {
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	logger.Log(fmt.Sprintf("Rolling back to savepoint %s", name), logger.Info)
+	_, err := database.MustGetTx().Exec("ROLLBACK TO SAVEPOINT " + name)
+	return err
+}
+
+// ReleaseSavepoint keeps everything since the named savepoint as part of the transaction
+func (database *Database) ReleaseSavepoint(name string) error {
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	_, err := database.MustGetTx().Exec("RELEASE SAVEPOINT " + name)
+	return err
+}
+
+// ReadOnly starts a read only transaction on a copy of the database, so concurrent readers each get their own.
+// Release must be called once done with it
+func (d *Database) ReadOnly(ctx context.Context) (*Database, error) {
+	tx, err := d.DB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Database{DB: d.DB, Tx: tx, Auth: d.Auth}, nil
+}
+
+// Release ends a read only transaction, there is nothing to commit so it is rolled back quietly
+func (d *Database) Release() {
+	if d.Tx != nil {
+		d.Tx.Rollback()
+		d.Tx = nil
+	}
+}
+
 // Connect opens up a conection to the database
 func (db *Database) Connect() error {
 
@@ -102,6 +157,15 @@ func (d *Database) FetchUserByName(name string) (models.User, error) {
 	return user, nil
 }
 
+func (d *Database) FetchUsers() ([]models.User, error) {
+	users := []models.User{}
+	err := d.MustGetTx().Select(&users, "SELECT * FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (d *Database) FetchArtistBySpotifyID(spotifyID string) (models.Artist, error) {
 	artist := models.Artist{}
 	err := d.MustGetTx().Get(&artist, "SELECT * FROM artists WHERE spotify_id = $1", spotifyID)
@@ -141,6 +205,36 @@ func (d *Database) FetchArtistsBySpotifyID(spotifyIDs []interface{}) ([]models.A
 	return artists, nil
 }
 
+func (d *Database) FetchShowsBySpotifyID(spotifyIDs []interface{}) ([]models.Show, error) {
+	shows := []models.Show{}
+	sql := fmt.Sprintf("SELECT * FROM shows WHERE spotify_id IN (%s)", utils.PrepareBatchValuesPG(1, len(spotifyIDs)))
+	err := d.MustGetTx().Select(&shows, sql, spotifyIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return shows, nil
+}
+
+func (d *Database) FetchEpisodesBySpotifyID(spotifyIDs []interface{}) ([]models.Episode, error) {
+	episodes := []models.Episode{}
+	sql := fmt.Sprintf("SELECT * FROM episodes WHERE spotify_id IN (%s)", utils.PrepareBatchValuesPG(1, len(spotifyIDs)))
+	err := d.MustGetTx().Select(&episodes, sql, spotifyIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// FetchLatestEpisodeResumePointsByUserID returns the most recent resume point stored for each episode a user has saved
+func (d *Database) FetchLatestEpisodeResumePointsByUserID(userID string) ([]models.EpisodeResumePoint, error) {
+	resumePoints := []models.EpisodeResumePoint{}
+	err := d.MustGetTx().Select(&resumePoints, "SELECT DISTINCT ON (episode_id) * FROM episode_resume_points WHERE user_id = $1 ORDER BY episode_id, created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return resumePoints, nil
+}
+
 func (d *Database) FetchArtistByID(id string) (models.Artist, error) {
 	artist := models.Artist{}
 	err := d.MustGetTx().Get(&artist, "SELECT * FROM artists WHERE id = $1", id)
@@ -151,6 +245,15 @@ func (d *Database) FetchArtistByID(id string) (models.Artist, error) {
 }
 
 // earliest time optimization
+func (d *Database) FetchAlbumByID(id string) (models.Album, error) {
+	album := models.Album{}
+	err := d.MustGetTx().Get(&album, "SELECT * FROM albums WHERE id = $1", id)
+	if err != nil {
+		return models.Album{}, err
+	}
+	return album, nil
+}
+
 func (d *Database) FetchRecentListensByUserIDAndTime(userID string, recentListenedToIDs []interface{}, earliestTime interface{}) ([]models.RecentListen, error) {
 	recentListens := []models.RecentListen{}
 	columnNames := utils.ColumnNamesExclusive(&models.RecentListen{})
@@ -165,9 +268,406 @@ func (d *Database) FetchRecentListensByUserIDAndTime(userID string, recentListen
 	return recentListens, nil
 }
 
+func (d *Database) FetchRecentListensByUserIDBetween(userID string, from utils.Time, to utils.Time) ([]models.RecentListen, error) {
+	recentListens := []models.RecentListen{}
+	columnNames := utils.ColumnNamesExclusive(&models.RecentListen{})
+	tableName := (&models.RecentListen{}).TableName()
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 AND played_at BETWEEN $2 AND $3", columnNames, tableName)
+	err := d.MustGetTx().Select(&recentListens, sql, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return recentListens, nil
+}
+
+func (d *Database) FetchHistoryImport(userID string, fileName string) (models.HistoryImport, error) {
+	historyImport := models.HistoryImport{}
+	err := d.MustGetTx().Get(&historyImport, "SELECT * FROM history_imports WHERE user_id = $1 AND file_name = $2", userID, fileName)
+	if err != nil {
+		return models.HistoryImport{}, err
+	}
+	return historyImport, nil
+}
+
+func (d *Database) UpdateHistoryImport(historyImport models.HistoryImport) error {
+	_, err := d.MustGetTx().Exec("UPDATE history_imports SET processed = $1, completed = $2, updated_at = $3 WHERE id = $4", historyImport.Processed, historyImport.Completed, utils.NewTime(), historyImport.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FetchScrobbleMatches takes flattened artist_key, track_key pairs
+func (d *Database) FetchScrobbleMatches(keys []interface{}) ([]models.ScrobbleMatch, error) {
+	matches := []models.ScrobbleMatch{}
+	sql := fmt.Sprintf("SELECT * FROM scrobble_matches WHERE (artist_key, track_key) IN (%s)", utils.PrepareInStringPG(2, len(keys)/2, 1))
+	err := d.MustGetTx().Select(&matches, sql, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// streamRows runs query and hands each row to scan one at a time, so exports of years of data don't have to be held in memory
+func (d *Database) streamRows(query string, args []interface{}, scan func(rows *sqlx.Rows) error) error {
+	rows, err := d.MustGetTx().Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamListenRows hands a users listens played in [from, to) to handle oldest first
+func (d *Database) StreamListenRows(userID string, from utils.Time, to utils.Time, handle func(row models.ListenRow) error) error {
+	sql := `SELECT rl.played_at, s.name AS song_name, s.spotify_id AS song_spotify_id, al.name AS album_name, ar.name AS artist_name
+		FROM recent_listens rl
+		JOIN songs s ON s.id = rl.song_id
+		JOIN albums al ON al.id = s.album_id
+		JOIN artists ar ON ar.id = s.artist_id
+		WHERE rl.user_id = $1 AND rl.played_at >= $2 AND rl.played_at < $3
+		ORDER BY rl.played_at`
+	return d.streamRows(sql, []interface{}{userID, from, to}, func(rows *sqlx.Rows) error {
+		row := models.ListenRow{}
+		err := rows.StructScan(&row)
+		if err != nil {
+			return err
+		}
+		return handle(row)
+	})
+}
+
+// StreamTopSongRows hands every entry of a users top songs snapshots taken in [from, to) to handle, oldest snapshot first
+func (d *Database) StreamTopSongRows(userID string, from utils.Time, to utils.Time, handle func(row models.TopSongRow) error) error {
+	sql := `SELECT ts.created_at AS snapshot_at, tsd.time_period, tsd."order", s.name AS song_name, s.spotify_id AS song_spotify_id, al.name AS album_name, ar.name AS artist_name
+		FROM top_songs ts
+		JOIN top_song_data tsd ON tsd.top_song_id = ts.id
+		JOIN songs s ON s.id = tsd.song_id
+		JOIN albums al ON al.id = s.album_id
+		JOIN artists ar ON ar.id = s.artist_id
+		WHERE ts.user_id = $1 AND ts.created_at >= $2 AND ts.created_at < $3
+		ORDER BY ts.created_at, tsd.time_period, tsd."order"`
+	return d.streamRows(sql, []interface{}{userID, from, to}, func(rows *sqlx.Rows) error {
+		row := models.TopSongRow{}
+		err := rows.StructScan(&row)
+		if err != nil {
+			return err
+		}
+		return handle(row)
+	})
+}
+
+// StreamTopArtistRows hands every entry of a users top artists snapshots taken in [from, to) to handle, oldest snapshot first
+func (d *Database) StreamTopArtistRows(userID string, from utils.Time, to utils.Time, handle func(row models.TopArtistRow) error) error {
+	sql := `SELECT ta.created_at AS snapshot_at, tad.time_period, tad."order", ar.name AS artist_name, ar.spotify_id AS artist_spotify_id
+		FROM top_artists ta
+		JOIN top_artist_data tad ON tad.top_artist_id = ta.id
+		JOIN artists ar ON ar.id = tad.artist_id
+		WHERE ta.user_id = $1 AND ta.created_at >= $2 AND ta.created_at < $3
+		ORDER BY ta.created_at, tad.time_period, tad."order"`
+	return d.streamRows(sql, []interface{}{userID, from, to}, func(rows *sqlx.Rows) error {
+		row := models.TopArtistRow{}
+		err := rows.StructScan(&row)
+		if err != nil {
+			return err
+		}
+		return handle(row)
+	})
+}
+
+// FetchListenRowsByUserID pages backwards through a users listens by (played_at, id), returning up to limit that come
+// before the listen played at before with id beforeID. An empty beforeID returns only listens played before before
+func (d *Database) FetchListenRowsByUserID(userID string, before time.Time, beforeID string, limit int) ([]models.ListenRow, error) {
+	listens := []models.ListenRow{}
+	sql := `SELECT rl.id, rl.played_at, s.name AS song_name, s.spotify_id AS song_spotify_id, al.name AS album_name, ar.name AS artist_name
+		FROM recent_listens rl
+		JOIN songs s ON s.id = rl.song_id
+		JOIN albums al ON al.id = s.album_id
+		JOIN artists ar ON ar.id = s.artist_id
+		WHERE rl.user_id = $1 AND (rl.played_at < $2 OR (rl.played_at = $2 AND rl.id::text < $3))
+		ORDER BY rl.played_at DESC, rl.id::text DESC
+		LIMIT $4`
+	err := d.MustGetTx().Select(&listens, sql, userID, before, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return listens, nil
+}
+
+// FetchTopSongRowsAt returns a users top songs for a time period from the latest snapshot taken at or before at
+func (d *Database) FetchTopSongRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopSongRow, error) {
+	rows := []models.TopSongRow{}
+	sql := `SELECT ts.created_at AS snapshot_at, tsd.time_period, tsd."order", s.name AS song_name, s.spotify_id AS song_spotify_id, al.name AS album_name, ar.name AS artist_name
+		FROM top_song_data tsd
+		JOIN top_songs ts ON ts.id = tsd.top_song_id
+		JOIN songs s ON s.id = tsd.song_id
+		JOIN albums al ON al.id = s.album_id
+		JOIN artists ar ON ar.id = s.artist_id
+		WHERE tsd.time_period = $2 AND tsd.top_song_id = (
+			SELECT t.id FROM top_songs t JOIN top_song_data d ON d.top_song_id = t.id
+			WHERE t.user_id = $1 AND d.time_period = $2 AND t.created_at <= $3
+			ORDER BY t.created_at DESC LIMIT 1
+		)
+		ORDER BY tsd."order"`
+	err := d.MustGetTx().Select(&rows, sql, userID, timePeriod, at)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchTopArtistRowsAt returns a users top artists for a time period from the latest snapshot taken at or before at
+func (d *Database) FetchTopArtistRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopArtistRow, error) {
+	rows := []models.TopArtistRow{}
+	sql := `SELECT ta.created_at AS snapshot_at, tad.time_period, tad."order", ar.name AS artist_name, ar.spotify_id AS artist_spotify_id
+		FROM top_artist_data tad
+		JOIN top_artists ta ON ta.id = tad.top_artist_id
+		JOIN artists ar ON ar.id = tad.artist_id
+		WHERE tad.time_period = $2 AND tad.top_artist_id = (
+			SELECT t.id FROM top_artists t JOIN top_artist_data d ON d.top_artist_id = t.id
+			WHERE t.user_id = $1 AND d.time_period = $2 AND t.created_at <= $3
+			ORDER BY t.created_at DESC LIMIT 1
+		)
+		ORDER BY tad."order"`
+	err := d.MustGetTx().Select(&rows, sql, userID, timePeriod, at)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchPlaysPerDay counts a users listens per utc day played in [from, to)
+func (d *Database) FetchPlaysPerDay(userID string, from utils.Time, to utils.Time) ([]models.DailyPlays, error) {
+	days := []models.DailyPlays{}
+	sql := `SELECT date_trunc('day', played_at AT TIME ZONE 'UTC') AS day, COUNT(*) AS plays
+		FROM recent_listens
+		WHERE user_id = $1 AND played_at >= $2 AND played_at < $3
+		GROUP BY 1 ORDER BY 1`
+	err := d.MustGetTx().Select(&days, sql, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// FetchSongPlays counts a users all time listens of each of the songs
+func (d *Database) FetchSongPlays(userID string, songIDs []interface{}) ([]models.SongPlays, error) {
+	plays := []models.SongPlays{}
+	sql := fmt.Sprintf(`SELECT s.id AS song_id, s.name, s.spotify_id, COUNT(*) AS plays
+		FROM recent_listens rl
+		JOIN songs s ON s.id = rl.song_id
+		WHERE rl.user_id = $1 AND rl.song_id IN (%s)
+		GROUP BY s.id, s.name, s.spotify_id`, utils.PrepareInStringPG(1, len(songIDs), 2))
+	vars := []interface{}{userID}
+	vars = append(vars, songIDs...)
+	err := d.MustGetTx().Select(&plays, sql, vars...)
+	if err != nil {
+		return nil, err
+	}
+	return plays, nil
+}
+
+// FetchStaleStatsDays returns the utc days with listens created since the users daily stats were last computed, or
+// whose listen details or estimated play times have changed since, which moves their minutes without adding a listen
+func (d *Database) FetchStaleStatsDays(userID string) ([]utils.Time, error) {
+	days := []utils.Time{}
+	sql := `WITH computed AS (
+			SELECT COALESCE(MAX(computed_at), '-infinity') AS at FROM user_daily_stats WHERE user_id = $1
+		)
+		SELECT DISTINCT date_trunc('day', rl.played_at AT TIME ZONE 'UTC') AS day
+		FROM recent_listens rl
+		CROSS JOIN computed
+		LEFT JOIN recent_listen_details rld ON rld.recent_listen_id = rl.id
+		LEFT JOIN listen_estimates le ON le.recent_listen_id = rl.id
+		WHERE rl.user_id = $1 AND (rl.created_at > computed.at OR rld.updated_at > computed.at OR le.updated_at > computed.at)
+		ORDER BY day`
+	err := d.MustGetTx().Select(&days, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+func (d *Database) FetchStatsListensBetween(userID string, from utils.Time, to utils.Time) ([]models.StatsListen, error) {
+	listens := []models.StatsListen{}
+	sql := `SELECT rl.played_at, rl.song_id, s.album_id, s.artist_id, COALESCE(NULLIF(rld.ms_played, 0), le.estimated_ms_played, s.duration_ms) AS estimated_ms
+		FROM recent_listens rl
+		JOIN songs s ON s.id = rl.song_id
+		LEFT JOIN recent_listen_details rld ON rld.recent_listen_id = rl.id
+		LEFT JOIN listen_estimates le ON le.recent_listen_id = rl.id
+		WHERE rl.user_id = $1 AND rl.played_at >= $2 AND rl.played_at < $3`
+	err := d.MustGetTx().Select(&listens, sql, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return listens, nil
+}
+
+func (d *Database) FetchFirstListensByUserID(userID string) ([]models.FirstListen, error) {
+	firsts := []models.FirstListen{}
+	sql := fmt.Sprintf(`SELECT '%s' AS item_type, song_id AS item_id, MIN(played_at) AS played_at
+		FROM recent_listens WHERE user_id = $1 GROUP BY song_id
+		UNION ALL
+		SELECT '%s', s.artist_id, MIN(rl.played_at)
+		FROM recent_listens rl JOIN songs s ON s.id = rl.song_id WHERE rl.user_id = $1 GROUP BY s.artist_id`, models.StatsItemSong, models.StatsItemArtist)
+	err := d.MustGetTx().Select(&firsts, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	return firsts, nil
+}
+
+// DeleteUserStatsBetween clears every daily stats table for the users days in [from, to) ahead of recomputing them
+func (d *Database) DeleteUserStatsBetween(userID string, from utils.Time, to utils.Time) error {
+	for _, model := range []models.Model{&models.UserDailyStat{}, &models.UserDailyHourStat{}, &models.UserDailyItemStat{}} {
+		sql := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND day >= $2 AND day < $3", model.TableName())
+		_, err := d.MustGetTx().Exec(sql, userID, from, to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) FetchUserDailyStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyStat, error) {
+	stats := []models.UserDailyStat{}
+	err := d.MustGetTx().Select(&stats, "SELECT * FROM user_daily_stats WHERE user_id = $1 AND day >= $2 AND day < $3 ORDER BY day", userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (d *Database) FetchUserDailyHourStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyHourStat, error) {
+	stats := []models.UserDailyHourStat{}
+	err := d.MustGetTx().Select(&stats, "SELECT * FROM user_daily_hour_stats WHERE user_id = $1 AND day >= $2 AND day < $3", userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+var statsItemTables = map[string]string{
+	models.StatsItemSong:   "songs",
+	models.StatsItemAlbum:  "albums",
+	models.StatsItemArtist: "artists",
+}
+
+// statsItemThumbnails is the column on each stats item table that thumbnails are attached to
+var statsItemThumbnails = map[string]string{
+	models.StatsItemSong:   "album_id",
+	models.StatsItemAlbum:  "id",
+	models.StatsItemArtist: "id",
+}
+
+// FetchTopItems sums a users daily plays of songs, albums or artists over [from, to), most played first. A limit
+// of 0 returns every item played
+func (d *Database) FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	items := []models.TopItemRow{}
+	sql := fmt.Sprintf(`SELECT st.item_id, e.name, e.spotify_id, e.%s AS thumbnail_id, SUM(st.plays) AS plays, SUM(st.estimated_ms) AS estimated_ms
+		FROM user_daily_item_stats st
+		JOIN %s e ON e.id = st.item_id
+		WHERE st.user_id = $1 AND st.item_type = $2 AND st.day >= $3 AND st.day < $4
+		GROUP BY st.item_id, e.name, e.spotify_id, e.%s
+		ORDER BY plays DESC, estimated_ms DESC
+		LIMIT NULLIF($5, 0)`, statsItemThumbnails[itemType], statsItemTables[itemType], statsItemThumbnails[itemType])
+	err := d.MustGetTx().Select(&items, sql, userID, itemType, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FetchWeeklyTotals sums a users daily stats over [from, to) by week
+func (d *Database) FetchWeeklyTotals(userID string, from utils.Time, to utils.Time) ([]models.WeeklyTotal, error) {
+	weeks := []models.WeeklyTotal{}
+	sql := `SELECT w.week, w.estimated_ms, COALESCE(a.unique_artists, 0) AS unique_artists
+		FROM (
+			SELECT date_trunc('week', day) AS week, SUM(estimated_ms) AS estimated_ms
+			FROM user_daily_stats
+			WHERE user_id = $1 AND day >= $2 AND day < $3
+			GROUP BY 1
+		) w
+		LEFT JOIN (
+			SELECT date_trunc('week', day) AS week, COUNT(DISTINCT item_id) AS unique_artists
+			FROM user_daily_item_stats
+			WHERE user_id = $1 AND item_type = $4 AND day >= $2 AND day < $3
+			GROUP BY 1
+		) a ON a.week = w.week
+		ORDER BY w.week`
+	err := d.MustGetTx().Select(&weeks, sql, userID, from, to, models.StatsItemArtist)
+	if err != nil {
+		return nil, err
+	}
+	return weeks, nil
+}
+
+// FetchTopDiscoveries is FetchTopItems for artists the user never played before from
+func (d *Database) FetchTopDiscoveries(userID string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	items := []models.TopItemRow{}
+	sql := `SELECT st.item_id, e.name, e.spotify_id, e.id AS thumbnail_id, SUM(st.plays) AS plays, SUM(st.estimated_ms) AS estimated_ms
+		FROM user_daily_item_stats st
+		JOIN artists e ON e.id = st.item_id
+		WHERE st.user_id = $1 AND st.item_type = $2 AND st.day >= $3 AND st.day < $4
+		AND NOT EXISTS (
+			SELECT 1 FROM recent_listens rl JOIN songs s ON s.id = rl.song_id
+			WHERE rl.user_id = $1 AND s.artist_id = st.item_id AND rl.played_at < $3
+		)
+		GROUP BY st.item_id, e.name, e.spotify_id, e.id
+		ORDER BY plays DESC, estimated_ms DESC
+		LIMIT $5`
+	err := d.MustGetTx().Select(&items, sql, userID, models.StatsItemArtist, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FetchGenrePlays sums a users daily artist plays over [from, to) by the artists genres, an artist with several
+// genres counts towards each of them
+func (d *Database) FetchGenrePlays(userID string, from utils.Time, to utils.Time, limit int) ([]models.GenrePlays, error) {
+	genres := []models.GenrePlays{}
+	sql := `SELECT ag.genre, SUM(st.plays) AS plays, SUM(st.estimated_ms) AS estimated_ms
+		FROM user_daily_item_stats st
+		JOIN artist_genres ag ON ag.artist_id = st.item_id
+		WHERE st.user_id = $1 AND st.item_type = $2 AND st.day >= $3 AND st.day < $4
+		GROUP BY ag.genre
+		ORDER BY plays DESC, ag.genre
+		LIMIT $5`
+	err := d.MustGetTx().Select(&genres, sql, userID, models.StatsItemArtist, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// FetchMostReplayedDay finds the day in [from, to) the user played one song the most, sql.ErrNoRows if there were no plays
+func (d *Database) FetchMostReplayedDay(userID string, from utils.Time, to utils.Time) (models.ReplayedDay, error) {
+	replayed := models.ReplayedDay{}
+	sql := `SELECT st.day, st.item_id AS song_id, s.name, s.spotify_id, st.plays
+		FROM user_daily_item_stats st
+		JOIN songs s ON s.id = st.item_id
+		WHERE st.user_id = $1 AND st.item_type = $2 AND st.day >= $3 AND st.day < $4
+		ORDER BY st.plays DESC, st.day
+		LIMIT 1`
+	err := d.MustGetTx().Get(&replayed, sql, userID, models.StatsItemSong, from, to)
+	if err != nil {
+		return models.ReplayedDay{}, err
+	}
+	return replayed, nil
+}
+
+// FetchThumbnailsByEntityID returns the current thumbnails for each entity, leaving out superseded versions
 func (d *Database) FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error) {
 	thumbnails := []models.Thumbnail{}
-	sql := fmt.Sprintf("SELECT * FROM thumbnails WHERE entity_id IN (%s)", utils.PrepareInStringPG(1, len(entityIDs), 1))
+	sql := fmt.Sprintf("SELECT * FROM thumbnails WHERE entity_id IN (%s) AND superseded_at IS NULL", utils.PrepareInStringPG(1, len(entityIDs), 1))
 	err := d.MustGetTx().Select(&thumbnails, sql, entityIDs...)
 	if err != nil {
 		return nil, err
@@ -175,6 +675,325 @@ func (d *Database) FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.
 	return thumbnails, nil
 }
 
+// FetchRawResponsesByRunID returns the responses archived during a run in the order they were requested
+func (d *Database) FetchRawResponsesByRunID(runID string) ([]models.RawResponse, error) {
+	responses := []models.RawResponse{}
+	err := d.MustGetTx().Select(&responses, "SELECT * FROM raw_responses WHERE run_id = $1 ORDER BY sequence", runID)
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// FetchUnmirroredThumbnails returns every thumbnail not yet copied to the blob store, oldest first
+func (d *Database) FetchUnmirroredThumbnails() ([]models.Thumbnail, error) {
+	thumbnails := []models.Thumbnail{}
+	err := d.MustGetTx().Select(&thumbnails, "SELECT * FROM thumbnails WHERE storage_key IS NULL ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	return thumbnails, nil
+}
+
+func (d *Database) SetThumbnailStorage(id string, storageKey string, contentType string, byteSize int64) error {
+	sql := "UPDATE thumbnails SET storage_key = $2, content_type = $3, byte_size = $4, updated_at = $5 WHERE id = $1"
+	_, err := d.MustGetTx().Exec(sql, id, storageKey, contentType, byteSize, utils.NewTime())
+	return err
+}
+
+func (d *Database) FetchSavedTracksByUserID(userID string) ([]models.UserSavedTrack, error) {
+	savedTracks := []models.UserSavedTrack{}
+	err := d.MustGetTx().Select(&savedTracks, "SELECT * FROM user_saved_tracks WHERE user_id = $1 AND removed_at IS NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	return savedTracks, nil
+}
+
+func (d *Database) FetchSavedAlbumsByUserID(userID string) ([]models.UserSavedAlbum, error) {
+	savedAlbums := []models.UserSavedAlbum{}
+	err := d.MustGetTx().Select(&savedAlbums, "SELECT * FROM user_saved_albums WHERE user_id = $1 AND removed_at IS NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	return savedAlbums, nil
+}
+
+func (d *Database) FetchFollowedArtistsByUserID(userID string) ([]models.UserFollowedArtist, error) {
+	followedArtists := []models.UserFollowedArtist{}
+	err := d.MustGetTx().Select(&followedArtists, "SELECT * FROM user_followed_artists WHERE user_id = $1 AND unfollowed_detected IS NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	return followedArtists, nil
+}
+
+// FetchSessionsStaleFrom returns the earliest played_at of a users listens created since their sessions were last
+// computed, invalid when there are none
+func (d *Database) FetchSessionsStaleFrom(userID string) (utils.NullTime, error) {
+	from := utils.NullTime{}
+	sql := `SELECT MIN(played_at) FROM recent_listens
+		WHERE user_id = $1 AND created_at > COALESCE((SELECT MAX(created_at) FROM listening_sessions WHERE user_id = $1), '-infinity')`
+	err := d.MustGetTx().Get(&from, sql, userID)
+	if err != nil {
+		return utils.NullTime{}, err
+	}
+	return from, nil
+}
+
+// FetchListeningSessionBefore returns the users latest session started at or before at, sql.ErrNoRows if there is none
+func (d *Database) FetchListeningSessionBefore(userID string, at utils.Time) (models.ListeningSession, error) {
+	session := models.ListeningSession{}
+	err := d.MustGetTx().Get(&session, "SELECT * FROM listening_sessions WHERE user_id = $1 AND started_at <= $2 ORDER BY started_at DESC LIMIT 1", userID, at)
+	if err != nil {
+		return models.ListeningSession{}, err
+	}
+	return session, nil
+}
+
+func (d *Database) FetchSessionListensFrom(userID string, from utils.Time) ([]models.SessionListen, error) {
+	listens := []models.SessionListen{}
+	sql := `SELECT rl.id AS recent_listen_id, rl.played_at, s.duration_ms, rld.id IS NOT NULL AS has_detail,
+		COALESCE(rld.ms_played, 0) AS ms_played, COALESCE(rld.skipped, false) AS skipped
+		FROM recent_listens rl
+		JOIN songs s ON s.id = rl.song_id
+		LEFT JOIN recent_listen_details rld ON rld.recent_listen_id = rl.id
+		WHERE rl.user_id = $1 AND rl.played_at >= $2
+		ORDER BY rl.played_at`
+	err := d.MustGetTx().Select(&listens, sql, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	return listens, nil
+}
+
+// DeleteListeningSessionsFrom clears a users sessions started at or after from, and their listen estimates
+func (d *Database) DeleteListeningSessionsFrom(userID string, from utils.Time) error {
+	_, err := d.MustGetTx().Exec(`DELETE FROM listen_estimates WHERE listening_session_id IN (
+		SELECT id FROM listening_sessions WHERE user_id = $1 AND started_at >= $2
+	)`, userID, from)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.MustGetTx().Exec("DELETE FROM listening_sessions WHERE user_id = $1 AND started_at >= $2", userID, from)
+	return err
+}
+
+// topListTables are the snapshot table, its data table, the data tables snapshot and item columns for each top list
+var topListTables = map[string][4]string{
+	models.TopListSong:   {"top_songs", "top_song_data", "top_song_id", "song_id"},
+	models.TopListArtist: {"top_artists", "top_artist_data", "top_artist_id", "artist_id"},
+}
+
+// FetchLatestTopListEntries returns the ranks in the most recent snapshot holding each term of a users top songs
+// or artists, ordered by term then rank
+func (d *Database) FetchLatestTopListEntries(userID string, listType string) ([]models.TopListEntry, error) {
+	entries := []models.TopListEntry{}
+	tables := topListTables[listType]
+	sql := fmt.Sprintf(`SELECT d.time_period, d.%[4]s AS item_id, d."order"
+		FROM %[2]s d
+		JOIN (
+			SELECT DISTINCT ON (d.time_period) d.time_period, d.%[3]s AS snapshot_id
+			FROM %[2]s d JOIN %[1]s t ON t.id = d.%[3]s
+			WHERE t.user_id = $1
+			ORDER BY d.time_period, t.created_at DESC
+		) latest ON latest.snapshot_id = d.%[3]s AND latest.time_period = d.time_period
+		ORDER BY d.time_period, d."order"`, tables[0], tables[1], tables[2], tables[3])
+	err := d.MustGetTx().Select(&entries, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FetchLatestPlaylistSnapshotsByUserID returns the most recent snapshot stored for each of a users playlists
+func (d *Database) FetchLatestPlaylistSnapshotsByUserID(userID string) ([]models.PlaylistSnapshot, error) {
+	snapshots := []models.PlaylistSnapshot{}
+	err := d.MustGetTx().Select(&snapshots, "SELECT DISTINCT ON (spotify_id) * FROM playlist_snapshots WHERE user_id = $1 ORDER BY spotify_id, created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// FetchPlaybackSessionMatches returns every pairing of a users unmatched playback sessions with the unmatched
+// recent_listens rows spotify could have reported for them, the closest pairings first
+func (d *Database) FetchPlaybackSessionMatches(userID string) ([]models.PlaybackSessionMatch, error) {
+	matches := []models.PlaybackSessionMatch{}
+	sql := `SELECT ps.id AS session_id, rl.id AS recent_listen_id
+	FROM playback_sessions ps
+	JOIN recent_listens rl ON rl.user_id = ps.user_id AND rl.song_id = ps.song_id
+		AND rl.played_at BETWEEN ps.started_at AND ps.ended_at + interval '5 minutes'
+	WHERE ps.user_id = $1 AND ps.recent_listen_id IS NULL
+	AND NOT EXISTS (SELECT 1 FROM playback_sessions matched WHERE matched.recent_listen_id = rl.id)
+	ORDER BY ABS(EXTRACT(EPOCH FROM rl.played_at - ps.ended_at)), ps.started_at, rl.played_at`
+	err := d.MustGetTx().Select(&matches, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FetchStaleEntities returns up to limit artists, albums and songs last updated before, least recently updated first
+func (d *Database) FetchStaleEntities(before utils.Time, limit int) ([]models.StaleEntity, error) {
+	entities := []models.StaleEntity{}
+	sql := fmt.Sprintf(`SELECT * FROM (
+			SELECT '%s' AS entity_type, id, spotify_id, name, 0 AS duration_ms, updated_at FROM artists WHERE updated_at < $1
+			UNION ALL
+			SELECT '%s', id, spotify_id, name, 0, updated_at FROM albums WHERE updated_at < $1
+			UNION ALL
+			SELECT '%s', id, spotify_id, name, duration_ms, updated_at FROM songs WHERE updated_at < $1
+		) stale
+		ORDER BY updated_at
+		LIMIT $2`, models.EntityArtist, models.EntityAlbum, models.EntitySong)
+	err := d.MustGetTx().Select(&entities, sql, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// UpdateByID sets columns on one row, always bumping updated_at to at even when there's nothing else to set
+func (d *Database) UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error {
+	assignments := []string{`"updated_at" = $2`}
+	vars := []interface{}{id, at}
+	for _, column := range utils.MapOrderedKeys(values) {
+		vars = append(vars, values[column])
+		assignments = append(assignments, fmt.Sprintf(`"%s" = $%d`, column, len(vars)))
+	}
+
+	sql := fmt.Sprintf("UPDATE %s SET %s WHERE id = $1", model.TableName(), strings.Join(assignments, ", "))
+	_, err := d.MustGetTx().Exec(sql, vars...)
+	return err
+}
+
+// FetchUnresolvedReferences returns up to limit references still waiting to be resolved, those never attempted first
+// then the longest since their last attempt. References attempted MaxResolveAttempts times are given up on
+func (d *Database) FetchUnresolvedReferences(limit int) ([]models.UnresolvedReference, error) {
+	references := []models.UnresolvedReference{}
+	sql := `SELECT * FROM unresolved_references WHERE resolved_at IS NULL AND attempts < $2
+		ORDER BY last_attempt_at NULLS FIRST, created_at LIMIT $1`
+	err := d.MustGetTx().Select(&references, sql, limit, models.MaxResolveAttempts)
+	if err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// FetchIngestStagesByRunID returns the stages the run has committed
+func (d *Database) FetchIngestStagesByRunID(runID string) ([]models.IngestStage, error) {
+	stages := []models.IngestStage{}
+	err := d.MustGetTx().Select(&stages, "SELECT * FROM ingest_stages WHERE run_id = $1", runID)
+	if err != nil {
+		return nil, err
+	}
+	return stages, nil
+}
+
+// RecordResolveAttempts counts a failed attempt at resolving each of the references
+func (d *Database) RecordResolveAttempts(ids []interface{}, at utils.Time) error {
+	sql := fmt.Sprintf(`UPDATE unresolved_references SET attempts = attempts + 1, last_attempt_at = $1, updated_at = $1 WHERE id IN (%s)`, utils.PrepareInStringPG(1, len(ids), 2))
+	vars := []interface{}{at}
+	vars = append(vars, ids...)
+	_, err := d.MustGetTx().Exec(sql, vars...)
+	return err
+}
+
+// ResolveReference patches the referencing row with the now known id and marks the reference resolved
+func (d *Database) ResolveReference(reference models.UnresolvedReference, resolvedID string, at utils.Time) error {
+	sql := fmt.Sprintf(`UPDATE %s SET "%s" = $2, "updated_at" = $3 WHERE id = $1`, reference.SourceTable, reference.SourceColumn)
+	_, err := d.MustGetTx().Exec(sql, reference.SourceID, resolvedID, at)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.MustGetTx().Exec("UPDATE unresolved_references SET resolved_at = $2, updated_at = $2 WHERE id = $1", reference.ID, at)
+	return err
+}
+
+const uuidPattern = `'^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'`
+
+// integrityChecks select the id, spotify id and offending value of every row failing each check
+var integrityChecks = map[string]string{
+	models.IntegritySongArtistNotUUID:  "SELECT id, spotify_id, artist_id AS value FROM songs WHERE artist_id !~ " + uuidPattern,
+	models.IntegritySongAlbumNotUUID:   "SELECT id, spotify_id, album_id AS value FROM songs WHERE album_id !~ " + uuidPattern,
+	models.IntegrityAlbumArtistNotUUID: "SELECT id, spotify_id, artist_id AS value FROM albums WHERE artist_id !~ " + uuidPattern,
+	models.IntegritySongArtistOrphan: `SELECT s.id, s.spotify_id, s.artist_id AS value FROM songs s
+		WHERE s.artist_id ~ ` + uuidPattern + ` AND NOT EXISTS (SELECT 1 FROM artists a WHERE a.id = s.artist_id)`,
+	models.IntegritySongAlbumOrphan: `SELECT s.id, s.spotify_id, s.album_id AS value FROM songs s
+		WHERE s.album_id ~ ` + uuidPattern + ` AND NOT EXISTS (SELECT 1 FROM albums al WHERE al.id = s.album_id)`,
+	// albums whose artist has none of the albums songs, leaving out 'various artists' compilations
+	models.IntegrityAlbumArtistMismatch: `SELECT al.id, al.spotify_id, ar.name AS value FROM albums al
+		JOIN artists ar ON ar.id = al.artist_id
+		WHERE ar.spotify_id <> '0LyfQWJT6nXafLPZqxe9Of'
+		AND EXISTS (SELECT 1 FROM songs s WHERE s.album_id = al.id)
+		AND NOT EXISTS (SELECT 1 FROM songs s WHERE s.album_id = al.id AND s.artist_id = al.artist_id)`,
+	models.IntegrityRecentListenOrphan: `SELECT rl.id, '' AS spotify_id, rl.song_id AS value FROM recent_listens rl
+		WHERE rl.song_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = rl.song_id)`,
+	models.IntegrityThumbnailUnattached: `SELECT t.id, '' AS spotify_id, t.url AS value FROM thumbnails t
+		WHERE t.entity_id = ''
+		OR (t.entity_type = 'Artist' AND NOT EXISTS (SELECT 1 FROM artists e WHERE e.id = t.entity_id))
+		OR (t.entity_type = 'Album' AND NOT EXISTS (SELECT 1 FROM albums e WHERE e.id = t.entity_id))
+		OR (t.entity_type = 'Show' AND NOT EXISTS (SELECT 1 FROM shows e WHERE e.id = t.entity_id))
+		OR (t.entity_type = 'Episode' AND NOT EXISTS (SELECT 1 FROM episodes e WHERE e.id = t.entity_id))`,
+	models.IntegrityDuplicateArtists: "SELECT MIN(id::text) AS id, spotify_id, COUNT(*)::text AS value FROM artists GROUP BY spotify_id HAVING COUNT(*) > 1",
+	models.IntegrityDuplicateAlbums:  "SELECT MIN(id::text) AS id, spotify_id, COUNT(*)::text AS value FROM albums GROUP BY spotify_id HAVING COUNT(*) > 1",
+	models.IntegrityDuplicateSongs:   "SELECT MIN(id::text) AS id, spotify_id, COUNT(*)::text AS value FROM songs GROUP BY spotify_id HAVING COUNT(*) > 1",
+}
+
+// CountIntegrityIssues returns how many rows fail the named integrity check
+func (d *Database) CountIntegrityIssues(check string) (int, error) {
+	count := 0
+	err := d.MustGetTx().Get(&count, fmt.Sprintf("SELECT COUNT(*) FROM (%s) issues", integrityChecks[check]))
+	return count, err
+}
+
+// FetchIntegrityIssues returns up to limit rows failing the named integrity check, a limit of 0 returns all of them
+func (d *Database) FetchIntegrityIssues(check string, limit int) ([]models.IntegrityIssue, error) {
+	issues := []models.IntegrityIssue{}
+	sql := fmt.Sprintf("SELECT * FROM (%s) issues ORDER BY id LIMIT NULLIF($1, 0)", integrityChecks[check])
+	err := d.MustGetTx().Select(&issues, sql, limit)
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// FetchThumbnailOwners returns every artist, album, show and episode without a current thumbnail
+func (d *Database) FetchThumbnailOwners() ([]models.ThumbnailOwner, error) {
+	owners := []models.ThumbnailOwner{}
+	sql := `SELECT 'Artist' AS entity_type, e.id, e.spotify_id FROM artists e
+			WHERE NOT EXISTS (SELECT 1 FROM thumbnails t WHERE t.entity_id = e.id AND t.superseded_at IS NULL)
+		UNION ALL
+		SELECT 'Album', e.id, e.spotify_id FROM albums e
+			WHERE NOT EXISTS (SELECT 1 FROM thumbnails t WHERE t.entity_id = e.id AND t.superseded_at IS NULL)
+		UNION ALL
+		SELECT 'Show', e.id, e.spotify_id FROM shows e
+			WHERE NOT EXISTS (SELECT 1 FROM thumbnails t WHERE t.entity_id = e.id AND t.superseded_at IS NULL)
+		UNION ALL
+		SELECT 'Episode', e.id, e.spotify_id FROM episodes e
+			WHERE NOT EXISTS (SELECT 1 FROM thumbnails t WHERE t.entity_id = e.id AND t.superseded_at IS NULL)`
+	err := d.MustGetTx().Select(&owners, sql)
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// SetTimeByIDs sets a timestamp column (and updated_at) on every row of the models table matching ids
+func (d *Database) SetTimeByIDs(model models.Model, column string, ids []interface{}, at utils.Time) error {
+	tableName := model.TableName()
+	sql := fmt.Sprintf(`UPDATE %s SET "%s" = $1, "updated_at" = $1 WHERE id IN (%s)`, tableName, column, utils.PrepareInStringPG(1, len(ids), 2))
+	vars := []interface{}{at}
+	vars = append(vars, ids...)
+	_, err := d.MustGetTx().Exec(sql, vars...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (d *Database) Create(model models.Model, values []interface{}) error {
 	columnNames := utils.ColumnNamesExclusive(model)
 	tableName := model.TableName()
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"reflect"
 	"spotify/models"
+	"spotify/utils"
 )
 
 type MockDatabase struct {
@@ -74,6 +75,81 @@ func (db *MockDatabase) FetchRecentListensByUserIDAndTime(userID string, recentL
 	return nil, nil
 }
 
+func (db *MockDatabase) FetchLatestTopListEntries(userID string, listType string) ([]models.TopListEntry, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchStaleEntities(before utils.Time, limit int) ([]models.StaleEntity, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error {
+	return nil
+}
+
+func (db *MockDatabase) FetchUnresolvedReferences(limit int) ([]models.UnresolvedReference, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchIngestStagesByRunID(runID string) ([]models.IngestStage, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) RecordResolveAttempts(ids []interface{}, at utils.Time) error {
+	return nil
+}
+
+func (db *MockDatabase) ResolveReference(reference models.UnresolvedReference, resolvedID string, at utils.Time) error {
+	return nil
+}
+
 func (db *MockDatabase) FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error) {
 	return nil, nil
 }
+
+func (db *MockDatabase) FetchSavedTracksByUserID(userID string) ([]models.UserSavedTrack, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchSavedAlbumsByUserID(userID string) ([]models.UserSavedAlbum, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) SetTimeByIDs(model models.Model, column string, ids []interface{}, at utils.Time) error {
+	return nil
+}
+
+func (db *MockDatabase) FetchFollowedArtistsByUserID(userID string) ([]models.UserFollowedArtist, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchLatestPlaylistSnapshotsByUserID(userID string) ([]models.PlaylistSnapshot, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchShowsBySpotifyID(spotifyIDs []interface{}) ([]models.Show, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchEpisodesBySpotifyID(spotifyIDs []interface{}) ([]models.Episode, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) FetchLatestEpisodeResumePointsByUserID(userID string) ([]models.EpisodeResumePoint, error) {
+	return nil, nil
+}
+
+func (db *MockDatabase) Commit() {
+}
+
+func (db *MockDatabase) Savepoint(name string) error {
+	return nil
+}
+
+func (db *MockDatabase) RollbackToSavepoint(name string) error {
+	return nil
+}
+
+func (db *MockDatabase) ReleaseSavepoint(name string) error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"spotify/doctor"
+	"spotify/ingest"
+)
+
+func doctorCommand(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	user := flags.String("u", "", "Username whose spotify token to repair rows with, only needed with -fix")
+	fix := flags.Bool("fix", false, "Repair what can be repaired through the spotify api, in a single transaction")
+	samples := flags.Int("n", 5, "Number of offending rows to print per check")
+	flags.Parse(args)
+
+	if *fix && *user == "" {
+		log.Fatalf("UserID must be specified to fix!")
+	}
+
+	var doc doctor.Doctor
+	if *fix {
+		env := LoadEnv(*user)
+		database := mustConnectDatabase(env)
+		spotify, metricHandler := mustBootstrapIngest(env, &database, ingest.SpotifyIngestOptions{UserID: *user, EnvUsers: env.Users})
+		defer metricHandler.Close()
+		doc = doctor.NewDoctor(&database, &spotify, spotify.API)
+	} else {
+		silenceLogger()
+		dbAuth, _ := LoadUsersEnv()
+		database := mustConnect(dbAuth)
+		doc = doctor.NewDoctor(&database, nil, nil)
+	}
+	doc.Samples = *samples
+
+	results, err := doc.Run(*fix)
+	if err != nil {
+		log.Fatalf("Doctor failed, nothing was changed: %s", err.Error())
+	}
+
+	printDoctorResults(results, *fix)
+}
+
+func printDoctorResults(results []doctor.Result, fix bool) {
+	for _, result := range results {
+		status := "ok"
+		if result.Count > 0 {
+			status = fmt.Sprintf("%d rows", result.Count)
+		}
+		fmt.Printf("%-30s %-10s %s\n", result.Check.Name, status, result.Check.Description)
+
+		for _, sample := range result.Samples {
+			fmt.Printf("    %s %s %s\n", sample.ID, sample.SpotifyID, sample.Value)
+		}
+
+		if !fix || result.Count == 0 {
+			continue
+		}
+		if result.Check.Repairable() {
+			fmt.Printf("    fixed %d of %d\n", result.Fixed, result.Count)
+		} else {
+			fmt.Printf("    not repairable, left as is\n")
+		}
+	}
+}
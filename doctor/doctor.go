@@ -0,0 +1,351 @@
+package doctor
+
+import (
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+type DoctorDatabase interface {
+	CountIntegrityIssues(check string) (int, error)
+	FetchIntegrityIssues(check string, limit int) ([]models.IntegrityIssue, error)
+	UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error
+	FetchThumbnailOwners() ([]models.ThumbnailOwner, error)
+	Commit()
+	Rollback()
+}
+
+// Resolver looks up the stored id for spotify ids, creating any we don't have yet, the ingest implements it
+type Resolver interface {
+	ResolveSpotifyIDs(kind string, spotifyIDs utils.StringArgs) (map[string]string, error)
+}
+
+type API interface {
+	TracksBySpotifyID(ids []string) ([]api.Song, error)
+	AlbumsBySpotifyID(ids []string) ([]api.Album, error)
+	ArtistsBySpotifyID(ids []string) ([]api.Artist, error)
+	ShowsBySpotifyID(ids []string) ([]api.Show, error)
+	EpisodesBySpotifyID(ids []string) ([]api.Episode, error)
+}
+
+// Check is one integrity check, checks without a repair are only reported
+type Check struct {
+	Name        string
+	Description string
+	repair      func(doctor *Doctor, issues []models.IntegrityIssue) (int, error)
+}
+
+// Checks is the catalog of integrity checks, run in order
+var Checks = []Check{
+	{models.IntegritySongArtistNotUUID, "Songs holding a spotify id in artist_id", repairColumn(&models.Song{}, "artist_id", models.EntityArtist)},
+	{models.IntegritySongAlbumNotUUID, "Songs holding a spotify id in album_id", repairColumn(&models.Song{}, "album_id", models.EntityAlbum)},
+	{models.IntegrityAlbumArtistNotUUID, "Albums holding a spotify id in artist_id", repairColumn(&models.Album{}, "artist_id", models.EntityArtist)},
+	{models.IntegritySongArtistOrphan, "Songs whose artist doesn't exist", repairSongs("artist_id", models.EntityArtist)},
+	{models.IntegritySongAlbumOrphan, "Songs whose album doesn't exist", repairSongs("album_id", models.EntityAlbum)},
+	{models.IntegrityAlbumArtistMismatch, "Albums attached to an artist with none of their songs", repairAlbumArtists},
+	{models.IntegrityRecentListenOrphan, "Recent listens whose song doesn't exist", nil},
+	{models.IntegrityThumbnailUnattached, "Thumbnails attached to no entity", reattachThumbnails},
+	{models.IntegrityDuplicateArtists, "Spotify ids stored as more than one artist", nil},
+	{models.IntegrityDuplicateAlbums, "Spotify ids stored as more than one album", nil},
+	{models.IntegrityDuplicateSongs, "Spotify ids stored as more than one song", nil},
+}
+
+func (check Check) Repairable() bool {
+	return check.repair != nil
+}
+
+type Result struct {
+	Check   Check
+	Count   int
+	Samples []models.IntegrityIssue
+	Fixed   int
+}
+
+type Doctor struct {
+	database DoctorDatabase
+	resolver Resolver
+	api      API
+	// Samples is how many offending rows to return per check
+	Samples int
+}
+
+// NewDoctor returns a doctor that only reports when resolver and api are nil
+func NewDoctor(database DoctorDatabase, resolver Resolver, api API) Doctor {
+	return Doctor{
+		database: database,
+		resolver: resolver,
+		api:      api,
+		Samples:  5,
+	}
+}
+
+// Run counts and samples the rows failing each check. With fix every repairable check is repaired inside the one
+// transaction, which is rolled back if any repair fails
+func (d *Doctor) Run(fix bool) ([]Result, error) {
+	if fix && (d.resolver == nil || d.api == nil) {
+		return nil, fmt.Errorf("fixing needs a resolver and api")
+	}
+
+	results := []Result{}
+	for _, check := range Checks {
+		result, err := d.run(check, fix)
+		if err != nil {
+			d.database.Rollback()
+			return nil, fmt.Errorf("%s: %w", check.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	if fix {
+		d.database.Commit()
+	} else {
+		d.database.Rollback()
+	}
+	return results, nil
+}
+
+func (d *Doctor) run(check Check, fix bool) (Result, error) {
+	result := Result{Check: check}
+	var err error
+	result.Count, err = d.database.CountIntegrityIssues(check.Name)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Count == 0 {
+		return result, nil
+	}
+
+	result.Samples, err = d.database.FetchIntegrityIssues(check.Name, d.Samples)
+	if err != nil {
+		return result, err
+	}
+
+	if !fix || !check.Repairable() {
+		return result, nil
+	}
+
+	issues, err := d.database.FetchIntegrityIssues(check.Name, 0)
+	if err != nil {
+		return result, err
+	}
+
+	logger.Log(fmt.Sprintf("Repairing %d rows failing %s", len(issues), check.Name), logger.Info)
+	result.Fixed, err = check.repair(d, issues)
+	return result, err
+}
+
+// patch points column of each issues row at the stored id of the spotify id found for it, rows without a spotify id
+// or whose spotify id can't be resolved are left alone
+func (d *Doctor) patch(model models.Model, column string, kind string, issues []models.IntegrityIssue, spotifyIDs map[string]string) (int, error) {
+	toResolve := utils.NewStringArgs()
+	for _, spotifyID := range spotifyIDs {
+		toResolve.Add(spotifyID)
+	}
+
+	if len(toResolve.UniqueMap) == 0 {
+		return 0, nil
+	}
+
+	resolved, err := d.resolver.ResolveSpotifyIDs(kind, toResolve)
+	if err != nil {
+		return 0, err
+	}
+
+	fixed := 0
+	at := utils.NewTime()
+	for _, issue := range issues {
+		id, exists := resolved[spotifyIDs[issue.ID]]
+		if !exists {
+			logger.Log(fmt.Sprintf("Unable to resolve %s %s for %s %s", kind, spotifyIDs[issue.ID], model.TableName(), issue.ID), logger.Warning)
+			continue
+		}
+
+		err = d.database.UpdateByID(model, issue.ID, map[string]interface{}{column: id}, at)
+		if err != nil {
+			return fixed, err
+		}
+		fixed++
+	}
+	return fixed, nil
+}
+
+// repairColumn resolves the spotify id left in a foreign key column
+func repairColumn(model models.Model, column string, kind string) func(*Doctor, []models.IntegrityIssue) (int, error) {
+	return func(d *Doctor, issues []models.IntegrityIssue) (int, error) {
+		spotifyIDs := map[string]string{}
+		for _, issue := range issues {
+			spotifyIDs[issue.ID] = issue.Value
+		}
+		return d.patch(model, column, kind, issues, spotifyIDs)
+	}
+}
+
+// repairSongs looks each song back up from spotify to find the artist or album it should reference
+func repairSongs(column string, kind string) func(*Doctor, []models.IntegrityIssue) (int, error) {
+	return func(d *Doctor, issues []models.IntegrityIssue) (int, error) {
+		trackIDs := []string{}
+		for _, issue := range issues {
+			trackIDs = append(trackIDs, issue.SpotifyID)
+		}
+
+		tracks, err := d.api.TracksBySpotifyID(trackIDs)
+		if err != nil {
+			return 0, err
+		}
+
+		referenced := map[string]string{}
+		for _, track := range tracks {
+			if kind == models.EntityAlbum {
+				referenced[track.ID] = track.Album.ID
+			} else if len(track.Artists) > 0 {
+				referenced[track.ID] = track.Artists[0].ID
+			}
+		}
+
+		spotifyIDs := map[string]string{}
+		for _, issue := range issues {
+			if spotifyID, exists := referenced[issue.SpotifyID]; exists && spotifyID != "" {
+				spotifyIDs[issue.ID] = spotifyID
+			}
+		}
+		return d.patch(&models.Song{}, column, kind, issues, spotifyIDs)
+	}
+}
+
+// repairAlbumArtists looks each album back up from spotify to find its own artist
+func repairAlbumArtists(d *Doctor, issues []models.IntegrityIssue) (int, error) {
+	albumIDs := []string{}
+	for _, issue := range issues {
+		albumIDs = append(albumIDs, issue.SpotifyID)
+	}
+
+	albums, err := d.api.AlbumsBySpotifyID(albumIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	artists := map[string]string{}
+	for _, album := range albums {
+		if len(album.Artists) > 0 {
+			artists[album.ID] = album.Artists[0].ID
+		}
+	}
+
+	spotifyIDs := map[string]string{}
+	for _, issue := range issues {
+		if artistID, exists := artists[issue.SpotifyID]; exists {
+			spotifyIDs[issue.ID] = artistID
+		}
+	}
+	return d.patch(&models.Album{}, "artist_id", models.EntityArtist, issues, spotifyIDs)
+}
+
+// reattachThumbnails looks the artists, albums, shows and episodes without a thumbnail back up from spotify,
+// attaching each unattached thumbnail to the one serving its url. Thumbnails none of them serve are left for a person
+// to look at rather than dropped
+func reattachThumbnails(d *Doctor, issues []models.IntegrityIssue) (int, error) {
+	owners, err := d.database.FetchThumbnailOwners()
+	if err != nil {
+		return 0, err
+	}
+
+	byType := map[string]map[string]models.ThumbnailOwner{}
+	for _, owner := range owners {
+		if _, exists := byType[owner.EntityType]; !exists {
+			byType[owner.EntityType] = map[string]models.ThumbnailOwner{}
+		}
+		byType[owner.EntityType][owner.SpotifyID] = owner
+	}
+
+	// owned maps each image url spotify serves to the entity serving it
+	owned := map[string]models.ThumbnailOwner{}
+	for _, entityType := range utils.MapOrderedKeys(byType) {
+		images, err := d.images(entityType, utils.MapOrderedKeys(byType[entityType]))
+		if err != nil {
+			return 0, err
+		}
+		for spotifyID, urls := range images {
+			owner, exists := byType[entityType][spotifyID]
+			if !exists {
+				continue
+			}
+			for _, url := range urls {
+				owned[url] = owner
+			}
+		}
+	}
+
+	fixed := 0
+	at := utils.NewTime()
+	for _, issue := range issues {
+		owner, exists := owned[issue.Value]
+		if !exists {
+			continue
+		}
+
+		err = d.database.UpdateByID(&models.Thumbnail{}, issue.ID, map[string]interface{}{"entity_type": owner.EntityType, "entity_id": owner.ID}, at)
+		if err != nil {
+			return fixed, err
+		}
+		fixed++
+	}
+
+	if fixed < len(issues) {
+		logger.Log(fmt.Sprintf("Left %d thumbnails no stored entity serves unrepaired", len(issues)-fixed), logger.Warning)
+	}
+	return fixed, nil
+}
+
+// images returns the image urls spotify serves for each spotify id of the thumbnail entity type
+func (d *Doctor) images(entityType string, spotifyIDs []string) (map[string][]string, error) {
+	images := map[string][]string{}
+	switch entityType {
+	case "Artist":
+		artists, err := d.api.ArtistsBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, artist := range artists {
+			images[artist.ID] = imageURLs(artist.Images)
+		}
+	case "Album":
+		albums, err := d.api.AlbumsBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, album := range albums {
+			images[album.ID] = imageURLs(album.Images)
+		}
+	case "Show":
+		shows, err := d.api.ShowsBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, show := range shows {
+			images[show.ID] = imageURLs(show.Images)
+		}
+	case "Episode":
+		episodes, err := d.api.EpisodesBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range episodes {
+			images[episode.ID] = imageURLs(episode.Images)
+		}
+	default:
+		return nil, fmt.Errorf("unknown thumbnail entity type %s", entityType)
+	}
+	return images, nil
+}
+
+func imageURLs(images []api.Image) []string {
+	urls := []string{}
+	for _, image := range images {
+		urls = append(urls, image.URL)
+	}
+	return urls
+}
@@ -0,0 +1,243 @@
+package doctor
+
+import (
+	"encoding/json"
+	"reflect"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+)
+
+type mockDoctorDatabase struct {
+	issues    map[string][]models.IntegrityIssue
+	updates   map[string]map[string]interface{}
+	owners    []models.ThumbnailOwner
+	commits   int
+	rollbacks int
+}
+
+func (db *mockDoctorDatabase) CountIntegrityIssues(check string) (int, error) {
+	return len(db.issues[check]), nil
+}
+
+func (db *mockDoctorDatabase) FetchIntegrityIssues(check string, limit int) ([]models.IntegrityIssue, error) {
+	issues := db.issues[check]
+	if limit > 0 && len(issues) > limit {
+		issues = issues[:limit]
+	}
+	return issues, nil
+}
+
+func (db *mockDoctorDatabase) UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error {
+	db.updates[model.TableName()+"/"+id] = values
+	return nil
+}
+
+func (db *mockDoctorDatabase) FetchThumbnailOwners() ([]models.ThumbnailOwner, error) {
+	return db.owners, nil
+}
+
+func (db *mockDoctorDatabase) Commit() {
+	db.commits++
+}
+
+func (db *mockDoctorDatabase) Rollback() {
+	db.rollbacks++
+}
+
+type mockResolver map[string]map[string]string
+
+func (r mockResolver) ResolveSpotifyIDs(kind string, spotifyIDs utils.StringArgs) (map[string]string, error) {
+	ids := map[string]string{}
+	for spotifyID := range spotifyIDs.UniqueMap {
+		if id, exists := r[kind][spotifyID]; exists {
+			ids[spotifyID] = id
+		}
+	}
+	return ids, nil
+}
+
+type mockAPI struct {
+	tracks   string
+	albums   string
+	artists  string
+	shows    string
+	episodes string
+}
+
+func (a mockAPI) TracksBySpotifyID(ids []string) ([]api.Song, error) {
+	tracks := []api.Song{}
+	err := json.Unmarshal([]byte(a.tracks), &tracks)
+	return tracks, err
+}
+
+func (a mockAPI) AlbumsBySpotifyID(ids []string) ([]api.Album, error) {
+	albums := []api.Album{}
+	err := json.Unmarshal([]byte(a.albums), &albums)
+	return albums, err
+}
+
+func (a mockAPI) ArtistsBySpotifyID(ids []string) ([]api.Artist, error) {
+	artists := []api.Artist{}
+	err := json.Unmarshal([]byte(a.artists), &artists)
+	return artists, err
+}
+
+func (a mockAPI) ShowsBySpotifyID(ids []string) ([]api.Show, error) {
+	shows := []api.Show{}
+	if a.shows == "" {
+		return shows, nil
+	}
+	err := json.Unmarshal([]byte(a.shows), &shows)
+	return shows, err
+}
+
+func (a mockAPI) EpisodesBySpotifyID(ids []string) ([]api.Episode, error) {
+	episodes := []api.Episode{}
+	if a.episodes == "" {
+		return episodes, nil
+	}
+	err := json.Unmarshal([]byte(a.episodes), &episodes)
+	return episodes, err
+}
+
+func newMockDatabase() *mockDoctorDatabase {
+	return &mockDoctorDatabase{
+		issues: map[string][]models.IntegrityIssue{
+			models.IntegritySongArtistNotUUID: {
+				{ID: "song-1", SpotifyID: "track-1", Value: "artist-spotify-1"},
+				{ID: "song-2", SpotifyID: "track-2", Value: "gone-from-spotify"},
+			},
+			models.IntegritySongAlbumOrphan:     {{ID: "song-3", SpotifyID: "track-3", Value: "deleted-album-uuid"}},
+			models.IntegrityAlbumArtistMismatch: {{ID: "album-1", SpotifyID: "album-spotify-1", Value: "First artist"}},
+			models.IntegrityThumbnailUnattached: {
+				{ID: "thumbnail-1", Value: "https://i.scdn.co/image/a"},
+				{ID: "thumbnail-2", Value: "https://i.scdn.co/image/b"},
+			},
+			models.IntegrityDuplicateSongs: {{ID: "song-4", SpotifyID: "track-4", Value: "2"}},
+		},
+		updates: map[string]map[string]interface{}{},
+		owners: []models.ThumbnailOwner{
+			{EntityType: "Album", ID: "album-1", SpotifyID: "album-spotify-1"},
+			{EntityType: "Artist", ID: "artist-3", SpotifyID: "artist-spotify-3"},
+		},
+	}
+}
+
+func TestDoctor_report(t *testing.T) {
+	db := newMockDatabase()
+	doctor := NewDoctor(db, nil, nil)
+	doctor.Samples = 1
+
+	results, err := doctor.Run(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(Checks) {
+		t.Fatalf("Expected a result per check, got %d", len(results))
+	}
+
+	for _, result := range results {
+		if result.Count != len(db.issues[result.Check.Name]) {
+			t.Errorf("Expected %s to count %d rows, got %d", result.Check.Name, len(db.issues[result.Check.Name]), result.Count)
+		}
+		if result.Count > 0 && len(result.Samples) != 1 {
+			t.Errorf("Expected %s to sample a single row, got %v", result.Check.Name, result.Samples)
+		}
+	}
+
+	if len(db.updates) != 0 || db.commits != 0 {
+		t.Errorf("Expected reporting to change nothing, got %v updates and %d commits", db.updates, db.commits)
+	}
+
+	if _, err := doctor.Run(true); err == nil {
+		t.Errorf("Expected fixing without a resolver to fail")
+	}
+}
+
+func TestDoctor_fix(t *testing.T) {
+	db := newMockDatabase()
+	resolver := mockResolver{
+		models.EntityArtist: {"artist-spotify-1": "artist-1", "artist-spotify-2": "artist-2"},
+		models.EntityAlbum:  {"album-spotify-3": "album-3"},
+	}
+	spotifyAPI := mockAPI{
+		tracks:  `[{"id": "track-3", "album": {"id": "album-spotify-3"}, "artists": [{"id": "artist-spotify-1"}]}]`,
+		albums:  `[{"id": "album-spotify-1", "artists": [{"id": "artist-spotify-2"}], "images": [{"url": "https://i.scdn.co/image/a"}]}]`,
+		artists: `[{"id": "artist-spotify-3", "images": [{"url": "https://i.scdn.co/image/c"}]}]`,
+	}
+	doctor := NewDoctor(db, resolver, spotifyAPI)
+
+	results, err := doctor.Run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"songs/song-1":   "artist-1",
+		"songs/song-3":   "album-3",
+		"albums/album-1": "artist-2",
+		// the unattached thumbnail is served by the album
+		"thumbnails/thumbnail-1": "album-1",
+	}
+	if len(db.updates) != len(expected) {
+		t.Errorf("Expected %d rows repaired, got %v", len(expected), db.updates)
+	}
+	for key, id := range expected {
+		found := false
+		for _, value := range db.updates[key] {
+			found = found || value == id
+		}
+		if !found {
+			t.Errorf("Expected %s to point at %s, got %v", key, id, db.updates[key])
+		}
+	}
+
+	fixed := map[string]int{}
+	for _, result := range results {
+		fixed[result.Check.Name] = result.Fixed
+	}
+	// the thumbnail nothing serves is left in place and not counted
+	if fixed[models.IntegritySongArtistNotUUID] != 1 || fixed[models.IntegrityThumbnailUnattached] != 1 || fixed[models.IntegrityDuplicateSongs] != 0 {
+		t.Errorf("Expected only resolvable rows counted as fixed, got %v", fixed)
+	}
+
+	if db.commits != 1 || db.rollbacks != 0 {
+		t.Errorf("Expected the repairs committed once, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+	}
+}
+
+func TestDoctor_fixShowThumbnail(t *testing.T) {
+	db := &mockDoctorDatabase{
+		issues: map[string][]models.IntegrityIssue{
+			models.IntegrityThumbnailUnattached: {
+				{ID: "thumbnail-1", Value: "https://i.scdn.co/image/show"},
+				{ID: "thumbnail-2", Value: "https://i.scdn.co/image/unknown"},
+			},
+		},
+		updates: map[string]map[string]interface{}{},
+		owners:  []models.ThumbnailOwner{{EntityType: "Show", ID: "show-1", SpotifyID: "show-spotify-1"}},
+	}
+	spotifyAPI := mockAPI{shows: `[{"id": "show-spotify-1", "images": [{"url": "https://i.scdn.co/image/show"}]}]`}
+	doctor := NewDoctor(db, mockResolver{}, spotifyAPI)
+
+	results, err := doctor.Run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[string]interface{}{
+		"thumbnails/thumbnail-1": {"entity_type": "Show", "entity_id": "show-1"},
+	}
+	if !reflect.DeepEqual(db.updates, expected) {
+		t.Errorf("Expected only the show thumbnail reattached, got %v", db.updates)
+	}
+
+	for _, result := range results {
+		if result.Check.Name == models.IntegrityThumbnailUnattached && (result.Count != 2 || result.Fixed != 1) {
+			t.Errorf("Expected 1 of 2 thumbnails fixed, got %d of %d", result.Fixed, result.Count)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"spotify/api"
+	"spotify/blob"
 	"spotify/database"
 	"spotify/metrics"
 	"spotify/utils"
@@ -18,6 +20,16 @@ type SpotifyIngestEnv struct {
 	LogstashAuth metrics.LogstashAuth
 	ElasticAuth  metrics.ElasticAuth
 	Users        []string
+	// ThumbnailStore is where thumbnails get mirrored to, mirroring is skipped when it's empty
+	ThumbnailStore string
+	// ArchiveStore is where raw api responses get archived to, archiving is skipped when it's empty
+	ArchiveStore string
+	// CheckpointDir is where ingest stages checkpoint their outputs so failed runs can be resumed, checkpointing is off
+	// when it's empty. Checkpointed runs commit each stage as it completes rather than the whole run at the end
+	CheckpointDir string
+	// WebhookConfig is the json file listing webhooks to post ingest events to, none are sent when it's empty
+	WebhookConfig string
+	S3Auth        blob.S3Credentials
 }
 
 func LoadEnv(userID string) SpotifyIngestEnv {
@@ -44,19 +56,57 @@ func LoadEnv(userID string) SpotifyIngestEnv {
 		Password: utils.MustGetEnv("elastic_password"),
 	}
 
-	dbAuth := database.DatabaseAuth{
-		User:     utils.MustGetEnv("DB_USER"),
-		IP:       utils.MustGetEnv("DB_IP"),
-		Password: utils.MustGetEnv("DB_PASS"),
-		Port:     utils.MustGetEnv("DB_PORT"),
-		Table:    utils.MustGetEnv("DB_TABLE"),
-	}
-
 	return SpotifyIngestEnv{
 		ApiAuth:      apiAuth,
 		LogstashAuth: logstashAuth,
 		ElasticAuth:  elasticAuth,
-		DbAuth:       dbAuth,
+		DbAuth:       loadDbAuth(),
 		Users:        users,
+
+		ThumbnailStore: os.Getenv("thumbnail_store"),
+		ArchiveStore:   os.Getenv("response_archive"),
+		CheckpointDir:  os.Getenv("checkpoint_dir"),
+		WebhookConfig:  os.Getenv("webhook_config"),
+		S3Auth:         loadS3Auth(),
+	}
+}
+
+// LoadServeEnv loads what the api server needs, which isn't tied to any one user
+func LoadServeEnv() (database.DatabaseAuth, []string) {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	return loadDbAuth(), strings.Split(utils.MustGetEnv("api_keys"), ",")
+}
+
+// LoadUsersEnv loads the database and the users env list, for commands that work across every configured user
+func LoadUsersEnv() (database.DatabaseAuth, []string) {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	return loadDbAuth(), strings.Split(utils.MustGetEnv("users"), ",")
+}
+
+// loadS3Auth reads the optional credentials for an s3:// thumbnail store
+func loadS3Auth() blob.S3Credentials {
+	return blob.S3Credentials{
+		Endpoint:  os.Getenv("s3_endpoint"),
+		Region:    os.Getenv("s3_region"),
+		AccessKey: os.Getenv("s3_access_key"),
+		SecretKey: os.Getenv("s3_secret_key"),
+	}
+}
+
+func loadDbAuth() database.DatabaseAuth {
+	return database.DatabaseAuth{
+		User:     utils.MustGetEnv("DB_USER"),
+		IP:       utils.MustGetEnv("DB_IP"),
+		Password: utils.MustGetEnv("DB_PASS"),
+		Port:     utils.MustGetEnv("DB_PORT"),
+		Table:    utils.MustGetEnv("DB_TABLE"),
 	}
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"spotify/database"
+	"spotify/export"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// exporters stream one dataset for a user and date range into a writer, returning how many rows were written
+var exporters = map[string]struct {
+	sample interface{}
+	export func(db *database.Database, userID string, from utils.Time, to utils.Time, writer export.Writer) (int, error)
+}{
+	"listens": {export.Listen{}, func(db *database.Database, userID string, from utils.Time, to utils.Time, writer export.Writer) (int, error) {
+		count := 0
+		err := db.StreamListenRows(userID, from, to, func(row models.ListenRow) error {
+			count++
+			return writer.Write(export.NewListen(row))
+		})
+		return count, err
+	}},
+	"top-songs": {export.TopSong{}, func(db *database.Database, userID string, from utils.Time, to utils.Time, writer export.Writer) (int, error) {
+		count := 0
+		err := db.StreamTopSongRows(userID, from, to, func(row models.TopSongRow) error {
+			count++
+			return writer.Write(export.NewTopSong(row))
+		})
+		return count, err
+	}},
+	"top-artists": {export.TopArtist{}, func(db *database.Database, userID string, from utils.Time, to utils.Time, writer export.Writer) (int, error) {
+		count := 0
+		err := db.StreamTopArtistRows(userID, from, to, func(row models.TopArtistRow) error {
+			count++
+			return writer.Write(export.NewTopArtist(row))
+		})
+		return count, err
+	}},
+}
+
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	user := flags.String("u", "", "Username whose data to export")
+	dataset := flags.String("d", "listens", "Dataset to export, one of listens, top-songs or top-artists")
+	format := flags.String("f", export.FormatCSV, "Output format, one of csv, jsonl or parquet")
+	from := flags.String("from", "", "Only export listens played (or snapshots taken) on or after this date (YYYY-MM-DD)")
+	to := flags.String("to", "", "Only export listens played (or snapshots taken) before this date (YYYY-MM-DD)")
+	output := flags.String("o", "", "File to write to, defaults to stdout")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	exporter, exists := exporters[*dataset]
+	if !exists {
+		log.Fatalf("Unknown dataset %q!", *dataset)
+	}
+
+	fromTime, toTime := mustParseDateRange(*from, *to)
+
+	var out io.Writer = os.Stdout
+	if *output == "" {
+		silenceLogger()
+	} else {
+		f, err := os.Create(*output)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	writer, err := export.NewWriter(*format, out, exporter.sample)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	defer database.Rollback()
+
+	dbUser, err := database.FetchUserByName(*user)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %s", *user, err.Error())
+	}
+
+	count, err := exporter.export(&database, dbUser.ID, fromTime, toTime, writer)
+	if err != nil {
+		panic(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	logger.Log(fmt.Sprintf("Exported %d %s rows for user %s", count, *dataset, *user), logger.Info)
+}
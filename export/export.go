@@ -0,0 +1,100 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// Writer writes rows of a single row type out in some format, Close must be called to flush anything buffered
+type Writer interface {
+	Write(row interface{}) error
+	Close() error
+}
+
+// NewWriter makes a writer for rows shaped like sample, which must be one of the row structs in this package
+func NewWriter(format string, out io.Writer, sample interface{}) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(out, sample), nil
+	case FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(out)}, nil
+	case FormatParquet:
+		return parquet.NewWriter(out, parquet.SchemaOf(sample)), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(row interface{}) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvWriter names its columns after the rows json tags so csv and jsonl exports line up
+type csvWriter struct {
+	writer      *csv.Writer
+	columns     []string
+	wroteHeader bool
+}
+
+func newCSVWriter(out io.Writer, sample interface{}) *csvWriter {
+	columns := []string{}
+	rowType := reflect.TypeOf(sample)
+	for i := 0; i < rowType.NumField(); i++ {
+		columns = append(columns, strings.Split(rowType.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	return &csvWriter{writer: csv.NewWriter(out), columns: columns}
+}
+
+func (w *csvWriter) Write(row interface{}) error {
+	if !w.wroteHeader {
+		err := w.writer.Write(w.columns)
+		if err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	value := reflect.ValueOf(row)
+	record := make([]string, value.NumField())
+	for i := range record {
+		switch field := value.Field(i).Interface().(type) {
+		case time.Time:
+			record[i] = field.UTC().Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(field)
+		}
+	}
+
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	// an empty export still gets its header
+	if !w.wroteHeader {
+		w.writer.Write(w.columns)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
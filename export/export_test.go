@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestWriters(t *testing.T) {
+	playedAt := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	listens := []Listen{
+		{PlayedAt: playedAt, SongName: "Song, with a comma", SongSpotifyID: "a", AlbumName: "Album", ArtistName: "Artist"},
+		{PlayedAt: playedAt.Add(time.Minute), SongName: "Other", SongSpotifyID: "b", AlbumName: "Album", ArtistName: "Artist"},
+	}
+
+	write := func(format string) []byte {
+		out := bytes.Buffer{}
+		writer, err := NewWriter(format, &out, Listen{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, listen := range listens {
+			err = writer.Write(listen)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		expected := "played_at,song_name,song_spotify_id,album_name,artist_name\n" +
+			"2020-01-01T10:00:00Z,\"Song, with a comma\",a,Album,Artist\n" +
+			"2020-01-01T10:01:00Z,Other,b,Album,Artist\n"
+		if actual := string(write(FormatCSV)); actual != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, actual)
+		}
+	})
+
+	t.Run("JSONL", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(string(write(FormatJSONL))), "\n")
+		if len(lines) != 2 || !strings.Contains(lines[0], `"played_at":"2020-01-01T10:00:00Z"`) {
+			t.Errorf("Unexpected jsonl output %v", lines)
+		}
+	})
+
+	t.Run("Parquet", func(t *testing.T) {
+		data := write(FormatParquet)
+		rows, err := parquet.Read[Listen](bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 2 || rows[0].SongName != listens[0].SongName || !rows[1].PlayedAt.Equal(listens[1].PlayedAt) {
+			t.Errorf("Expected %+v, got %+v", listens, rows)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := NewWriter("xlsx", &bytes.Buffer{}, Listen{})
+		if err == nil {
+			t.Error("Expected an error for an unknown format")
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"spotify/models"
+	"time"
+)
+
+type Listen struct {
+	PlayedAt      time.Time `json:"played_at" parquet:"played_at,timestamp(millisecond)"`
+	SongName      string    `json:"song_name" parquet:"song_name"`
+	SongSpotifyID string    `json:"song_spotify_id" parquet:"song_spotify_id"`
+	AlbumName     string    `json:"album_name" parquet:"album_name"`
+	ArtistName    string    `json:"artist_name" parquet:"artist_name"`
+}
+
+func NewListen(row models.ListenRow) Listen {
+	return Listen{
+		PlayedAt:      row.PlayedAt.Time,
+		SongName:      row.SongName,
+		SongSpotifyID: row.SongSpotifyID,
+		AlbumName:     row.AlbumName,
+		ArtistName:    row.ArtistName,
+	}
+}
+
+type TopSong struct {
+	SnapshotAt    time.Time `json:"snapshot_at" parquet:"snapshot_at,timestamp(millisecond)"`
+	TimePeriod    string    `json:"time_period" parquet:"time_period"`
+	Rank          int       `json:"rank" parquet:"rank"`
+	SongName      string    `json:"song_name" parquet:"song_name"`
+	SongSpotifyID string    `json:"song_spotify_id" parquet:"song_spotify_id"`
+	AlbumName     string    `json:"album_name" parquet:"album_name"`
+	ArtistName    string    `json:"artist_name" parquet:"artist_name"`
+}
+
+func NewTopSong(row models.TopSongRow) TopSong {
+	return TopSong{
+		SnapshotAt:    row.SnapshotAt.Time,
+		TimePeriod:    row.TimePeriod,
+		Rank:          row.Order,
+		SongName:      row.SongName,
+		SongSpotifyID: row.SongSpotifyID,
+		AlbumName:     row.AlbumName,
+		ArtistName:    row.ArtistName,
+	}
+}
+
+type TopArtist struct {
+	SnapshotAt      time.Time `json:"snapshot_at" parquet:"snapshot_at,timestamp(millisecond)"`
+	TimePeriod      string    `json:"time_period" parquet:"time_period"`
+	Rank            int       `json:"rank" parquet:"rank"`
+	ArtistName      string    `json:"artist_name" parquet:"artist_name"`
+	ArtistSpotifyID string    `json:"artist_spotify_id" parquet:"artist_spotify_id"`
+}
+
+func NewTopArtist(row models.TopArtistRow) TopArtist {
+	return TopArtist{
+		SnapshotAt:      row.SnapshotAt.Time,
+		TimePeriod:      row.TimePeriod,
+		Rank:            row.Order,
+		ArtistName:      row.ArtistName,
+		ArtistSpotifyID: row.ArtistSpotifyID,
+	}
+}
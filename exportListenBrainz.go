@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"spotify/models"
+	"spotify/scrobble"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func exportListenBrainzCommand(args []string) {
+	flags := flag.NewFlagSet("export-listenbrainz", flag.ExitOnError)
+	user := flags.String("u", "", "Username whose recent listens to export")
+	from := flags.String("from", "", "Only export listens played on or after this date (YYYY-MM-DD)")
+	to := flags.String("to", "", "Only export listens played before this date (YYYY-MM-DD)")
+	output := flags.String("o", "", "File to write submit-listens payloads to, one per line, defaults to stdout")
+	submit := flags.Bool("submit", false, "Submit the payloads to listenbrainz instead of writing them out")
+	token := flags.String("token", "", "Listenbrainz user token, defaults to listenbrainz_token in env")
+	url := flags.String("url", scrobble.ListenBrainzURL, "Listenbrainz api to submit to")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	fromTime, toTime := mustParseDateRange(*from, *to)
+	if !*submit && *output == "" {
+		silenceLogger()
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	defer database.Rollback()
+
+	dbUser, err := database.FetchUserByName(*user)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %s", *user, err.Error())
+	}
+
+	listens := []scrobble.Listen{}
+	err = database.StreamListenRows(dbUser.ID, fromTime, toTime, func(row models.ListenRow) error {
+		listens = append(listens, scrobble.NewListen(row.PlayedAt.Time, row.ArtistName, row.SongName, row.AlbumName, row.SongSpotifyID))
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	payloads := scrobble.NewImportPayloads(listens)
+	logger.Log(fmt.Sprintf("Exporting %d listens in %d payloads for user %s", len(listens), len(payloads), *user), logger.Info)
+
+	if *submit {
+		if *token == "" {
+			*token = utils.MustGetEnv("listenbrainz_token")
+		}
+
+		submitter := scrobble.NewSubmitter(*url, *token, 5)
+		for i, payload := range payloads {
+			err = submitter.Submit(payload)
+			if err != nil {
+				log.Fatalf("Failed to submit payload %d of %d: %s", i+1, len(payloads), err.Error())
+			}
+		}
+		return
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			panic(err)
+		}
+		defer out.Close()
+	}
+
+	encoder := json.NewEncoder(out)
+	for _, payload := range payloads {
+		err = encoder.Encode(payload)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// mustParseDateRange turns optional YYYY-MM-DD flags into a [from, to) range, open ends cover everything
+func mustParseDateRange(from string, to string) (utils.Time, utils.Time) {
+	fromTime := time.Unix(0, 0).UTC()
+	toTime := time.Now().UTC().AddDate(100, 0, 0)
+
+	var err error
+	if from != "" {
+		fromTime, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			log.Fatalf("Bad from date %q: %s", from, err.Error())
+		}
+	}
+
+	if to != "" {
+		toTime, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			log.Fatalf("Bad to date %q: %s", to, err.Error())
+		}
+	}
+
+	return utils.Time{Time: fromTime}, utils.Time{Time: toTime}
+}
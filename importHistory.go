@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"spotify/ingest"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func importHistoryCommand(args []string) {
+	flags := flag.NewFlagSet("import-history", flag.ExitOnError)
+	user := flags.String("u", "", "Username the streaming history belongs to, must have relevant refresh_token in env")
+	batchSize := flags.Int("b", 500, "Number of history entries to resolve and insert per transaction")
+	minMsPlayed := flags.Int("m", 30000, "Ignore plays shorter than this many milliseconds, spotify only counts a stream after 30 seconds")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	if flags.NArg() == 0 {
+		log.Fatalf("At least one streaming history file or export directory must be given!")
+	}
+
+	files, err := ingest.HistoryFiles(flags.Args())
+	if err != nil {
+		panic(err)
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	spotify, metricHandler := mustBootstrapIngest(env, &database, ingest.SpotifyIngestOptions{UserID: *user, EnvUsers: env.Users})
+	defer metricHandler.Close()
+	database.Commit()
+
+	logger.Log(fmt.Sprintf("Importing %d streaming history files for user %s", len(files), *user), logger.Info)
+	importer := ingest.NewHistoryImporter(&database, &spotify, *batchSize, *minMsPlayed)
+	err = importer.Import(files)
+	if err != nil {
+		metricHandler.AddNewFailure("IMPORT_HISTORY", err)
+		panic(err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"spotify/ingest"
+	"spotify/scrobble"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+var scrobbleParsers = map[string]func(r io.Reader) ([]scrobble.Scrobble, error){
+	"lastfm-json":  scrobble.ParseLastfmJSON,
+	"lastfm-csv":   scrobble.ParseLastfmCSV,
+	"listenbrainz": scrobble.ParseListenBrainz,
+}
+
+func importScrobblesCommand(args []string) {
+	flags := flag.NewFlagSet("import-scrobbles", flag.ExitOnError)
+	user := flags.String("u", "", "Username the scrobbles belong to, must have relevant refresh_token in env")
+	format := flags.String("f", "", "Export format, one of lastfm-json, lastfm-csv or listenbrainz")
+	batchSize := flags.Int("b", 500, "Number of scrobbles to match and insert per transaction")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	parse, exists := scrobbleParsers[*format]
+	if !exists {
+		log.Fatalf("Unknown scrobble format %q!", *format)
+	}
+
+	if flags.NArg() == 0 {
+		log.Fatalf("At least one export file must be given!")
+	}
+
+	scrobbles := []scrobble.Scrobble{}
+	for _, file := range flags.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			panic(err)
+		}
+
+		parsed, err := parse(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to parse %s: %s", file, err.Error())
+		}
+		scrobbles = append(scrobbles, parsed...)
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	spotify, metricHandler := mustBootstrapIngest(env, &database, ingest.SpotifyIngestOptions{UserID: *user, EnvUsers: env.Users})
+	defer metricHandler.Close()
+	database.Commit()
+
+	logger.Log(fmt.Sprintf("Importing %d scrobbles for user %s", len(scrobbles), *user), logger.Info)
+	importer := ingest.NewScrobbleImporter(&database, &spotify, *format, *batchSize)
+	err := importer.Import(scrobbles)
+	if err != nil {
+		metricHandler.AddNewFailure("IMPORT_SCROBBLES", err)
+		panic(err)
+	}
+}
@@ -2,31 +2,57 @@ package ingest
 
 import (
 	"fmt"
-	"spotify/api"
 	"spotify/models"
 	"spotify/utils"
 
 	"github.com/batzz-00/goutils/logger"
 )
 
-func (spotify *SpotifyIngest) PopulateAlbums(songs map[string]api.TopTracksResponse, recents api.RecentlyPlayedResponse) ([]models.Album, error) {
+func (spotify *SpotifyIngest) PopulateAlbums(APIData APIData) ([]models.Album, error) {
 	albumSpotifyIDs := utils.NewStringArgs()
-	for _, resp := range songs {
+	for _, resp := range APIData.Songs {
 		for _, song := range resp.Items {
 			albumSpotifyIDs.Add(song.Album.ID)
 		}
 	}
 
-	for _, song := range recents.Items {
+	for _, song := range APIData.Recents.Items {
 		albumSpotifyIDs.Add(song.Track.Album.ID)
 	}
 
+	for _, saved := range APIData.SavedTracks.Items {
+		albumSpotifyIDs.Add(saved.Track.Album.ID)
+	}
+
+	for _, saved := range APIData.SavedAlbums.Items {
+		albumSpotifyIDs.Add(saved.Album.ID)
+	}
+
+	for _, playlist := range APIData.Playlists {
+		for _, item := range playlist.Items {
+			albumSpotifyIDs.Add(item.Track.Album.ID)
+		}
+	}
+
+	for _, song := range APIData.Tracks {
+		albumSpotifyIDs.Add(song.Album.ID)
+	}
+
+	if len(albumSpotifyIDs.UniqueMap) == 0 {
+		logger.Log("No album references to populate", logger.Debug)
+		return []models.Album{}, nil
+	}
+
 	logger.Log(fmt.Sprintf("Querying database for %d albums", len(albumSpotifyIDs.Args())), logger.Debug)
 	dbAlbums, err := spotify.Database.FetchAlbumsBySpotifyID(albumSpotifyIDs.Args())
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range dbAlbums {
+		spotify.OnNewAlbum(&dbAlbums[i], false)
+	}
+
 	// Songs to attempt to fetch from API
 	albumsToFetch := []string{}
 Outer:
@@ -50,6 +76,10 @@ Outer:
 	}
 
 	for _, album := range apiAlbums {
+		// spotify returns null for ids it no longer knows about
+		if album.ID == "" || len(album.Artists) == 0 {
+			continue
+		}
 		album := models.NewAlbum(album.Name, album.Artists[0].ID, album.ID, true)
 		dbAlbums = append(dbAlbums, album)
 		spotify.OnNewAlbum(&album, true)
@@ -60,25 +90,30 @@ Outer:
 
 func (spotify *SpotifyIngest) AttachAlbumUUIDs(albums []models.Album, artists []models.Artist) error {
 	albumValues := []interface{}{}
+	references := []models.UnresolvedReference{}
 
 	for i, album := range albums {
 		if !album.NeedsUpdate {
 			continue
 		}
-		for _, artist := range artists {
-			// hardcoded 'various artists'
-			if albums[i].ArtistID == "0LyfQWJT6nXafLPZqxe9Of" {
-				albums[i].ArtistID = spotify.Options.VariousArtistsUUID
-			}
 
-			dbArtist, exists := getArtistBySpotifyID(artists, artist.SpotifyID)
-			if !exists {
-				logger.Log(fmt.Sprintf("Failed to attach artist ID for artist %s", artist.Name), logger.Warning)
-			}
+		artistSpotifyID := albums[i].ArtistID
+		dbArtist, exists := getArtistBySpotifyID(artists, artistSpotifyID)
+		switch {
+		// hardcoded 'various artists'
+		case artistSpotifyID == variousArtists:
+			albums[i].ArtistID = spotify.Options.VariousArtistsUUID
+		case exists:
 			albums[i].ArtistID = dbArtist.ID
-			break
+		default:
+			albums[i].ArtistID = ""
+		}
+
+		values := utils.ReflectValues(albums[i])
+		if albums[i].ArtistID == "" {
+			references = append(references, spotify.deferReference(&albums[i], values, albums[i].ID, "artist_id", models.EntityArtist, artistSpotifyID))
 		}
-		albumValues = append(albumValues, utils.ReflectValues(albums[i])...)
+		albumValues = append(albumValues, values...)
 	}
 
 	if len(albumValues) == 0 {
@@ -92,5 +127,5 @@ func (spotify *SpotifyIngest) AttachAlbumUUIDs(albums []models.Album, artists []
 		return err
 	}
 
-	return nil
+	return spotify.insertUnresolvedReferences(references)
 }
@@ -9,10 +9,10 @@ import (
 	"github.com/batzz-00/goutils/logger"
 )
 
-func (spotify *SpotifyIngest) PopulateArtists(songs map[string]api.TopTracksResponse, artists map[string]api.TopArtistsResponse, recents api.RecentlyPlayedResponse) ([]models.Artist, error) {
+func (spotify *SpotifyIngest) PopulateArtists(APIData APIData) ([]models.Artist, error) {
 	// Songs to attempt to fetch from DB
 	artistSpotifyIDs := utils.NewStringArgs()
-	for _, resp := range songs {
+	for _, resp := range APIData.Songs {
 		for _, song := range resp.Items {
 			for _, artist := range song.Artists {
 				artistSpotifyIDs.Add(artist.ID)
@@ -23,13 +23,13 @@ func (spotify *SpotifyIngest) PopulateArtists(songs map[string]api.TopTracksResp
 		}
 	}
 
-	for _, resp := range artists {
+	for _, resp := range APIData.Artists {
 		for _, artist := range resp.Items {
 			artistSpotifyIDs.Add(artist.ID)
 		}
 	}
 
-	for _, song := range recents.Items {
+	for _, song := range APIData.Recents.Items {
 		for _, artist := range song.Track.Album.Artists {
 			artistSpotifyIDs.Add(artist.ID)
 		}
@@ -38,12 +38,60 @@ func (spotify *SpotifyIngest) PopulateArtists(songs map[string]api.TopTracksResp
 		}
 	}
 
+	for _, saved := range APIData.SavedTracks.Items {
+		for _, artist := range saved.Track.Album.Artists {
+			artistSpotifyIDs.Add(artist.ID)
+		}
+		for _, artist := range saved.Track.Artists {
+			artistSpotifyIDs.Add(artist.ID)
+		}
+	}
+
+	for _, saved := range APIData.SavedAlbums.Items {
+		for _, artist := range saved.Album.Artists {
+			artistSpotifyIDs.Add(artist.ID)
+		}
+	}
+
+	for _, artist := range APIData.FollowedArtists.Artists.Items {
+		artistSpotifyIDs.Add(artist.ID)
+	}
+
+	for _, playlist := range APIData.Playlists {
+		for _, item := range playlist.Items {
+			for _, artist := range item.Track.Album.Artists {
+				artistSpotifyIDs.Add(artist.ID)
+			}
+			for _, artist := range item.Track.Artists {
+				artistSpotifyIDs.Add(artist.ID)
+			}
+		}
+	}
+
+	for _, song := range APIData.Tracks {
+		for _, artist := range song.Album.Artists {
+			artistSpotifyIDs.Add(artist.ID)
+		}
+		for _, artist := range song.Artists {
+			artistSpotifyIDs.Add(artist.ID)
+		}
+	}
+
+	if len(artistSpotifyIDs.UniqueMap) == 0 {
+		logger.Log("No artist references to populate", logger.Debug)
+		return []models.Artist{}, nil
+	}
+
 	logger.Log(fmt.Sprintf("Querying database for %d artists", len(artistSpotifyIDs.Args())), logger.Debug)
 	dbArtists, err := spotify.Database.FetchArtistsBySpotifyID(artistSpotifyIDs.Args())
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range dbArtists {
+		spotify.OnNewArtist(&dbArtists[i], false)
+	}
+
 	// Songs to attempt to fetch from API
 	artistsToFetch := []string{}
 Outer:
@@ -68,7 +116,16 @@ Outer:
 	}
 
 	for _, artist := range apiArtists {
+		// spotify returns null for ids it no longer knows about
+		if artist.ID == "" {
+			continue
+		}
 		artistModel := models.NewArtist(artist.Name, artist.ID, true)
+		artistModel.Genres = artist.Genres
+		for _, image := range artist.Images {
+			thumbnail := models.NewThumbnail("Artist", artistModel.ID, image.URL, image.Height, image.Width)
+			artistModel.Thumbnails = append(artistModel.Thumbnails, thumbnail)
+		}
 		dbArtists = append(dbArtists, artistModel)
 		spotify.OnNewArtist(&artistModel, true)
 	}
@@ -81,7 +138,7 @@ func (spotify *SpotifyIngest) Artists() (map[string]api.TopArtistsResponse, erro
 
 	for _, period := range spotify.Times {
 		logger.Log(fmt.Sprintf("Processing %s_term time range for artists endpoint", period), logger.Debug)
-		artists, err := spotify.API.TopArtistsForUser(period + "_term")
+		artists, err := spotify.API.TopArtistsForUser(period+"_term", spotify.topDepth(period))
 		if err != nil {
 			return nil, err
 		}
@@ -92,11 +149,16 @@ func (spotify *SpotifyIngest) Artists() (map[string]api.TopArtistsResponse, erro
 
 func (spotify *SpotifyIngest) InsertArtists(artists []models.Artist) error {
 	artistValues := []interface{}{}
+	genreValues := []interface{}{}
 	for _, artist := range artists {
 		if !artist.NeedsUpdate {
 			continue
 		}
 		artistValues = append(artistValues, utils.ReflectValues(artist)...)
+		for _, genre := range artist.Genres {
+			genre := models.NewArtistGenre(artist.ID, genre)
+			genreValues = append(genreValues, utils.ReflectValues(genre)...)
+		}
 	}
 
 	if len(artistValues) == 0 {
@@ -110,5 +172,15 @@ func (spotify *SpotifyIngest) InsertArtists(artists []models.Artist) error {
 		return err
 	}
 
+	if len(genreValues) == 0 {
+		return nil
+	}
+
+	logger.Log("Inserting new artist genres", logger.Debug)
+	err = spotify.Database.Create(&models.ArtistGenre{}, genreValues)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
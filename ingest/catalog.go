@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/models"
+	"spotify/utils"
+	"strconv"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+var catalogModels = map[string]models.Model{
+	models.EntityArtist: &models.Artist{},
+	models.EntityAlbum:  &models.Album{},
+	models.EntitySong:   &models.Song{},
+}
+
+// catalogFields is what a refresh fetched from spotify for one entity, keyed by column
+type catalogFields map[string]string
+
+// RefreshStaleCatalog re-fetches up to CatalogRefreshBudget artists, albums and songs not updated in CatalogMaxAge,
+// updating any that spotify has since changed and auditing each changed field in entity_changes. Entities spotify
+// no longer returns are only touched so the budget moves on to others
+func (spotify *SpotifyIngest) RefreshStaleCatalog() error {
+	at := utils.NewTime()
+	stale, err := spotify.Database.FetchStaleEntities(utils.Time{Time: at.Add(-spotify.Options.CatalogMaxAge)}, spotify.Options.CatalogRefreshBudget)
+	if err != nil {
+		return err
+	}
+
+	if len(stale) == 0 {
+		logger.Log("No stale catalog entities to refresh", logger.Debug)
+		return nil
+	}
+
+	byType := map[string][]models.StaleEntity{}
+	for _, entity := range stale {
+		byType[entity.EntityType] = append(byType[entity.EntityType], entity)
+	}
+
+	changes := []models.EntityChange{}
+	for _, entityType := range utils.MapOrderedKeys(byType) {
+		entities := byType[entityType]
+		fresh, err := spotify.fetchCatalogFields(entityType, entities)
+		if err != nil {
+			return err
+		}
+
+		for _, entity := range entities {
+			values := map[string]interface{}{}
+			if fields, exists := fresh[entity.SpotifyID]; exists {
+				for _, change := range catalogChanges(entity, fields) {
+					changes = append(changes, change)
+					values[change.Field] = fields.value(change.Field)
+				}
+			}
+
+			err = spotify.Database.UpdateByID(catalogModels[entityType], entity.ID, values, at)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.Log(fmt.Sprintf("Refreshed %d stale catalog entities, %d fields changed", len(stale), len(changes)), logger.Info)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changeValues := []interface{}{}
+	for _, change := range changes {
+		spotify.OnNewEntityEvent(&change)
+		changeValues = append(changeValues, utils.ReflectValues(change)...)
+	}
+	return spotify.Database.Create(&models.EntityChange{}, changeValues)
+}
+
+func (spotify *SpotifyIngest) fetchCatalogFields(entityType string, entities []models.StaleEntity) (map[string]catalogFields, error) {
+	spotifyIDs := []string{}
+	for _, entity := range entities {
+		spotifyIDs = append(spotifyIDs, entity.SpotifyID)
+	}
+
+	fresh := map[string]catalogFields{}
+	switch entityType {
+	case models.EntityArtist:
+		artists, err := spotify.API.ArtistsBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, artist := range artists {
+			fresh[artist.ID] = catalogFields{"name": artist.Name}
+		}
+	case models.EntityAlbum:
+		albums, err := spotify.API.AlbumsBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, album := range albums {
+			fresh[album.ID] = catalogFields{"name": album.Name}
+		}
+	case models.EntitySong:
+		songs, err := spotify.API.TracksBySpotifyID(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			fresh[song.ID] = catalogFields{"name": song.Name, "duration_ms": strconv.Itoa(int(song.DurationMs))}
+		}
+	}
+
+	// spotify returns null for ids it no longer knows, which decode to an empty id
+	delete(fresh, "")
+	return fresh, nil
+}
+
+// value converts a field back to the type its column holds
+func (fields catalogFields) value(column string) interface{} {
+	if column == "duration_ms" {
+		durationMs, _ := strconv.Atoi(fields[column])
+		return durationMs
+	}
+	return fields[column]
+}
+
+func catalogChanges(entity models.StaleEntity, fields catalogFields) []models.EntityChange {
+	current := catalogFields{"name": entity.Name}
+	if entity.EntityType == models.EntitySong {
+		current["duration_ms"] = strconv.Itoa(entity.DurationMs)
+	}
+
+	changes := []models.EntityChange{}
+	for _, column := range utils.MapOrderedKeys(current) {
+		newValue, exists := fields[column]
+		if !exists || newValue == current[column] || newValue == "" {
+			continue
+		}
+		changes = append(changes, models.NewEntityChange(entity.EntityType, entity.ID, column, current[column], newValue))
+	}
+	return changes
+}
+
+// catalogRefreshEnabled is whether the run has a budget to refresh stale entities with
+func (spotify *SpotifyIngest) catalogRefreshEnabled() bool {
+	return spotify.Options.CatalogRefreshBudget > 0 && spotify.Options.CatalogMaxAge > time.Duration(0)
+}
@@ -0,0 +1,43 @@
+package ingest
+
+import (
+	"spotify/models"
+	"testing"
+)
+
+func TestCatalogChanges(t *testing.T) {
+	song := models.StaleEntity{EntityType: models.EntitySong, ID: "1", Name: "Old title", DurationMs: 180000}
+	artist := models.StaleEntity{EntityType: models.EntityArtist, ID: "2", Name: "Artist"}
+
+	tests := []struct {
+		name     string
+		entity   models.StaleEntity
+		fields   catalogFields
+		expected map[string][2]string
+	}{
+		{"Unchanged", song, catalogFields{"name": "Old title", "duration_ms": "180000"}, map[string][2]string{}},
+		{"Retitled and remastered", song, catalogFields{"name": "New title", "duration_ms": "181000"}, map[string][2]string{
+			"name":        {"Old title", "New title"},
+			"duration_ms": {"180000", "181000"},
+		}},
+		{"Empty values are ignored", song, catalogFields{"name": "", "duration_ms": "180000"}, map[string][2]string{}},
+		{"Artists only compare names", artist, catalogFields{"name": "Renamed", "duration_ms": "1"}, map[string][2]string{
+			"name": {"Artist", "Renamed"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := catalogChanges(test.entity, test.fields)
+			if len(changes) != len(test.expected) {
+				t.Fatalf("Expected %d changes, got %+v", len(test.expected), changes)
+			}
+			for _, change := range changes {
+				expected := test.expected[change.Field]
+				if change.EntityID != test.entity.ID || change.OldValue != expected[0] || change.NewValue != expected[1] {
+					t.Errorf("Expected %s to change from %q to %q, got %+v", change.Field, expected[0], expected[1], change)
+				}
+			}
+		})
+	}
+}
@@ -2,4 +2,7 @@ package ingest
 
 const (
 	variousArtists = "0LyfQWJT6nXafLPZqxe9Of"
+
+	// DefaultTopDepth is a single page of spotifys top items
+	DefaultTopDepth = 50
 )
@@ -0,0 +1,191 @@
+package ingest
+
+import (
+	"fmt"
+	"slices"
+	"spotify/api"
+	"spotify/models"
+	"sync"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// Event names, one per event type
+const (
+	EventEntityCreated = "entity_created"
+	EventStageStarted  = "stage_started"
+	EventStageFinished = "stage_finished"
+	EventRunFinished   = "run_finished"
+	EventApiCall       = "api_call"
+	EventWarning       = "warning"
+)
+
+// Event is anything published on the event bus, subscribers switch on the concrete type
+type Event interface {
+	EventName() string
+}
+
+// EntityCreated is published for each entity an ingest handles, New is false for entities that were already stored
+type EntityCreated struct {
+	Model models.Model
+	New   bool
+}
+
+type StageStarted struct {
+	Name string
+}
+
+type StageFinished struct {
+	Timing StageTiming
+}
+
+// RunFinished is published once an ingest is over, Err is set when it failed
+type RunFinished struct {
+	RunID  string
+	UserID string
+	Stats  SpotifyIngestStats
+	Err    error
+}
+
+type ApiCall struct {
+	Method   string
+	URL      string
+	Duration time.Duration
+	BodySize int
+}
+
+type Warning struct {
+	Message string
+}
+
+func (e EntityCreated) EventName() string { return EventEntityCreated }
+func (e StageStarted) EventName() string  { return EventStageStarted }
+func (e StageFinished) EventName() string { return EventStageFinished }
+func (e RunFinished) EventName() string   { return EventRunFinished }
+func (e ApiCall) EventName() string       { return EventApiCall }
+func (e Warning) EventName() string       { return EventWarning }
+
+type Subscriber func(event Event)
+
+type subscription struct {
+	handle Subscriber
+	// queue is nil for subscribers delivered to synchronously
+	queue chan Event
+}
+
+// EventBus delivers published events to every subscriber, in the order they subscribed. A subscriber that panics is
+// logged and skipped, it never takes the ingest down with it. A nil bus drops everything published on it
+type EventBus struct {
+	mutex         sync.RWMutex
+	subscriptions []subscription
+	closed        bool
+	// publishing counts the publishes still enqueueing, Close waits on it before closing the queues
+	publishing sync.WaitGroup
+	wait       sync.WaitGroup
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe delivers events to handle on the publishing goroutine, before Publish returns
+func (bus *EventBus) Subscribe(handle Subscriber) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.subscriptions = append(bus.subscriptions, subscription{handle: handle})
+}
+
+// SubscribeAsync delivers events to handle on its own goroutine through a queue of buffer events, publishing blocks
+// once the queue is full so no events are dropped. Close waits for the queue to drain
+func (bus *EventBus) SubscribeAsync(handle Subscriber, buffer int) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.closed {
+		return
+	}
+
+	queue := make(chan Event, buffer)
+	bus.subscriptions = append(bus.subscriptions, subscription{handle: handle, queue: queue})
+	bus.wait.Add(1)
+	go func() {
+		defer bus.wait.Done()
+		for event := range queue {
+			deliver(handle, event)
+		}
+	}()
+}
+
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+
+	// deliver outside the lock so a subscriber can subscribe, the publish is counted so Close still waits for it
+	bus.mutex.RLock()
+	if bus.closed {
+		bus.mutex.RUnlock()
+		return
+	}
+	bus.publishing.Add(1)
+	defer bus.publishing.Done()
+	subscriptions := slices.Clone(bus.subscriptions)
+	bus.mutex.RUnlock()
+
+	for _, subscription := range subscriptions {
+		if subscription.queue == nil {
+			deliver(subscription.handle, event)
+			continue
+		}
+		subscription.queue <- event
+	}
+}
+
+// Close stops accepting publishes, then waits for the ones already accepted and for async subscribers to handle
+// everything they enqueued
+func (bus *EventBus) Close() {
+	if bus == nil {
+		return
+	}
+
+	bus.mutex.Lock()
+	closing := !bus.closed
+	bus.closed = true
+	subscriptions := slices.Clone(bus.subscriptions)
+	bus.mutex.Unlock()
+
+	if closing {
+		bus.publishing.Wait()
+		for _, subscription := range subscriptions {
+			if subscription.queue != nil {
+				close(subscription.queue)
+			}
+		}
+	}
+	bus.wait.Wait()
+}
+
+func deliver(handle Subscriber, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log(fmt.Sprintf("Event subscriber panicked handling %s, %v", event.EventName(), r), logger.Error)
+		}
+	}()
+	handle(event)
+}
+
+// PublishApiCalls wraps an api metrics handler, publishing each request the api makes as an ApiCall before passing
+// it on to next
+func (bus *EventBus) PublishApiCalls(next api.MetricHandler) api.MetricHandler {
+	return apiCallPublisher{bus: bus, next: next}
+}
+
+type apiCallPublisher struct {
+	bus  *EventBus
+	next api.MetricHandler
+}
+
+func (p apiCallPublisher) AddApiRequestIndex(method string, url string, reqBody string, timeTakenMs int64, bodySize int) error {
+	p.bus.Publish(ApiCall{Method: method, URL: url, Duration: time.Duration(timeTakenMs) * time.Millisecond, BodySize: bodySize})
+	return p.next.AddApiRequestIndex(method, url, reqBody, timeTakenMs, bodySize)
+}
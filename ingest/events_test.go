@@ -0,0 +1,209 @@
+package ingest
+
+import (
+	"errors"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventBus_deliversToEverySubscriber(t *testing.T) {
+	bus := NewEventBus()
+
+	sync := []string{}
+	async := []string{}
+	bus.Subscribe(func(event Event) {
+		panic("subscriber failed")
+	})
+	bus.Subscribe(func(event Event) {
+		sync = append(sync, event.EventName())
+	})
+	bus.SubscribeAsync(func(event Event) {
+		async = append(async, event.EventName())
+	}, 1)
+
+	bus.Publish(StageStarted{Name: "fetch-api"})
+	bus.Publish(Warning{Message: "warning"})
+	bus.Publish(StageFinished{Timing: StageTiming{Name: "fetch-api"}})
+	bus.Close()
+	bus.Publish(Warning{Message: "after close"})
+
+	expected := []string{EventStageStarted, EventWarning, EventStageFinished}
+	for _, received := range [][]string{sync, async} {
+		if len(received) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, received)
+		}
+		for i := range expected {
+			if received[i] != expected[i] {
+				t.Errorf("Expected %v, got %v", expected, received)
+			}
+		}
+	}
+}
+
+func TestEventBus_subscribersCanUseTheBus(t *testing.T) {
+	bus := NewEventBus()
+	bus.Subscribe(func(event Event) {
+		if _, ok := event.(StageStarted); ok {
+			bus.Subscribe(func(event Event) {})
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		bus.Publish(StageStarted{Name: "fetch-api"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a subscriber subscribing not to deadlock")
+	}
+}
+
+func TestEventBus_closeWaitsForFullQueue(t *testing.T) {
+	bus := NewEventBus()
+	release := make(chan struct{})
+	handling := make(chan struct{}, 1)
+	handled := []string{}
+	bus.SubscribeAsync(func(event Event) {
+		handling <- struct{}{}
+		<-release
+		handled = append(handled, event.(StageStarted).Name)
+	}, 1)
+
+	// the first event is being handled and the second fills the queue, so the third blocks on the full queue
+	bus.Publish(StageStarted{Name: "first"})
+	<-handling
+	bus.Publish(StageStarted{Name: "second"})
+	go bus.Publish(StageStarted{Name: "third"})
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-handling:
+			case <-closed:
+				return
+			}
+		}
+	}()
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the bus to close once the queue drained")
+	}
+
+	// the third publish may race Close, but if accepted it must be handled
+	if len(handled) < 2 || handled[0] != "first" || handled[1] != "second" {
+		t.Errorf("Expected the queued events to be handled, got %v", handled)
+	}
+}
+
+func TestEventBus_publishRacingClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		bus := NewEventBus()
+		// the synchronous subscriber counts every publish the bus accepts, each of which the async one must handle
+		var accepted, handled atomic.Int64
+		bus.Subscribe(func(event Event) {
+			accepted.Add(1)
+		})
+		bus.SubscribeAsync(func(event Event) {
+			handled.Add(1)
+		}, 1)
+
+		var publishers sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			publishers.Add(1)
+			go func() {
+				defer publishers.Done()
+				for n := 0; n < 1000; n++ {
+					bus.Publish(StageStarted{Name: "stage"})
+				}
+			}()
+		}
+		// close while the publishers are mid flight
+		for accepted.Load() < 50 {
+			time.Sleep(time.Microsecond)
+		}
+		bus.Close()
+		closedAt := handled.Load()
+		publishers.Wait()
+
+		if handled.Load() != closedAt {
+			t.Fatalf("Expected nothing to be handled after Close returned, got %d more", handled.Load()-closedAt)
+		}
+		if handled.Load() != accepted.Load() {
+			t.Fatalf("Expected every accepted event to be handled, accepted %d and handled %d", accepted.Load(), handled.Load())
+		}
+	}
+}
+
+func TestInsertThumbnails_publishesOnlyInsertedThumbnails(t *testing.T) {
+	db := database.NewMockDatabase()
+	bus := NewEventBus()
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{Events: bus})
+
+	created := []models.Model{}
+	bus.Subscribe(func(event Event) {
+		if e, ok := event.(EntityCreated); ok {
+			created = append(created, e.Model)
+		}
+	})
+
+	artist := models.NewArtist("Artist", "artist-spotify-id", true)
+	thumbnail := models.NewThumbnail("Artist", artist.ID, "https://i.scdn.co/image/artist", 640, 640)
+	artist.Thumbnails = []models.Thumbnail{thumbnail, thumbnail}
+
+	err := spotify.InsertThumbnails(APIData{}, DBData{Artists: []models.Artist{artist}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(created) != 1 {
+		t.Errorf("Expected a single thumbnail to be published, got %d", len(created))
+	}
+}
+
+// unavailableAPI fails the first request an ingest makes
+type unavailableAPI struct {
+	API
+}
+
+func (a unavailableAPI) TopTracksForUser(period string, depth int) (api.TopTracksResponse, error) {
+	return api.TopTracksResponse{}, errors.New("api unavailable")
+}
+
+func TestIngest_publishesRunFinished(t *testing.T) {
+	db := database.NewMockDatabase()
+	bus := NewEventBus()
+	spotify := NewSpotifyIngest(&db, unavailableAPI{}, SpotifyIngestOptions{Events: bus, RunID: "run"})
+
+	var finished *RunFinished
+	bus.Subscribe(func(event Event) {
+		if e, ok := event.(RunFinished); ok {
+			finished = &e
+		}
+	})
+
+	err := spotify.Ingest()
+	if err == nil {
+		t.Fatal("Expected the ingest to fail")
+	}
+
+	if finished == nil || finished.RunID != "run" || finished.Err == nil {
+		t.Errorf("Expected a failed run to be published, got %+v", finished)
+	}
+}
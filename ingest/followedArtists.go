@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func (spotify *SpotifyIngest) FollowedArtists() (api.FollowedArtistsResponse, error) {
+	followedArtists, err := spotify.API.FollowedArtists()
+	if err != nil {
+		return api.FollowedArtistsResponse{}, err
+	}
+
+	return followedArtists, nil
+}
+
+// InsertFollowedArtists diffs the users current follows against the stored ones. Spotify doesn't tell us when an
+// artist was followed, so new follows are stamped with the run that first saw them and missing ones are marked unfollowed
+func (spotify *SpotifyIngest) InsertFollowedArtists(followed api.FollowedArtistsResponse, artists []models.Artist) error {
+	existingFollowedArtists, err := spotify.Database.FetchFollowedArtistsByUserID(spotify.Options.UserID)
+	if err != nil {
+		return err
+	}
+
+	seenAt := utils.NewTime()
+	stillFollowed := make(map[string]bool)
+	followedArtistValues := []interface{}{}
+Outer:
+	for _, followedArtist := range followed.Artists.Items {
+		artist, exists := getArtistBySpotifyID(artists, followedArtist.ID)
+		if !exists {
+			spotify.warn(fmt.Sprintf("Failed to attach artist ID for followed artist %s", followedArtist.Name))
+			continue
+		}
+
+		stillFollowed[artist.ID] = true
+		for _, existingFollowedArtist := range existingFollowedArtists {
+			if existingFollowedArtist.ArtistID == artist.ID {
+				continue Outer
+			}
+		}
+
+		newFollowedArtist := models.NewUserFollowedArtist(spotify.Options.UserID, artist.ID, seenAt.Time)
+		spotify.OnNewEntityEvent(&newFollowedArtist)
+		followedArtistValues = append(followedArtistValues, utils.ReflectValues(newFollowedArtist)...)
+	}
+
+	unfollowedIDs := []interface{}{}
+	for _, existingFollowedArtist := range existingFollowedArtists {
+		if !stillFollowed[existingFollowedArtist.ArtistID] {
+			unfollowedIDs = append(unfollowedIDs, existingFollowedArtist.ID)
+		}
+	}
+
+	if len(unfollowedIDs) > 0 {
+		logger.Log(fmt.Sprintf("Marking %d followed artists as unfollowed", len(unfollowedIDs)), logger.Debug)
+		err = spotify.Database.SetTimeByIDs(&models.UserFollowedArtist{}, "unfollowed_detected", unfollowedIDs, seenAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(followedArtistValues) == 0 {
+		logger.Log("No new followed artist data to ingest", logger.Info)
+		return nil
+	}
+
+	followedArtistRecords := len(followedArtistValues) / len(utils.ReflectColumns(&models.UserFollowedArtist{}))
+	logger.Log(fmt.Sprintf("Inserting %d new user_followed_artists records", followedArtistRecords), logger.Debug)
+	err = spotify.Database.Create(&models.UserFollowedArtist{}, followedArtistValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
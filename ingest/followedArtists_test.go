@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+// mockFollowedArtistsDatabase serves the users current follows and records the follows marked unfollowed
+type mockFollowedArtistsDatabase struct {
+	*database.MockDatabase
+	followed   []models.UserFollowedArtist
+	unfollowed []interface{}
+}
+
+func (db *mockFollowedArtistsDatabase) FetchFollowedArtistsByUserID(userID string) ([]models.UserFollowedArtist, error) {
+	return db.followed, nil
+}
+
+func (db *mockFollowedArtistsDatabase) SetTimeByIDs(model models.Model, column string, ids []interface{}, at utils.Time) error {
+	db.unfollowed = append(db.unfollowed, ids...)
+	return nil
+}
+
+func followedArtists(spotifyIDs ...string) api.FollowedArtistsResponse {
+	followed := api.FollowedArtistsResponse{}
+	for _, id := range spotifyIDs {
+		followed.Artists.Items = append(followed.Artists.Items, api.Artist{ID: id})
+	}
+	return followed
+}
+
+func TestInsertFollowedArtists(t *testing.T) {
+	kept := models.NewArtist("Kept", "kept-spotify-id", false)
+	followed := models.NewArtist("Followed", "followed-spotify-id", false)
+	unfollowed := models.NewArtist("Unfollowed", "unfollowed-spotify-id", false)
+
+	mock := database.NewMockDatabase()
+	db := mockFollowedArtistsDatabase{MockDatabase: &mock, followed: []models.UserFollowedArtist{
+		models.NewUserFollowedArtist("user", kept.ID, time.Now()),
+		models.NewUserFollowedArtist("user", unfollowed.ID, time.Now()),
+	}}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	err := spotify.InsertFollowedArtists(followedArtists("kept-spotify-id", "followed-spotify-id"), []models.Artist{kept, followed, unfollowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := savedColumn(&mock, &models.UserFollowedArtist{}, "artist_id")
+	if !reflect.DeepEqual(inserted, []interface{}{followed.ID}) {
+		t.Errorf("Expected only the new follow to be inserted, got %v", inserted)
+	}
+
+	if !reflect.DeepEqual(db.unfollowed, []interface{}{db.followed[1].ID}) {
+		t.Errorf("Expected only the unfollowed artist to be marked unfollowed, got %v", db.unfollowed)
+	}
+}
+
+func TestInsertFollowedArtists_refollow(t *testing.T) {
+	artist := models.NewArtist("Refollowed", "refollowed-spotify-id", false)
+
+	// the unfollowed row isn't fetched back, only current follows are
+	mock := database.NewMockDatabase()
+	db := mockFollowedArtistsDatabase{MockDatabase: &mock}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	err := spotify.InsertFollowedArtists(followedArtists("refollowed-spotify-id"), []models.Artist{artist})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := savedColumn(&mock, &models.UserFollowedArtist{}, "artist_id")
+	if !reflect.DeepEqual(inserted, []interface{}{artist.ID}) {
+		t.Errorf("Expected the re-follow to be inserted as a new follow, got %v", inserted)
+	}
+
+	unfollowedDetected := savedColumn(&mock, &models.UserFollowedArtist{}, "unfollowed_detected")
+	if !reflect.DeepEqual(unfollowedDetected, []interface{}{nil}) {
+		t.Errorf("Expected the new follow not to be unfollowed, got %v", unfollowedDetected)
+	}
+
+	if len(db.unfollowed) != 0 {
+		t.Errorf("Expected nothing to be marked unfollowed, got %v", db.unfollowed)
+	}
+}
@@ -29,6 +29,24 @@ func getAlbumBySpotifyID(albums []models.Album, spotifyID string) (models.Album,
 	return models.Album{}, false
 }
 
+func getShowBySpotifyID(shows []models.Show, spotifyID string) (models.Show, bool) {
+	for _, show := range shows {
+		if show.SpotifyID == spotifyID {
+			return show, true
+		}
+	}
+	return models.Show{}, false
+}
+
+func getEpisodeBySpotifyID(episodes []models.Episode, spotifyID string) (models.Episode, bool) {
+	for _, episode := range episodes {
+		if episode.SpotifyID == spotifyID {
+			return episode, true
+		}
+	}
+	return models.Episode{}, false
+}
+
 func getThumbnailByEntityIDAndDimensions(thumbnails []models.Thumbnail, entityID string, entityHeight int, entityWidth int) (models.Thumbnail, bool) {
 	for _, thumbnail := range thumbnails {
 		if thumbnail.EntityID == entityID && thumbnail.Height == entityHeight && thumbnail.Width == entityWidth {
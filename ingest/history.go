@@ -0,0 +1,300 @@
+package ingest
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"spotify/models"
+	"spotify/utils"
+	"strings"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	historySource = "history_import"
+	// spotifys export timestamps are to the second and don't quite line up with played_at from the api
+	historyDedupWindow = 10 * time.Second
+)
+
+// HistoryEntry is one play from spotifys extended streaming history export, both the Streaming_History_Audio_*.json
+// and older endsong_*.json files share this shape
+type HistoryEntry struct {
+	Ts              time.Time `json:"ts"`
+	Platform        string    `json:"platform"`
+	MsPlayed        int       `json:"ms_played"`
+	TrackName       string    `json:"master_metadata_track_name"`
+	ArtistName      string    `json:"master_metadata_album_artist_name"`
+	AlbumName       string    `json:"master_metadata_album_album_name"`
+	SpotifyTrackURI string    `json:"spotify_track_uri"`
+	ReasonStart     string    `json:"reason_start"`
+	ReasonEnd       string    `json:"reason_end"`
+	Shuffle         bool      `json:"shuffle"`
+	Skipped         bool      `json:"skipped"`
+	Offline         bool      `json:"offline"`
+}
+
+func (h *HistoryEntry) TrackID() string {
+	return strings.TrimPrefix(h.SpotifyTrackURI, "spotify:track:")
+}
+
+type HistoryDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	FetchRecentListensByUserIDBetween(userID string, from utils.Time, to utils.Time) ([]models.RecentListen, error)
+	FetchHistoryImport(userID string, fileName string) (models.HistoryImport, error)
+	UpdateHistoryImport(historyImport models.HistoryImport) error
+	Commit()
+	Rollback()
+}
+
+// HistoryImporter streams extended streaming history files into recent_listens in batches, committing
+// after each one along with how far through the file it got so a failed import picks up where it left off
+type HistoryImporter struct {
+	database    HistoryDatabase
+	spotify     *SpotifyIngest
+	batchSize   int
+	minMsPlayed int
+}
+
+func NewHistoryImporter(database HistoryDatabase, spotify *SpotifyIngest, batchSize int, minMsPlayed int) HistoryImporter {
+	return HistoryImporter{
+		database:    database,
+		spotify:     spotify,
+		batchSize:   batchSize,
+		minMsPlayed: minMsPlayed,
+	}
+}
+
+// HistoryFiles expands any directories in paths into the streaming history files inside them
+func HistoryFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		for _, pattern := range []string{"Streaming_History_Audio_*.json", "endsong_*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func (h *HistoryImporter) Import(files []string) error {
+	for _, file := range files {
+		err := h.importFile(file)
+		if err != nil {
+			h.database.Rollback()
+			return fmt.Errorf("importing %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *HistoryImporter) importFile(file string) error {
+	fileName := filepath.Base(file)
+	historyImport, err := h.database.FetchHistoryImport(h.spotify.Options.UserID, fileName)
+	if err == sql.ErrNoRows {
+		historyImport = models.NewHistoryImport(h.spotify.Options.UserID, fileName)
+		err = h.database.Create(&models.HistoryImport{}, utils.ReflectValues(historyImport))
+	}
+	if err != nil {
+		return err
+	}
+
+	if historyImport.Completed {
+		logger.Log(fmt.Sprintf("%s has already been imported, skipping", fileName), logger.Info)
+		return nil
+	}
+
+	if historyImport.Processed > 0 {
+		logger.Log(fmt.Sprintf("Resuming %s from entry %d", fileName, historyImport.Processed), logger.Info)
+	} else {
+		logger.Log(fmt.Sprintf("Importing %s", fileName), logger.Info)
+	}
+
+	err = readHistoryFile(file, historyImport.Processed, h.batchSize, func(batch []HistoryEntry, processed int) error {
+		err := h.importBatch(batch)
+		if err != nil {
+			return err
+		}
+
+		historyImport.Processed = processed
+		err = h.database.UpdateHistoryImport(historyImport)
+		if err != nil {
+			return err
+		}
+
+		logger.Log(fmt.Sprintf("Imported %d entries of %s", processed, fileName), logger.Debug)
+		h.database.Commit()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	historyImport.Completed = true
+	err = h.database.UpdateHistoryImport(historyImport)
+	if err != nil {
+		return err
+	}
+
+	h.database.Commit()
+	return nil
+}
+
+// readHistoryFile decodes the json array one entry at a time so multi hundred megabyte exports don't have to fit in memory,
+// skipping the first offset entries and handing the rest over in batches along with the running count of entries read
+func readHistoryFile(file string, offset int, batchSize int, handle func(batch []HistoryEntry, processed int) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	_, err = decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	processed := 0
+	batch := []HistoryEntry{}
+	for decoder.More() {
+		entry := HistoryEntry{}
+		err = decoder.Decode(&entry)
+		if err != nil {
+			return err
+		}
+
+		processed++
+		if processed <= offset {
+			continue
+		}
+
+		batch = append(batch, entry)
+		if len(batch) == batchSize {
+			err = handle(batch, processed)
+			if err != nil {
+				return err
+			}
+			batch = []HistoryEntry{}
+		}
+	}
+
+	if len(batch) > 0 {
+		return handle(batch, processed)
+	}
+
+	return nil
+}
+
+func (h *HistoryImporter) importBatch(batch []HistoryEntry) error {
+	entries := []HistoryEntry{}
+	trackSpotifyIDs := utils.NewStringArgs()
+	for _, entry := range batch {
+		// podcast episodes and local files have no track uri
+		if !strings.HasPrefix(entry.SpotifyTrackURI, "spotify:track:") || entry.MsPlayed < h.minMsPlayed {
+			continue
+		}
+		entries = append(entries, entry)
+		trackSpotifyIDs.Add(entry.TrackID())
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	songs, err := h.spotify.ResolveTracks(trackSpotifyIDs)
+	if err != nil {
+		return err
+	}
+
+	from, to := entries[0].Ts, entries[0].Ts
+	for _, entry := range entries {
+		if entry.Ts.Before(from) {
+			from = entry.Ts
+		}
+		if entry.Ts.After(to) {
+			to = entry.Ts
+		}
+	}
+
+	existingRecentListens, err := h.database.FetchRecentListensByUserIDBetween(h.spotify.Options.UserID, utils.Time{Time: from.Add(-historyDedupWindow)}, utils.Time{Time: to.Add(historyDedupWindow)})
+	if err != nil {
+		return err
+	}
+
+	recentListenValues := []interface{}{}
+	detailValues := []interface{}{}
+	for _, entry := range entries {
+		song, exists := getSongBySpotifyID(songs, entry.TrackID())
+		if !exists {
+			h.spotify.warn(fmt.Sprintf("Failed to attach song ID for %s (%s)", entry.TrackName, entry.SpotifyTrackURI))
+			continue
+		}
+
+		if isDuplicateListen(existingRecentListens, song.ID, entry.Ts, historyDedupWindow) {
+			logger.Log(fmt.Sprintf("Already have a listen of %s at %s", entry.TrackName, entry.Ts.Format(time.RFC3339)), logger.Trace)
+			continue
+		}
+
+		recentListen := models.NewRecentListen(song.ID, h.spotify.Options.UserID, entry.Ts)
+		existingRecentListens = append(existingRecentListens, recentListen)
+		h.spotify.OnNewEntityEvent(&recentListen)
+		recentListenValues = append(recentListenValues, utils.ReflectValues(recentListen)...)
+
+		detail := models.NewRecentListenDetail(recentListen.ID, entry.MsPlayed, entry.ReasonStart, entry.ReasonEnd, entry.Skipped, entry.Shuffle, entry.Offline, entry.Platform, historySource)
+		detailValues = append(detailValues, utils.ReflectValues(detail)...)
+	}
+
+	if len(recentListenValues) == 0 {
+		logger.Log("No new listens in batch", logger.Debug)
+		return nil
+	}
+
+	recentListenRecords := len(recentListenValues) / len(utils.ReflectColumns(&models.RecentListen{}))
+	logger.Log(fmt.Sprintf("Inserting %d new recent_listen records", recentListenRecords), logger.Debug)
+	err = h.database.Create(&models.RecentListen{}, recentListenValues)
+	if err != nil {
+		return err
+	}
+
+	return h.database.Create(&models.RecentListenDetail{}, detailValues)
+}
+
+// isDuplicateListen checks for a listen at exactly the same time, or of the same song within window
+func isDuplicateListen(recentListens []models.RecentListen, songID string, playedAt time.Time, window time.Duration) bool {
+	for _, recentListen := range recentListens {
+		if !recentListen.PlayedAt.Equal(playedAt) && recentListen.SongID != songID {
+			continue
+		}
+
+		gap := recentListen.PlayedAt.Sub(playedAt)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap <= window {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Streaming_History_Audio_2020.json")
+	history := `[
+		{"ts": "2020-01-01T10:00:00Z", "ms_played": 180000, "spotify_track_uri": "spotify:track:a"},
+		{"ts": "2020-01-01T10:03:00Z", "ms_played": 2000, "spotify_track_uri": "spotify:track:b", "skipped": true},
+		{"ts": "2020-01-01T10:06:00Z", "ms_played": 170000, "spotify_track_uri": "spotify:track:c", "skipped": null},
+		{"ts": "2020-01-01T10:09:00Z", "ms_played": 160000, "spotify_track_uri": null}
+	]`
+	err := os.WriteFile(file, []byte(history), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		offset    int
+		batchSize int
+		expected  [][]string
+		processed []int
+	}{
+		{"Whole file in batches", 0, 3, [][]string{{"a", "b", "c"}, {""}}, []int{3, 4}},
+		{"Resumed part way through", 2, 3, [][]string{{"c", ""}}, []int{4}},
+		{"Resumed at the end", 4, 3, [][]string{}, []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			batches := [][]string{}
+			processed := []int{}
+			err := readHistoryFile(file, test.offset, test.batchSize, func(batch []HistoryEntry, count int) error {
+				ids := []string{}
+				for _, entry := range batch {
+					ids = append(ids, entry.TrackID())
+				}
+				batches = append(batches, ids)
+				processed = append(processed, count)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(batches) != len(test.expected) {
+				t.Fatalf("Expected batches %v, got %v", test.expected, batches)
+			}
+			for i := range batches {
+				if len(batches[i]) != len(test.expected[i]) || processed[i] != test.processed[i] {
+					t.Fatalf("Expected batches %v (processed %v), got %v (processed %v)", test.expected, test.processed, batches, processed)
+				}
+				for j := range batches[i] {
+					if batches[i][j] != test.expected[i][j] {
+						t.Errorf("Expected batches %v, got %v", test.expected, batches)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// Outputs a stage can declare, each is a part of the pipeline state that gets checkpointed once the stage producing
+// it succeeds
+const (
+	OutputAPIData = "api_data"
+	OutputDBData  = "db_data"
+)
+
+// PipelineState is the data handed from one stage to the next
+type PipelineState struct {
+	APIData APIData
+	DBData  DBData
+}
+
+// output returns a pointer to the named part of the state, to checkpoint from or restore into
+func (state *PipelineState) output(name string) (interface{}, error) {
+	switch name {
+	case OutputAPIData:
+		return &state.APIData, nil
+	case OutputDBData:
+		return &state.DBData, nil
+	}
+	return nil, fmt.Errorf("unknown pipeline output %s", name)
+}
+
+// Stage is one named step of the ingest, it reads its inputs from the state and writes its outputs back to it
+type Stage struct {
+	Name    string
+	Inputs  []string
+	Outputs []string
+	Run     func(state *PipelineState) error
+}
+
+// StageTiming is how long a stage took, resumed stages were restored from a checkpoint instead of being run
+type StageTiming struct {
+	Name     string
+	Duration time.Duration
+	Resumed  bool
+	Err      string
+}
+
+// Stages is the ingest pipeline, run in order
+func (spotify *SpotifyIngest) Stages() []Stage {
+	return []Stage{
+		{
+			Name:    "fetch-api",
+			Outputs: []string{OutputAPIData},
+			Run: func(state *PipelineState) error {
+				logger.Log("Fetching user data from spotify API", logger.Info)
+				var err error
+				state.APIData, err = spotify.FetchAPIData()
+				return err
+			},
+		},
+		{
+			Name:    "fetch-related",
+			Inputs:  []string{OutputAPIData},
+			Outputs: []string{OutputDBData},
+			Run: func(state *PipelineState) error {
+				logger.Log("Fetching related data for songs, albums etc from database and then the spotify API if we don't already have it", logger.Info)
+				var err error
+				state.DBData, err = spotify.FetchRelated(state.APIData)
+				return err
+			},
+		},
+		{
+			Name:    "attach-insert",
+			Inputs:  []string{OutputAPIData, OutputDBData},
+			Outputs: []string{OutputDBData},
+			Run: func(state *PipelineState) error {
+				logger.Log("Attaching appropriate UUIDs based on spotify ID, and inserting any missed data freshly gathered from spotify API", logger.Info)
+				var err error
+				state.DBData, err = spotify.AttachAndInsertFreshData(state.APIData, state.DBData)
+				return err
+			},
+		},
+		{
+			Name:    "fetch-existing-listens",
+			Inputs:  []string{OutputAPIData, OutputDBData},
+			Outputs: []string{OutputDBData},
+			Run: func(state *PipelineState) error {
+				logger.Log("Fetching all existing recently listened to songs", logger.Info)
+				var err error
+				state.DBData.RecentListens, err = spotify.FetchExistingRecentListens(state.APIData.Recents)
+				if err != nil {
+					logger.Log("Failed to fetch recently listened to songs from the database", logger.Error)
+				}
+				return err
+			},
+		},
+		{
+			Name:   "insert-user-data",
+			Inputs: []string{OutputAPIData, OutputDBData},
+			Run: func(state *PipelineState) error {
+				logger.Log("Prefetch and insert phase complete, moving on to user data insert", logger.Info)
+				err := spotify.InsertUserData(state.APIData, state.DBData)
+				if err != nil {
+					logger.Log("Failed to insert user data", logger.Error)
+				}
+				return err
+			},
+		},
+		{
+			Name: "refresh-catalog",
+			Run: func(state *PipelineState) error {
+				if !spotify.catalogRefreshEnabled() {
+					return nil
+				}
+
+				logger.Log("Refreshing stale catalog entities", logger.Info)
+				err := spotify.RefreshStaleCatalog()
+				if err != nil {
+					logger.Log("Failed to refresh stale catalog entities", logger.Error)
+				}
+				return err
+			},
+		},
+	}
+}
+
+// RunStages runs each stage in order. With checkpoints, each stage is committed and its outputs written to disk as it
+// succeeds, stages the run id already committed are restored instead of run again, so a failed run picks up from the
+// stage that failed
+func (spotify *SpotifyIngest) RunStages(stages []Stage, checkpoints *Checkpoints) error {
+	state := PipelineState{}
+	produced := map[string]bool{}
+	resuming := checkpoints != nil
+
+	// a checkpoint is only trusted once the stage marker committed alongside the stages data is stored, a checkpoint
+	// written before a failed commit belongs to a stage that has to run again
+	committed := map[string]bool{}
+	if checkpoints != nil {
+		committedStages, err := spotify.Database.FetchIngestStagesByRunID(checkpoints.RunID)
+		if err != nil {
+			return err
+		}
+		for _, committedStage := range committedStages {
+			committed[committedStage.Stage] = true
+		}
+	}
+
+	for _, stage := range stages {
+		resuming = resuming && committed[stage.Name]
+		if resuming {
+			restored, err := checkpoints.Load(stage, &state)
+			if err != nil {
+				return fmt.Errorf("%s stage checkpoint: %w", stage.Name, err)
+			}
+			if !restored {
+				return fmt.Errorf("%s stage was committed by run %s but its checkpoint is missing", stage.Name, checkpoints.RunID)
+			}
+
+			logger.Log(fmt.Sprintf("Stage %s already completed by run %s, resuming from its checkpoint", stage.Name, checkpoints.RunID), logger.Info)
+			spotify.onStage(StageTiming{Name: stage.Name, Resumed: true})
+			for _, output := range stage.Outputs {
+				produced[output] = true
+			}
+			continue
+		}
+
+		for _, input := range stage.Inputs {
+			if !produced[input] {
+				return fmt.Errorf("%s stage needs %s, which no earlier stage produced", stage.Name, input)
+			}
+		}
+
+		spotify.Options.Events.Publish(StageStarted{Name: stage.Name})
+		start := time.Now()
+		err := stage.Run(&state)
+		timing := StageTiming{Name: stage.Name, Duration: time.Since(start)}
+		if err != nil {
+			timing.Err = err.Error()
+		}
+		spotify.onStage(timing)
+		if err != nil {
+			if checkpoints != nil {
+				logger.Log(fmt.Sprintf("Stage %s failed, resume the run with -resume %s", stage.Name, checkpoints.RunID), logger.Error)
+			}
+			return fmt.Errorf("%s stage: %w", stage.Name, err)
+		}
+
+		for _, output := range stage.Outputs {
+			produced[output] = true
+		}
+
+		if checkpoints == nil {
+			continue
+		}
+
+		// the checkpoint is written before the commit, if the commit never happens the missing marker reruns the stage
+		marker := models.NewIngestStage(checkpoints.RunID, stage.Name)
+		err = spotify.Database.Create(&marker, utils.ReflectValues(marker))
+		if err != nil {
+			return fmt.Errorf("%s stage marker: %w", stage.Name, err)
+		}
+
+		err = checkpoints.Save(stage, &state)
+		if err != nil {
+			return fmt.Errorf("%s stage checkpoint: %w", stage.Name, err)
+		}
+		spotify.Database.Commit()
+	}
+
+	if checkpoints != nil {
+		return checkpoints.Clear()
+	}
+	return nil
+}
+
+func (spotify *SpotifyIngest) onStage(timing StageTiming) {
+	spotify.Stats.Stages = append(spotify.Stats.Stages, timing)
+	spotify.Options.Events.Publish(StageFinished{Timing: timing})
+}
+
+// Checkpoints stores the outputs of each completed stage of a run, one json file per stage under Dir/RunID
+type Checkpoints struct {
+	Dir   string
+	RunID string
+}
+
+func NewCheckpoints(dir string, runID string) Checkpoints {
+	return Checkpoints{
+		Dir:   dir,
+		RunID: runID,
+	}
+}
+
+func (c *Checkpoints) path(stage Stage) string {
+	return filepath.Join(c.Dir, c.RunID, stage.Name+".json")
+}
+
+// Save writes the stages outputs, through a temporary file so a crash mid write doesn't leave a partial checkpoint
+func (c *Checkpoints) Save(stage Stage, state *PipelineState) error {
+	outputs := map[string]interface{}{}
+	for _, name := range stage.Outputs {
+		output, err := state.output(name)
+		if err != nil {
+			return err
+		}
+		outputs[name] = output
+	}
+
+	bytes, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Join(c.Dir, c.RunID), 0755)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(stage)
+	err = os.WriteFile(path+".tmp", bytes, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Load restores the stages outputs into state, returning false when the stage has no checkpoint
+func (c *Checkpoints) Load(stage Stage, state *PipelineState) (bool, error) {
+	bytes, err := os.ReadFile(c.path(stage))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	outputs := map[string]json.RawMessage{}
+	err = json.Unmarshal(bytes, &outputs)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range stage.Outputs {
+		raw, exists := outputs[name]
+		if !exists {
+			return false, fmt.Errorf("checkpoint is missing %s", name)
+		}
+
+		output, err := state.output(name)
+		if err != nil {
+			return false, err
+		}
+
+		err = json.Unmarshal(raw, output)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Clear removes the runs checkpoints once every stage has completed
+func (c *Checkpoints) Clear() error {
+	return os.RemoveAll(filepath.Join(c.Dir, c.RunID))
+}
@@ -0,0 +1,189 @@
+package ingest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"testing"
+)
+
+func testStages(runs map[string]int, failAt string) []Stage {
+	return []Stage{
+		{
+			Name:    "fetch",
+			Outputs: []string{OutputAPIData},
+			Run: func(state *PipelineState) error {
+				runs["fetch"]++
+				state.APIData.Tracks = []api.Song{{ID: "track-spotify-id"}}
+				return nil
+			},
+		},
+		{
+			Name:   "insert",
+			Inputs: []string{OutputAPIData},
+			Run: func(state *PipelineState) error {
+				runs["insert"]++
+				if failAt == "insert" {
+					return errors.New("insert failed")
+				}
+				if len(state.APIData.Tracks) != 1 || state.APIData.Tracks[0].ID != "track-spotify-id" {
+					return errors.New("expected the fetched track")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// mockStageDatabase keeps the stage markers of the open transaction apart from the committed ones, a rollback drops them
+type mockStageDatabase struct {
+	*database.MockDatabase
+	pending   []models.IngestStage
+	committed []models.IngestStage
+}
+
+func newMockStageDatabase() *mockStageDatabase {
+	mock := database.NewMockDatabase()
+	return &mockStageDatabase{MockDatabase: &mock}
+}
+
+func (db *mockStageDatabase) Create(model models.Model, values []interface{}) error {
+	if model.TableName() == "ingest_stages" {
+		db.pending = append(db.pending, *model.(*models.IngestStage))
+	}
+	return db.MockDatabase.Create(model, values)
+}
+
+func (db *mockStageDatabase) FetchIngestStagesByRunID(runID string) ([]models.IngestStage, error) {
+	return db.committed, nil
+}
+
+func (db *mockStageDatabase) Commit() {
+	db.committed = append(db.committed, db.pending...)
+	db.pending = nil
+}
+
+func (db *mockStageDatabase) Rollback() {
+	db.pending = nil
+}
+
+func TestRunStages_resumesFromFailedStage(t *testing.T) {
+	db := newMockStageDatabase()
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{})
+	checkpoints := NewCheckpoints(t.TempDir(), "run")
+
+	runs := map[string]int{}
+	err := spotify.RunStages(testStages(runs, "insert"), &checkpoints)
+	if err == nil {
+		t.Fatal("Expected the insert stage to fail")
+	}
+
+	if _, err := os.Stat(filepath.Join(checkpoints.Dir, "run", "fetch.json")); err != nil {
+		t.Fatalf("Expected the fetch stage to be checkpointed, %s", err.Error())
+	}
+
+	db.Rollback()
+	resumed := NewSpotifyIngest(db, nil, SpotifyIngestOptions{})
+	err = resumed.RunStages(testStages(runs, ""), &checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runs["fetch"] != 1 || runs["insert"] != 2 {
+		t.Errorf("Expected fetch to run once and insert twice, got %v", runs)
+	}
+
+	if len(resumed.Stats.Stages) != 2 || !resumed.Stats.Stages[0].Resumed || resumed.Stats.Stages[1].Resumed {
+		t.Errorf("Expected only the fetch stage to be resumed, got %+v", resumed.Stats.Stages)
+	}
+
+	if _, err := os.Stat(filepath.Join(checkpoints.Dir, "run")); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoints to be cleared once the run completed")
+	}
+}
+
+func TestRunStages_rerunsStageWhoseCheckpointFailed(t *testing.T) {
+	db := newMockStageDatabase()
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{})
+	checkpoints := NewCheckpoints(t.TempDir(), "run")
+
+	// the insert stages checkpoint can't be written, so its data and marker are rolled back with the failed run
+	runs := map[string]int{}
+	stages := testStages(runs, "")
+	err := os.MkdirAll(filepath.Join(checkpoints.Dir, "run", "insert.json"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spotify.RunStages(stages, &checkpoints)
+	if err == nil {
+		t.Fatal("Expected saving the insert stages checkpoint to fail")
+	}
+	db.Rollback()
+
+	err = os.Remove(filepath.Join(checkpoints.Dir, "run", "insert.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := NewSpotifyIngest(db, nil, SpotifyIngestOptions{})
+	err = resumed.RunStages(testStages(runs, ""), &checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runs["fetch"] != 1 || runs["insert"] != 2 {
+		t.Errorf("Expected fetch to run once and the uncommitted insert twice, got %v", runs)
+	}
+
+	// only the rerun of the insert stage was committed
+	committed := []string{}
+	for _, stage := range db.committed {
+		committed = append(committed, stage.Stage)
+	}
+	if !reflect.DeepEqual(committed, []string{"fetch", "insert"}) {
+		t.Errorf("Expected each stage committed once, got %v", committed)
+	}
+}
+
+func TestRunStages_skipsCheckpointWithoutCommittedMarker(t *testing.T) {
+	db := newMockStageDatabase()
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{})
+	checkpoints := NewCheckpoints(t.TempDir(), "run")
+
+	// a checkpoint left by a run that crashed before committing the stage
+	state := PipelineState{}
+	err := checkpoints.Save(testStages(nil, "")[0], &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := map[string]int{}
+	err = spotify.RunStages(testStages(runs, ""), &checkpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runs["fetch"] != 1 || runs["insert"] != 1 {
+		t.Errorf("Expected both stages to run, got %v", runs)
+	}
+}
+
+func TestRunStages_rejectsMissingInputs(t *testing.T) {
+	db := database.NewMockDatabase()
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{})
+
+	runs := map[string]int{}
+	stages := testStages(runs, "")[1:]
+	err := spotify.RunStages(stages, nil)
+	if err == nil {
+		t.Fatal("Expected a stage without its inputs to fail")
+	}
+
+	if runs["insert"] != 0 {
+		t.Errorf("Expected the insert stage not to run, got %v", runs)
+	}
+}
@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+type PlaylistData struct {
+	Playlist api.Playlist
+	Items    []PlaylistItem
+}
+
+type PlaylistItem struct {
+	api.PlaylistTrack
+	Position int
+}
+
+// Playlists lists the users own playlists and fetches the tracks of every playlist whose snapshot_id
+// has moved on since the last stored snapshot
+func (spotify *SpotifyIngest) Playlists() ([]PlaylistData, error) {
+	playlists, err := spotify.API.Playlists()
+	if err != nil {
+		return nil, err
+	}
+
+	latestSnapshots, err := spotify.Database.FetchLatestPlaylistSnapshotsByUserID(spotify.Options.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	playlistData := []PlaylistData{}
+Outer:
+	for _, playlist := range playlists.Items {
+		if spotify.Options.SpotifyUserID != "" && playlist.Owner.ID != spotify.Options.SpotifyUserID {
+			logger.Log(fmt.Sprintf("Skipping playlist %s, owned by %s", playlist.Name, playlist.Owner.ID), logger.Trace)
+			continue
+		}
+
+		for _, snapshot := range latestSnapshots {
+			if snapshot.SpotifyID == playlist.ID && snapshot.SnapshotID == playlist.SnapshotID {
+				logger.Log(fmt.Sprintf("Playlist %s unchanged since last snapshot, skipping", playlist.Name), logger.Debug)
+				continue Outer
+			}
+		}
+
+		logger.Log(fmt.Sprintf("Fetching tracks for changed playlist %s", playlist.Name), logger.Debug)
+		playlistTracks, err := spotify.API.PlaylistTracks(playlist.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		items := []PlaylistItem{}
+		for i, playlistTrack := range playlistTracks.Items {
+			// local files and podcast episodes can't be resolved to songs
+			if playlistTrack.IsLocal || playlistTrack.Track.ID == "" || playlistTrack.Track.Type != "track" {
+				continue
+			}
+			items = append(items, PlaylistItem{PlaylistTrack: playlistTrack, Position: i})
+		}
+
+		playlistData = append(playlistData, PlaylistData{Playlist: playlist, Items: items})
+	}
+
+	return playlistData, nil
+}
+
+func (spotify *SpotifyIngest) InsertPlaylistSnapshots(playlists []PlaylistData, songs []models.Song) error {
+	snapshotValues := []interface{}{}
+	snapshotTrackValues := []interface{}{}
+
+	for _, playlist := range playlists {
+		snapshot := models.NewPlaylistSnapshot(spotify.Options.UserID, playlist.Playlist.ID, playlist.Playlist.SnapshotID, playlist.Playlist.Name, int(playlist.Playlist.Tracks.Total))
+		spotify.OnNewEntityEvent(&snapshot)
+		snapshotValues = append(snapshotValues, utils.ReflectValues(snapshot)...)
+
+		for _, item := range playlist.Items {
+			song, exists := getSongBySpotifyID(songs, item.Track.ID)
+			if !exists {
+				spotify.warn(fmt.Sprintf("Failed to attach song ID for playlist track %s", item.Track.Name))
+				continue
+			}
+
+			addedAt := utils.NullTime{}
+			if !item.AddedAt.IsZero() {
+				addedAt = utils.NewNullTime(item.AddedAt)
+			}
+
+			snapshotTrack := models.NewPlaylistSnapshotTrack(snapshot.ID, song.ID, item.Position, addedAt)
+			spotify.OnNewEntityEvent(&snapshotTrack)
+			snapshotTrackValues = append(snapshotTrackValues, utils.ReflectValues(snapshotTrack)...)
+		}
+	}
+
+	if len(snapshotValues) == 0 {
+		logger.Log("No changed playlists to ingest", logger.Info)
+		return nil
+	}
+
+	snapshotRecords := len(snapshotValues) / len(utils.ReflectColumns(&models.PlaylistSnapshot{}))
+	logger.Log(fmt.Sprintf("Inserting %d playlist_snapshots records", snapshotRecords), logger.Debug)
+	err := spotify.Database.Create(&models.PlaylistSnapshot{}, snapshotValues)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshotTrackValues) == 0 {
+		return nil
+	}
+
+	// postgres caps a statement at 65535 bindings, big playlists blow through that in one insert
+	colLength := len(utils.ReflectColumns(&models.PlaylistSnapshotTrack{}))
+	for _, chunk := range utils.ChunkSlice(snapshotTrackValues, colLength*1000) {
+		logger.Log(fmt.Sprintf("Inserting %d playlist_snapshot_tracks records", len(chunk)/colLength), logger.Debug)
+		err = spotify.Database.Create(&models.PlaylistSnapshotTrack{}, chunk)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package ingest
+
+import (
+	"fmt"
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"testing"
+)
+
+// playlistAPI serves the users playlists and records which playlists had their tracks fetched
+type playlistAPI struct {
+	API
+	playlists api.PlaylistsResponse
+	fetched   []string
+}
+
+func (a *playlistAPI) Playlists() (api.PlaylistsResponse, error) {
+	return a.playlists, nil
+}
+
+func (a *playlistAPI) PlaylistTracks(playlistID string) (api.PlaylistTracksResponse, error) {
+	a.fetched = append(a.fetched, playlistID)
+	return api.PlaylistTracksResponse{Items: []api.PlaylistTrack{{Track: api.Song{ID: "track", Type: "track"}}}}, nil
+}
+
+// mockPlaylistDatabase serves the latest snapshot of each playlist and counts the inserts made per table
+type mockPlaylistDatabase struct {
+	*database.MockDatabase
+	snapshots []models.PlaylistSnapshot
+	inserts   map[string]int
+}
+
+func newMockPlaylistDatabase() *mockPlaylistDatabase {
+	mock := database.NewMockDatabase()
+	return &mockPlaylistDatabase{MockDatabase: &mock, inserts: map[string]int{}}
+}
+
+func (db *mockPlaylistDatabase) Create(model models.Model, values []interface{}) error {
+	db.inserts[model.TableName()]++
+	return db.MockDatabase.Create(model, values)
+}
+
+func (db *mockPlaylistDatabase) FetchLatestPlaylistSnapshotsByUserID(userID string) ([]models.PlaylistSnapshot, error) {
+	return db.snapshots, nil
+}
+
+func playlist(id string, snapshotID string, ownerID string) api.Playlist {
+	playlist := api.Playlist{ID: id, SnapshotID: snapshotID, Name: id}
+	playlist.Owner.ID = ownerID
+	return playlist
+}
+
+func TestPlaylists_skipsUnchangedAndUnowned(t *testing.T) {
+	db := newMockPlaylistDatabase()
+	db.snapshots = []models.PlaylistSnapshot{
+		models.NewPlaylistSnapshot("user", "unchanged", "snapshot-1", "unchanged", 1),
+		models.NewPlaylistSnapshot("user", "changed", "snapshot-1", "changed", 1),
+	}
+	spotifyAPI := &playlistAPI{playlists: api.PlaylistsResponse{Items: []api.Playlist{
+		playlist("unchanged", "snapshot-1", "me"),
+		playlist("changed", "snapshot-2", "me"),
+		playlist("new", "snapshot-1", "me"),
+		playlist("followed", "snapshot-1", "someone-else"),
+	}}}
+	spotify := NewSpotifyIngest(db, spotifyAPI, SpotifyIngestOptions{UserID: "user", SpotifyUserID: "me"})
+
+	playlists, err := spotify.Playlists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"changed", "new"}
+	if !reflect.DeepEqual(spotifyAPI.fetched, expected) {
+		t.Errorf("Expected only tracks of %v to be fetched, got %v", expected, spotifyAPI.fetched)
+	}
+	if len(playlists) != len(expected) {
+		t.Errorf("Expected %d playlists to be snapshotted, got %d", len(expected), len(playlists))
+	}
+}
+
+func TestInsertPlaylistSnapshots_chunksTracks(t *testing.T) {
+	songs := []models.Song{}
+	items := []PlaylistItem{}
+	for i := 0; i < 2500; i++ {
+		song := models.NewSong("Song", fmt.Sprintf("track-%d", i), "album", "artist", 180000, false)
+		songs = append(songs, song)
+		items = append(items, PlaylistItem{PlaylistTrack: api.PlaylistTrack{Track: api.Song{ID: song.SpotifyID}}, Position: i})
+	}
+
+	db := newMockPlaylistDatabase()
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+	err := spotify.InsertPlaylistSnapshots([]PlaylistData{{Playlist: playlist("big", "snapshot-1", "me"), Items: items}}, songs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.inserts["playlist_snapshots"] != 1 {
+		t.Errorf("Expected a single snapshot insert, got %d", db.inserts["playlist_snapshots"])
+	}
+
+	// 2500 tracks go in as 1000, 1000 then 500 rows
+	if db.inserts["playlist_snapshot_tracks"] != 3 {
+		t.Fatalf("Expected the tracks to be inserted in 3 chunks, got %d", db.inserts["playlist_snapshot_tracks"])
+	}
+	if positions := savedColumn(db.MockDatabase, &models.PlaylistSnapshotTrack{}, "position"); len(positions) != 500 || positions[499] != 2499 {
+		t.Errorf("Expected the last chunk to hold the final 500 tracks in order, got %d rows", len(positions))
+	}
+}
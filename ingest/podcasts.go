@@ -0,0 +1,212 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func (spotify *SpotifyIngest) SavedShows() (api.SavedShowsResponse, error) {
+	savedShows, err := spotify.API.SavedShows()
+	if err != nil {
+		return api.SavedShowsResponse{}, err
+	}
+
+	return savedShows, nil
+}
+
+func (spotify *SpotifyIngest) SavedEpisodes() (api.SavedEpisodesResponse, error) {
+	savedEpisodes, err := spotify.API.SavedEpisodes()
+	if err != nil {
+		return api.SavedEpisodesResponse{}, err
+	}
+
+	return savedEpisodes, nil
+}
+
+// PopulateShows fetches the referenced shows from the database. Shows come back whole from every endpoint
+// that references them, so missing ones are built from the response instead of asking the api again
+func (spotify *SpotifyIngest) PopulateShows(APIData APIData) ([]models.Show, error) {
+	apiShows := make(map[string]api.Show)
+	for _, saved := range APIData.SavedShows.Items {
+		apiShows[saved.Show.ID] = saved.Show
+	}
+
+	for _, saved := range APIData.SavedEpisodes.Items {
+		apiShows[saved.Episode.Show.ID] = saved.Episode.Show
+	}
+
+	for _, episode := range APIData.Episodes {
+		apiShows[episode.Show.ID] = episode.Show
+	}
+
+	if len(apiShows) == 0 {
+		logger.Log("No show references to populate", logger.Debug)
+		return []models.Show{}, nil
+	}
+
+	showSpotifyIDs := utils.NewStringArgs()
+	for id := range apiShows {
+		showSpotifyIDs.Add(id)
+	}
+
+	logger.Log(fmt.Sprintf("Querying database for %d shows", len(showSpotifyIDs.Args())), logger.Debug)
+	dbShows, err := spotify.Database.FetchShowsBySpotifyID(showSpotifyIDs.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range utils.MapOrderedKeys(apiShows) {
+		if _, exists := getShowBySpotifyID(dbShows, id); exists {
+			continue
+		}
+
+		show := models.NewShow(apiShows[id].Name, apiShows[id].Publisher, id, true)
+		spotify.OnNewEntityEvent(&show)
+		dbShows = append(dbShows, show)
+	}
+
+	return dbShows, nil
+}
+
+func (spotify *SpotifyIngest) PopulateEpisodes(APIData APIData) ([]models.Episode, error) {
+	apiEpisodes := make(map[string]api.Episode)
+	for _, saved := range APIData.SavedEpisodes.Items {
+		apiEpisodes[saved.Episode.ID] = saved.Episode
+	}
+
+	for _, episode := range APIData.Episodes {
+		apiEpisodes[episode.ID] = episode
+	}
+
+	if len(apiEpisodes) == 0 {
+		logger.Log("No episode references to populate", logger.Debug)
+		return []models.Episode{}, nil
+	}
+
+	episodeSpotifyIDs := utils.NewStringArgs()
+	for id := range apiEpisodes {
+		episodeSpotifyIDs.Add(id)
+	}
+
+	logger.Log(fmt.Sprintf("Querying database for %d episodes", len(episodeSpotifyIDs.Args())), logger.Debug)
+	dbEpisodes, err := spotify.Database.FetchEpisodesBySpotifyID(episodeSpotifyIDs.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range utils.MapOrderedKeys(apiEpisodes) {
+		if _, exists := getEpisodeBySpotifyID(dbEpisodes, id); exists {
+			continue
+		}
+
+		apiEpisode := apiEpisodes[id]
+		// show id is swapped for the shows uuid in AttachEpisodeUUIDs
+		episode := models.NewEpisode(apiEpisode.Name, apiEpisode.Show.ID, id, int(apiEpisode.DurationMs), apiEpisode.ReleaseDate, true)
+		spotify.OnNewEntityEvent(&episode)
+		dbEpisodes = append(dbEpisodes, episode)
+	}
+
+	return dbEpisodes, nil
+}
+
+func (spotify *SpotifyIngest) InsertShows(shows []models.Show) error {
+	showValues := []interface{}{}
+	for _, show := range shows {
+		if !show.NeedsUpdate {
+			continue
+		}
+		showValues = append(showValues, utils.ReflectValues(show)...)
+	}
+
+	if len(showValues) == 0 {
+		logger.Log("No new show data to ingest", logger.Debug)
+		return nil
+	}
+
+	logger.Log("Inserting new shows", logger.Debug)
+	err := spotify.Database.Create(&models.Show{}, showValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (spotify *SpotifyIngest) AttachEpisodeUUIDs(episodes []models.Episode, shows []models.Show) error {
+	episodeValues := []interface{}{}
+	for i, episode := range episodes {
+		if !episode.NeedsUpdate {
+			continue
+		}
+
+		show, exists := getShowBySpotifyID(shows, episode.ShowID)
+		if !exists {
+			spotify.warn(fmt.Sprintf("Show with spotify ID %s was not fetched from spotify", episode.ShowID))
+			continue
+		}
+		episodes[i].ShowID = show.ID
+
+		episodeValues = append(episodeValues, utils.ReflectValues(episodes[i])...)
+	}
+
+	if len(episodeValues) == 0 {
+		logger.Log("No new episode data to ingest", logger.Debug)
+		return nil
+	}
+
+	episodeRecords := len(episodeValues) / len(utils.ReflectColumns(&models.Episode{}))
+	logger.Log(fmt.Sprintf("Inserting %d new episode records", episodeRecords), logger.Debug)
+	err := spotify.Database.Create(&models.Episode{}, episodeValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertEpisodeResumePoints records a users resume point for each saved episode whenever it has moved since the last one stored
+func (spotify *SpotifyIngest) InsertEpisodeResumePoints(saved api.SavedEpisodesResponse, episodes []models.Episode) error {
+	latestResumePoints, err := spotify.Database.FetchLatestEpisodeResumePointsByUserID(spotify.Options.UserID)
+	if err != nil {
+		return err
+	}
+
+	resumePointValues := []interface{}{}
+Outer:
+	for _, savedEpisode := range saved.Items {
+		episode, exists := getEpisodeBySpotifyID(episodes, savedEpisode.Episode.ID)
+		if !exists {
+			spotify.warn(fmt.Sprintf("Failed to attach episode ID for saved episode %s", savedEpisode.Episode.Name))
+			continue
+		}
+
+		resumePoint := savedEpisode.Episode.ResumePoint
+		for _, latest := range latestResumePoints {
+			if latest.EpisodeID == episode.ID && latest.ResumePositionMs == int(resumePoint.ResumePositionMs) && latest.FullyPlayed == resumePoint.FullyPlayed {
+				continue Outer
+			}
+		}
+
+		newResumePoint := models.NewEpisodeResumePoint(spotify.Options.UserID, episode.ID, int(resumePoint.ResumePositionMs), resumePoint.FullyPlayed)
+		spotify.OnNewEntityEvent(&newResumePoint)
+		resumePointValues = append(resumePointValues, utils.ReflectValues(newResumePoint)...)
+	}
+
+	if len(resumePointValues) == 0 {
+		logger.Log("No changed episode resume points to ingest", logger.Info)
+		return nil
+	}
+
+	resumePointRecords := len(resumePointValues) / len(utils.ReflectColumns(&models.EpisodeResumePoint{}))
+	logger.Log(fmt.Sprintf("Inserting %d episode_resume_points records", resumePointRecords), logger.Debug)
+	err = spotify.Database.Create(&models.EpisodeResumePoint{}, resumePointValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"testing"
+)
+
+func savedEpisode(id string, showID string, resumePositionMs float64, fullyPlayed bool) api.SavedEpisode {
+	episode := api.Episode{ID: id, Name: id, DurationMs: 3600000, Show: api.Show{ID: showID, Name: showID}}
+	episode.ResumePoint.ResumePositionMs = resumePositionMs
+	episode.ResumePoint.FullyPlayed = fullyPlayed
+	return api.SavedEpisode{Episode: episode}
+}
+
+// mockPodcastDatabase serves the stored shows, episodes and resume points
+type mockPodcastDatabase struct {
+	*database.MockDatabase
+	shows        []models.Show
+	episodes     []models.Episode
+	resumePoints []models.EpisodeResumePoint
+}
+
+func newMockPodcastDatabase() *mockPodcastDatabase {
+	mock := database.NewMockDatabase()
+	return &mockPodcastDatabase{MockDatabase: &mock}
+}
+
+func (db *mockPodcastDatabase) FetchShowsBySpotifyID(spotifyIDs []interface{}) ([]models.Show, error) {
+	return db.shows, nil
+}
+
+func (db *mockPodcastDatabase) FetchEpisodesBySpotifyID(spotifyIDs []interface{}) ([]models.Episode, error) {
+	return db.episodes, nil
+}
+
+func (db *mockPodcastDatabase) FetchLatestEpisodeResumePointsByUserID(userID string) ([]models.EpisodeResumePoint, error) {
+	return db.resumePoints, nil
+}
+
+func TestPodcasts_ingest(t *testing.T) {
+	storedShow := models.NewShow("Stored show", "Publisher", "stored-show", false)
+	storedEpisode := models.NewEpisode("Stored episode", storedShow.ID, "stored-episode", 3600000, "2024-01-01", false)
+
+	db := newMockPodcastDatabase()
+	db.shows = []models.Show{storedShow}
+	db.episodes = []models.Episode{storedEpisode}
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	APIData := APIData{}
+	APIData.SavedShows.Items = []api.SavedShow{{Show: api.Show{ID: "stored-show"}}}
+	APIData.SavedEpisodes.Items = []api.SavedEpisode{
+		savedEpisode("stored-episode", "stored-show", 0, false),
+		savedEpisode("new-episode", "new-show", 0, false),
+	}
+
+	shows, err := spotify.PopulateShows(APIData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shows) != 2 || shows[0].ID != storedShow.ID || shows[1].SpotifyID != "new-show" || !shows[1].NeedsUpdate {
+		t.Fatalf("Expected the stored show and a new show, got %+v", shows)
+	}
+
+	episodes, err := spotify.PopulateEpisodes(APIData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(episodes) != 2 || episodes[0].ID != storedEpisode.ID || episodes[1].SpotifyID != "new-episode" || !episodes[1].NeedsUpdate {
+		t.Fatalf("Expected the stored episode and a new episode, got %+v", episodes)
+	}
+
+	err = spotify.InsertShows(shows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted := savedColumn(db.MockDatabase, &models.Show{}, "id"); !reflect.DeepEqual(inserted, []interface{}{shows[1].ID}) {
+		t.Errorf("Expected only the new show to be inserted, got %v", inserted)
+	}
+
+	err = spotify.AttachEpisodeUUIDs(episodes, shows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted := savedColumn(db.MockDatabase, &models.Episode{}, "show_id"); !reflect.DeepEqual(inserted, []interface{}{shows[1].ID}) {
+		t.Errorf("Expected the new episode to be inserted against the new shows id, got %v", inserted)
+	}
+}
+
+func TestInsertEpisodeResumePoints(t *testing.T) {
+	unchanged := models.NewEpisode("Unchanged", "show", "unchanged-episode", 3600000, "2024-01-01", false)
+	moved := models.NewEpisode("Moved", "show", "moved-episode", 3600000, "2024-01-01", false)
+	finished := models.NewEpisode("Finished", "show", "finished-episode", 3600000, "2024-01-01", false)
+	started := models.NewEpisode("Started", "show", "started-episode", 3600000, "2024-01-01", false)
+
+	db := newMockPodcastDatabase()
+	db.resumePoints = []models.EpisodeResumePoint{
+		models.NewEpisodeResumePoint("user", unchanged.ID, 1000, false),
+		models.NewEpisodeResumePoint("user", moved.ID, 1000, false),
+		models.NewEpisodeResumePoint("user", finished.ID, 3600000, false),
+	}
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	saved := api.SavedEpisodesResponse{Items: []api.SavedEpisode{
+		savedEpisode("unchanged-episode", "show", 1000, false),
+		savedEpisode("moved-episode", "show", 2000, false),
+		savedEpisode("finished-episode", "show", 3600000, true),
+		savedEpisode("started-episode", "show", 500, false),
+		savedEpisode("unknown-episode", "show", 500, false),
+	}}
+	err := spotify.InsertEpisodeResumePoints(saved, []models.Episode{unchanged, moved, finished, started})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := savedColumn(db.MockDatabase, &models.EpisodeResumePoint{}, "episode_id")
+	expected := []interface{}{moved.ID, finished.ID, started.ID}
+	if !reflect.DeepEqual(inserted, expected) {
+		t.Errorf("Expected resume points for %v, got %v", expected, inserted)
+	}
+}
+
+// mockLookupDatabase counts the catalog lookups made against it
+type mockLookupDatabase struct {
+	*database.MockDatabase
+	lookups int
+}
+
+func (db *mockLookupDatabase) FetchAlbumsBySpotifyID(spotifyIDs []interface{}) ([]models.Album, error) {
+	db.lookups++
+	return nil, nil
+}
+
+func (db *mockLookupDatabase) FetchArtistsBySpotifyID(spotifyIDs []interface{}) ([]models.Artist, error) {
+	db.lookups++
+	return nil, nil
+}
+
+func (db *mockLookupDatabase) FetchSongsBySpotifyID(spotifyIDs []interface{}) ([]models.Song, error) {
+	db.lookups++
+	return nil, nil
+}
+
+func TestPopulate_noReferences(t *testing.T) {
+	mock := database.NewMockDatabase()
+	db := mockLookupDatabase{MockDatabase: &mock}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{})
+
+	// a podcast only run references no music at all
+	APIData := APIData{}
+	APIData.SavedEpisodes.Items = []api.SavedEpisode{savedEpisode("episode", "show", 0, false)}
+
+	albums, err := spotify.PopulateAlbums(APIData)
+	if err != nil || albums == nil || len(albums) != 0 {
+		t.Errorf("Expected no albums, got %v, %v", albums, err)
+	}
+
+	artists, err := spotify.PopulateArtists(APIData)
+	if err != nil || artists == nil || len(artists) != 0 {
+		t.Errorf("Expected no artists, got %v, %v", artists, err)
+	}
+
+	songs, err := spotify.PopulateTracks(APIData)
+	if err != nil || songs == nil || len(songs) != 0 {
+		t.Errorf("Expected no songs, got %v, %v", songs, err)
+	}
+
+	if db.lookups != 0 {
+		t.Errorf("Expected nothing to be looked up, got %d lookups", db.lookups)
+	}
+}
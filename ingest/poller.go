@@ -0,0 +1,298 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	// how close to the end of a track playback has to get (on top of the poll interval) to count as finished
+	finishedSlack = 3 * time.Second
+	// how long a track can sit paused before its session is closed
+	pauseTimeout = 10 * time.Minute
+)
+
+type PollerDatabase interface {
+	FetchPlaybackSessionMatches(userID string) ([]models.PlaybackSessionMatch, error)
+	UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error
+	Create(model models.Model, values []interface{}) error
+	Commit()
+	Rollback()
+}
+
+type playback struct {
+	session models.PlaybackSession
+	itemID  string
+	track   api.Song
+	// episode is set instead of track while a podcast is playing
+	episode         *api.Episode
+	startProgressMs int
+	lastSeenAt      time.Time
+	pausedSince     time.Time
+}
+
+// Poller watches the currently playing endpoint and turns what it sees into playback sessions,
+// which unlike recent listens include skipped and partially played tracks, and episode listens
+type Poller struct {
+	database PollerDatabase
+	spotify  *SpotifyIngest
+	interval time.Duration
+
+	current *playback
+}
+
+func NewPoller(database PollerDatabase, spotify *SpotifyIngest, interval time.Duration) Poller {
+	return Poller{
+		database: database,
+		spotify:  spotify,
+		interval: interval,
+	}
+}
+
+func (p *Poller) Run(ctx context.Context) error {
+	logger.Log(fmt.Sprintf("Polling currently playing every %s", p.interval), logger.Info)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		err := p.Poll(time.Now())
+		if err != nil {
+			logger.Log(fmt.Sprintf("Failed to poll currently playing, %s", err.Error()), logger.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Log("Stopping poller, closing any open playback session", logger.Info)
+			if p.current == nil {
+				return nil
+			}
+			return p.persist([]playback{p.end(models.PlaybackEndedPaused)})
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) Poll(now time.Time) error {
+	currentlyPlaying, err := p.spotify.API.CurrentlyPlaying()
+	if err != nil {
+		badResp := &api.BadRespError{}
+		if errors.As(err, &badResp) && badResp.Code == 401 {
+			logger.Log("Access token expired, refreshing", logger.Info)
+			return p.spotify.API.Refresh()
+		}
+		return err
+	}
+
+	ended := p.observe(currentlyPlaying, now)
+	if len(ended) == 0 {
+		return nil
+	}
+
+	return p.persist(ended)
+}
+
+// Match links playback sessions to the recent listens spotify has since counted for them
+func (p *Poller) Match() error {
+	matched, err := p.matchSessions()
+	if err != nil {
+		p.database.Rollback()
+		return err
+	}
+
+	logger.Log(fmt.Sprintf("Matched %d playback sessions to recent listens", matched), logger.Debug)
+	p.database.Commit()
+	return nil
+}
+
+// matchSessions links each unmatched playback session to the closest recent listen in its window, a listen is only
+// ever claimed by one session even when several sessions of the same track sit next to it
+func (p *Poller) matchSessions() (int, error) {
+	matches, err := p.database.FetchPlaybackSessionMatches(p.spotify.Options.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	matchedSessions, matchedListens := map[string]bool{}, map[string]bool{}
+	at := utils.NewTime()
+	for _, match := range matches {
+		if matchedSessions[match.SessionID] || matchedListens[match.RecentListenID] {
+			continue
+		}
+
+		err = p.database.UpdateByID(&models.PlaybackSession{}, match.SessionID, map[string]interface{}{"recent_listen_id": match.RecentListenID}, at)
+		if err != nil {
+			return len(matchedSessions), err
+		}
+		matchedSessions[match.SessionID] = true
+		matchedListens[match.RecentListenID] = true
+	}
+	return len(matchedSessions), nil
+}
+
+func (p *Poller) observe(currentlyPlaying api.CurrentlyPlayingResponse, now time.Time) []playback {
+	ended := []playback{}
+	itemID, durationMs, playing := playingItem(currentlyPlaying)
+	progress := int(currentlyPlaying.ProgressMs)
+
+	if p.current != nil {
+		switch {
+		case !playing:
+			ended = append(ended, p.end(models.PlaybackEndedPaused))
+		case itemID != p.current.itemID:
+			ended = append(ended, p.end(models.PlaybackEndedSkipped))
+		case progress+int(finishedSlack.Milliseconds()) < p.current.session.ProgressMs:
+			// same track went back to the start, eg. on repeat (or a seek backwards, which we can't tell apart)
+			ended = append(ended, p.end(models.PlaybackEndedSkipped))
+		case !currentlyPlaying.IsPlaying:
+			if p.current.pausedSince.IsZero() {
+				p.current.pausedSince = now
+			}
+			p.current.session.ProgressMs = progress
+			p.current.lastSeenAt = now
+			if now.Sub(p.current.pausedSince) >= pauseTimeout {
+				ended = append(ended, p.end(models.PlaybackEndedPaused))
+			}
+			return ended
+		default:
+			p.current.pausedSince = time.Time{}
+			p.current.session.ProgressMs = progress
+			p.current.lastSeenAt = now
+			return ended
+		}
+	}
+
+	if playing && currentlyPlaying.IsPlaying {
+		current := &playback{
+			itemID:          itemID,
+			startProgressMs: progress,
+			lastSeenAt:      now,
+		}
+
+		// tracks are assumed to have been started from the top, episodes are usually resumed part way through
+		startedAt := now.Add(-time.Duration(progress) * time.Millisecond)
+		if currentlyPlaying.Episode != nil {
+			startedAt = now
+			current.episode = currentlyPlaying.Episode
+		} else {
+			current.track = *currentlyPlaying.Item
+		}
+
+		current.session = models.NewPlaybackSession(p.spotify.Options.UserID, "", startedAt, progress, durationMs)
+		p.current = current
+	}
+
+	return ended
+}
+
+func playingItem(currentlyPlaying api.CurrentlyPlayingResponse) (string, int, bool) {
+	if currentlyPlaying.Item != nil && currentlyPlaying.CurrentlyPlayingType == "track" && currentlyPlaying.Item.ID != "" {
+		return currentlyPlaying.Item.ID, int(currentlyPlaying.Item.DurationMs), true
+	}
+
+	if currentlyPlaying.Episode != nil && currentlyPlaying.Episode.ID != "" {
+		return currentlyPlaying.Episode.ID, int(currentlyPlaying.Episode.DurationMs), true
+	}
+
+	return "", 0, false
+}
+
+// end closes the current session. If the last progress we saw was close enough to the end of the track that it would
+// have finished before the next poll it counts as finished, otherwise it ends for the given reason
+func (p *Poller) end(reason string) playback {
+	ended := *p.current
+	p.current = nil
+
+	remaining := time.Duration(ended.session.DurationMs-ended.session.ProgressMs) * time.Millisecond
+	ended.session.EndedReason = reason
+	ended.session.EndedAt.Time = ended.lastSeenAt
+	if ended.pausedSince.IsZero() && remaining <= p.interval+finishedSlack {
+		ended.session.EndedReason = models.PlaybackEndedFinished
+		ended.session.EndedAt.Time = ended.lastSeenAt.Add(remaining)
+		ended.session.ProgressMs = ended.session.DurationMs
+	}
+
+	return ended
+}
+
+func (p *Poller) persist(ended []playback) error {
+	APIData := APIData{}
+	for _, playback := range ended {
+		if playback.episode != nil {
+			APIData.Episodes = append(APIData.Episodes, *playback.episode)
+			continue
+		}
+		APIData.Tracks = append(APIData.Tracks, playback.track)
+	}
+
+	relatedData, err := p.spotify.FetchRelated(APIData)
+	if err != nil {
+		p.database.Rollback()
+		return err
+	}
+
+	relatedData, err = p.spotify.AttachAndInsertFreshData(APIData, relatedData)
+	if err != nil {
+		p.database.Rollback()
+		return err
+	}
+
+	for _, playback := range ended {
+		if playback.episode != nil {
+			err = p.persistEpisodeListen(playback, relatedData.Episodes)
+			if err != nil {
+				p.database.Rollback()
+				return err
+			}
+			continue
+		}
+
+		song, exists := getSongBySpotifyID(relatedData.Songs, playback.track.ID)
+		if !exists {
+			p.spotify.warn(fmt.Sprintf("Failed to attach song ID for playback of %s, dropping session", playback.track.Name))
+			continue
+		}
+
+		playback.session.SongID = song.ID
+		logger.Log(fmt.Sprintf("Playback of %s %s after %dms", playback.track.Name, playback.session.EndedReason, playback.session.ProgressMs), logger.Debug)
+		p.spotify.OnNewEntityEvent(&playback.session)
+		err = p.database.Create(&models.PlaybackSession{}, utils.ReflectValues(playback.session))
+		if err != nil {
+			p.database.Rollback()
+			return err
+		}
+	}
+
+	_, err = p.matchSessions()
+	if err != nil {
+		p.database.Rollback()
+		return err
+	}
+
+	p.database.Commit()
+	return nil
+}
+
+func (p *Poller) persistEpisodeListen(playback playback, episodes []models.Episode) error {
+	episode, exists := getEpisodeBySpotifyID(episodes, playback.episode.ID)
+	if !exists {
+		p.spotify.warn(fmt.Sprintf("Failed to attach episode ID for playback of %s, dropping listen", playback.episode.Name))
+		return nil
+	}
+
+	listenedMs := playback.session.ProgressMs - playback.startProgressMs
+	if listenedMs < 0 {
+		listenedMs = 0
+	}
+
+	logger.Log(fmt.Sprintf("Listened to %dms of episode %s", listenedMs, playback.episode.Name), logger.Debug)
+	episodeListen := models.NewEpisodeListen(p.spotify.Options.UserID, episode.ID, playback.session.StartedAt.Time, playback.session.EndedAt.Time, listenedMs)
+	p.spotify.OnNewEntityEvent(&episodeListen)
+	return p.database.Create(&models.EpisodeListen{}, utils.ReflectValues(episodeListen))
+}
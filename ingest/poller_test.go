@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+func currentlyPlaying(trackID string, progressMs float64, isPlaying bool) api.CurrentlyPlayingResponse {
+	return api.CurrentlyPlayingResponse{
+		ProgressMs:           progressMs,
+		IsPlaying:            isPlaying,
+		CurrentlyPlayingType: "track",
+		Item:                 &api.Song{ID: trackID, DurationMs: 200000},
+	}
+}
+
+func TestPoller_observe(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tick := func(i int) time.Time { return start.Add(time.Duration(i) * 5 * time.Second) }
+
+	tests := []struct {
+		name     string
+		polls    []api.CurrentlyPlayingResponse
+		expected []string
+	}{
+		{
+			name: "Track played to the end then next track",
+			polls: []api.CurrentlyPlayingResponse{
+				currentlyPlaying("a", 185000, true),
+				currentlyPlaying("a", 190000, true),
+				currentlyPlaying("a", 195000, true),
+				currentlyPlaying("b", 1000, true),
+			},
+			expected: []string{models.PlaybackEndedFinished},
+		},
+		{
+			name: "Track skipped part way through",
+			polls: []api.CurrentlyPlayingResponse{
+				currentlyPlaying("a", 10000, true),
+				currentlyPlaying("a", 15000, true),
+				currentlyPlaying("b", 1000, true),
+			},
+			expected: []string{models.PlaybackEndedSkipped},
+		},
+		{
+			name: "Playback stopped part way through",
+			polls: []api.CurrentlyPlayingResponse{
+				currentlyPlaying("a", 10000, true),
+				{},
+			},
+			expected: []string{models.PlaybackEndedPaused},
+		},
+		{
+			name: "Track repeated",
+			polls: []api.CurrentlyPlayingResponse{
+				currentlyPlaying("a", 195000, true),
+				currentlyPlaying("a", 1000, true),
+			},
+			expected: []string{models.PlaybackEndedFinished},
+		},
+		{
+			name: "Paused track resumed is one session",
+			polls: []api.CurrentlyPlayingResponse{
+				currentlyPlaying("a", 10000, true),
+				currentlyPlaying("a", 12000, false),
+				currentlyPlaying("a", 12000, false),
+				currentlyPlaying("a", 15000, true),
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			poller := NewPoller(nil, &SpotifyIngest{Options: SpotifyIngestOptions{UserID: "123"}}, 5*time.Second)
+			reasons := []string{}
+			for i, poll := range test.polls {
+				for _, ended := range poller.observe(poll, tick(i)) {
+					reasons = append(reasons, ended.session.EndedReason)
+				}
+			}
+
+			if len(reasons) != len(test.expected) {
+				t.Fatalf("Expected ended sessions %v, got %v", test.expected, reasons)
+			}
+			for i := range reasons {
+				if reasons[i] != test.expected[i] {
+					t.Errorf("Expected ended sessions %v, got %v", test.expected, reasons)
+				}
+			}
+		})
+	}
+}
+
+// mockPollerDatabase serves the candidate session matches and records the sessions linked to a listen
+type mockPollerDatabase struct {
+	*database.MockDatabase
+	matches []models.PlaybackSessionMatch
+	linked  map[string]interface{}
+}
+
+func (db *mockPollerDatabase) FetchPlaybackSessionMatches(userID string) ([]models.PlaybackSessionMatch, error) {
+	return db.matches, nil
+}
+
+func (db *mockPollerDatabase) UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error {
+	db.linked[id] = values["recent_listen_id"]
+	return nil
+}
+
+func (db *mockPollerDatabase) Commit() {}
+
+func (db *mockPollerDatabase) Rollback() {}
+
+func TestPoller_matchClaimsEachListenOnce(t *testing.T) {
+	// two sessions of the same track sit next to a single listen, the closer one is returned first
+	mock := database.NewMockDatabase()
+	db := mockPollerDatabase{MockDatabase: &mock, linked: map[string]interface{}{}, matches: []models.PlaybackSessionMatch{
+		{SessionID: "closer-session", RecentListenID: "listen"},
+		{SessionID: "earlier-session", RecentListenID: "listen"},
+		{SessionID: "earlier-session", RecentListenID: "earlier-listen"},
+		{SessionID: "closer-session", RecentListenID: "earlier-listen"},
+	}}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{UserID: "user"})
+	poller := NewPoller(&db, &spotify, time.Second)
+
+	err := poller.Match()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"closer-session": "listen", "earlier-session": "earlier-listen"}
+	if !reflect.DeepEqual(db.linked, expected) {
+		t.Errorf("Expected each listen claimed by a single session %v, got %v", expected, db.linked)
+	}
+}
+
+func TestPoller_matchLeavesSecondSessionUnmatched(t *testing.T) {
+	mock := database.NewMockDatabase()
+	db := mockPollerDatabase{MockDatabase: &mock, linked: map[string]interface{}{}, matches: []models.PlaybackSessionMatch{
+		{SessionID: "first-session", RecentListenID: "listen"},
+		{SessionID: "second-session", RecentListenID: "listen"},
+	}}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{UserID: "user"})
+	poller := NewPoller(&db, &spotify, time.Second)
+
+	err := poller.Match()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(db.linked, map[string]interface{}{"first-session": "listen"}) {
+		t.Errorf("Expected only the first session to claim the listen, got %v", db.linked)
+	}
+}
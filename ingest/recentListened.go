@@ -12,6 +12,7 @@ import (
 
 func (spotify *SpotifyIngest) InsertRecentListens(recents api.RecentlyPlayedResponse, songs []models.Song, existingRecentListens []models.RecentListen) error {
 	recentListenValues := []interface{}{}
+	references := []models.UnresolvedReference{}
 Outer:
 	for _, recentListen := range recents.Items {
 		for _, existingRecentListen := range existingRecentListens {
@@ -26,10 +27,12 @@ Outer:
 		song, exists := getSongBySpotifyID(songs, recentListen.Track.ID)
 		if exists {
 			newRecentListenData.SongID = song.ID
-		} else {
-			fmt.Printf("Failed to find %s\n", recentListen.Track.ID)
 		}
-		recentListenValues = append(recentListenValues, utils.ReflectValues(newRecentListenData)...)
+		values := utils.ReflectValues(newRecentListenData)
+		if !exists {
+			references = append(references, spotify.deferReference(&newRecentListenData, values, newRecentListenData.ID, "song_id", models.EntitySong, recentListen.Track.ID))
+		}
+		recentListenValues = append(recentListenValues, values...)
 	}
 
 	if len(recentListenValues) == 0 {
@@ -45,7 +48,7 @@ Outer:
 		return err
 	}
 
-	return nil
+	return spotify.insertUnresolvedReferences(references)
 }
 
 func (spotify *SpotifyIngest) FetchExistingRecentListens(recents api.RecentlyPlayedResponse) ([]models.RecentListen, error) {
@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func (spotify *SpotifyIngest) SavedTracks() (api.SavedTracksResponse, error) {
+	savedTracks, err := spotify.API.SavedTracks()
+	if err != nil {
+		return api.SavedTracksResponse{}, err
+	}
+
+	return savedTracks, nil
+}
+
+func (spotify *SpotifyIngest) SavedAlbums() (api.SavedAlbumsResponse, error) {
+	savedAlbums, err := spotify.API.SavedAlbums()
+	if err != nil {
+		return api.SavedAlbumsResponse{}, err
+	}
+
+	return savedAlbums, nil
+}
+
+// InsertSavedTracks inserts any newly liked tracks and marks the ones no longer in the users library as removed,
+// removed rows are kept so the library history survives
+func (spotify *SpotifyIngest) InsertSavedTracks(saved api.SavedTracksResponse, songs []models.Song) error {
+	existingSavedTracks, err := spotify.Database.FetchSavedTracksByUserID(spotify.Options.UserID)
+	if err != nil {
+		return err
+	}
+
+	stillSaved := make(map[string]bool)
+	savedTrackValues := []interface{}{}
+Outer:
+	for _, savedTrack := range saved.Items {
+		song, exists := getSongBySpotifyID(songs, savedTrack.Track.ID)
+		if !exists {
+			spotify.warn(fmt.Sprintf("Failed to attach song ID for saved track %s", savedTrack.Track.Name))
+			continue
+		}
+
+		stillSaved[song.ID] = true
+		for _, existingSavedTrack := range existingSavedTracks {
+			if existingSavedTrack.SongID == song.ID {
+				continue Outer
+			}
+		}
+
+		newSavedTrack := models.NewUserSavedTrack(spotify.Options.UserID, song.ID, savedTrack.AddedAt)
+		spotify.OnNewEntityEvent(&newSavedTrack)
+		savedTrackValues = append(savedTrackValues, utils.ReflectValues(newSavedTrack)...)
+	}
+
+	removedIDs := []interface{}{}
+	for _, existingSavedTrack := range existingSavedTracks {
+		if !stillSaved[existingSavedTrack.SongID] {
+			removedIDs = append(removedIDs, existingSavedTrack.ID)
+		}
+	}
+
+	if len(removedIDs) > 0 {
+		logger.Log(fmt.Sprintf("Marking %d saved tracks as removed", len(removedIDs)), logger.Debug)
+		err = spotify.Database.SetTimeByIDs(&models.UserSavedTrack{}, "removed_at", removedIDs, utils.NewTime())
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(savedTrackValues) == 0 {
+		logger.Log("No new saved track data to ingest", logger.Info)
+		return nil
+	}
+
+	savedTrackRecords := len(savedTrackValues) / len(utils.ReflectColumns(&models.UserSavedTrack{}))
+	logger.Log(fmt.Sprintf("Inserting %d new user_saved_tracks records", savedTrackRecords), logger.Debug)
+	err = spotify.Database.Create(&models.UserSavedTrack{}, savedTrackValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (spotify *SpotifyIngest) InsertSavedAlbums(saved api.SavedAlbumsResponse, albums []models.Album) error {
+	existingSavedAlbums, err := spotify.Database.FetchSavedAlbumsByUserID(spotify.Options.UserID)
+	if err != nil {
+		return err
+	}
+
+	stillSaved := make(map[string]bool)
+	savedAlbumValues := []interface{}{}
+Outer:
+	for _, savedAlbum := range saved.Items {
+		album, exists := getAlbumBySpotifyID(albums, savedAlbum.Album.ID)
+		if !exists {
+			spotify.warn(fmt.Sprintf("Failed to attach album ID for saved album %s", savedAlbum.Album.Name))
+			continue
+		}
+
+		stillSaved[album.ID] = true
+		for _, existingSavedAlbum := range existingSavedAlbums {
+			if existingSavedAlbum.AlbumID == album.ID {
+				continue Outer
+			}
+		}
+
+		newSavedAlbum := models.NewUserSavedAlbum(spotify.Options.UserID, album.ID, savedAlbum.AddedAt)
+		spotify.OnNewEntityEvent(&newSavedAlbum)
+		savedAlbumValues = append(savedAlbumValues, utils.ReflectValues(newSavedAlbum)...)
+	}
+
+	removedIDs := []interface{}{}
+	for _, existingSavedAlbum := range existingSavedAlbums {
+		if !stillSaved[existingSavedAlbum.AlbumID] {
+			removedIDs = append(removedIDs, existingSavedAlbum.ID)
+		}
+	}
+
+	if len(removedIDs) > 0 {
+		logger.Log(fmt.Sprintf("Marking %d saved albums as removed", len(removedIDs)), logger.Debug)
+		err = spotify.Database.SetTimeByIDs(&models.UserSavedAlbum{}, "removed_at", removedIDs, utils.NewTime())
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(savedAlbumValues) == 0 {
+		logger.Log("No new saved album data to ingest", logger.Info)
+		return nil
+	}
+
+	savedAlbumRecords := len(savedAlbumValues) / len(utils.ReflectColumns(&models.UserSavedAlbum{}))
+	logger.Log(fmt.Sprintf("Inserting %d new user_saved_albums records", savedAlbumRecords), logger.Debug)
+	err = spotify.Database.Create(&models.UserSavedAlbum{}, savedAlbumValues)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
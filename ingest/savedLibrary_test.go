@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+// mockSavedLibraryDatabase serves the users stored library and records the rows marked removed
+type mockSavedLibraryDatabase struct {
+	*database.MockDatabase
+	savedTracks []models.UserSavedTrack
+	savedAlbums []models.UserSavedAlbum
+	removed     map[string][]interface{}
+}
+
+func newMockSavedLibraryDatabase() *mockSavedLibraryDatabase {
+	mock := database.NewMockDatabase()
+	return &mockSavedLibraryDatabase{MockDatabase: &mock, removed: map[string][]interface{}{}}
+}
+
+func (db *mockSavedLibraryDatabase) FetchSavedTracksByUserID(userID string) ([]models.UserSavedTrack, error) {
+	return db.savedTracks, nil
+}
+
+func (db *mockSavedLibraryDatabase) FetchSavedAlbumsByUserID(userID string) ([]models.UserSavedAlbum, error) {
+	return db.savedAlbums, nil
+}
+
+func (db *mockSavedLibraryDatabase) SetTimeByIDs(model models.Model, column string, ids []interface{}, at utils.Time) error {
+	db.removed[model.TableName()] = append(db.removed[model.TableName()], ids...)
+	return nil
+}
+
+// savedColumn returns the column of every row in the last insert made into the models table
+func savedColumn(db *database.MockDatabase, model models.Model, column string) []interface{} {
+	columns := utils.ReflectColumns(model)
+	values := []interface{}{}
+	for i, value := range db.SavedValues[reflect.TypeOf(model).Elem().Name()] {
+		if columns[i%len(columns)] == column {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func TestInsertSavedTracks(t *testing.T) {
+	unchanged := models.NewSong("Unchanged", "unchanged-spotify-id", "album", "artist", 180000, false)
+	added := models.NewSong("Added", "added-spotify-id", "album", "artist", 180000, false)
+	removed := models.NewSong("Removed", "removed-spotify-id", "album", "artist", 180000, false)
+
+	db := newMockSavedLibraryDatabase()
+	db.savedTracks = []models.UserSavedTrack{
+		models.NewUserSavedTrack("user", unchanged.ID, time.Now()),
+		models.NewUserSavedTrack("user", removed.ID, time.Now()),
+	}
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	saved := api.SavedTracksResponse{Items: []api.SavedTrack{
+		{AddedAt: time.Now(), Track: api.Song{ID: "unchanged-spotify-id"}},
+		{AddedAt: time.Now(), Track: api.Song{ID: "added-spotify-id"}},
+	}}
+	err := spotify.InsertSavedTracks(saved, []models.Song{unchanged, added, removed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := savedColumn(db.MockDatabase, &models.UserSavedTrack{}, "song_id")
+	if !reflect.DeepEqual(inserted, []interface{}{added.ID}) {
+		t.Errorf("Expected only the added track to be inserted, got %v", inserted)
+	}
+
+	removedIDs := db.removed["user_saved_tracks"]
+	if !reflect.DeepEqual(removedIDs, []interface{}{db.savedTracks[1].ID}) {
+		t.Errorf("Expected only the removed track to be marked removed, got %v", removedIDs)
+	}
+}
+
+func TestInsertSavedAlbums(t *testing.T) {
+	unchanged := models.NewAlbum("Unchanged", "artist", "unchanged-spotify-id", false)
+	added := models.NewAlbum("Added", "artist", "added-spotify-id", false)
+	removed := models.NewAlbum("Removed", "artist", "removed-spotify-id", false)
+
+	db := newMockSavedLibraryDatabase()
+	db.savedAlbums = []models.UserSavedAlbum{
+		models.NewUserSavedAlbum("user", unchanged.ID, time.Now()),
+		models.NewUserSavedAlbum("user", removed.ID, time.Now()),
+	}
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	saved := api.SavedAlbumsResponse{Items: []api.SavedAlbum{
+		{AddedAt: time.Now(), Album: api.Album{ID: "unchanged-spotify-id"}},
+		{AddedAt: time.Now(), Album: api.Album{ID: "added-spotify-id"}},
+	}}
+	err := spotify.InsertSavedAlbums(saved, []models.Album{unchanged, added, removed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := savedColumn(db.MockDatabase, &models.UserSavedAlbum{}, "album_id")
+	if !reflect.DeepEqual(inserted, []interface{}{added.ID}) {
+		t.Errorf("Expected only the added album to be inserted, got %v", inserted)
+	}
+
+	removedIDs := db.removed["user_saved_albums"]
+	if !reflect.DeepEqual(removedIDs, []interface{}{db.savedAlbums[1].ID}) {
+		t.Errorf("Expected only the removed album to be marked removed, got %v", removedIDs)
+	}
+}
+
+func TestInsertSavedTracks_unchanged(t *testing.T) {
+	song := models.NewSong("Unchanged", "unchanged-spotify-id", "album", "artist", 180000, false)
+
+	db := newMockSavedLibraryDatabase()
+	db.savedTracks = []models.UserSavedTrack{models.NewUserSavedTrack("user", song.ID, time.Now())}
+	spotify := NewSpotifyIngest(db, nil, SpotifyIngestOptions{UserID: "user"})
+
+	saved := api.SavedTracksResponse{Items: []api.SavedTrack{{AddedAt: time.Now(), Track: api.Song{ID: "unchanged-spotify-id"}}}}
+	err := spotify.InsertSavedTracks(saved, []models.Song{song})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.SavedValues) != 0 || len(db.removed) != 0 {
+		t.Errorf("Expected an unchanged library to change nothing, got %v inserts and %v removals", db.SavedValues, db.removed)
+	}
+}
@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/models"
+)
+
+// Failure policies, strict fails the run on the first failed step while best-effort rolls the step back and carries
+// on with the rest
+const (
+	PolicyStrict     = "strict"
+	PolicyBestEffort = "best-effort"
+)
+
+// FailedStep is a step rolled back under the best-effort policy, the run committed everything else
+type FailedStep struct {
+	Name string
+	Err  string
+}
+
+// ValidPolicy reports whether policy is one of the failure policies, an empty policy is strict
+func ValidPolicy(policy string) bool {
+	return policy == "" || policy == PolicyStrict || policy == PolicyBestEffort
+}
+
+// inSavepoint runs a step inside its own savepoint, named after the step. A failed step is always rolled back to its
+// savepoint, under the best-effort policy it is then recorded in the stats instead of failing the run
+func (spotify *SpotifyIngest) inSavepoint(name string, step func() error) error {
+	err := spotify.Database.Savepoint(name)
+	if err != nil {
+		return err
+	}
+
+	err = step()
+	if err == nil {
+		return spotify.Database.ReleaseSavepoint(name)
+	}
+
+	rollbackErr := spotify.Database.RollbackToSavepoint(name)
+	if rollbackErr != nil {
+		return fmt.Errorf("%w, then failed to roll back to savepoint %s, %s", err, name, rollbackErr.Error())
+	}
+
+	if spotify.Options.FailurePolicy != PolicyBestEffort {
+		return err
+	}
+
+	spotify.warn(fmt.Sprintf("Step %s failed and was rolled back, carrying on with the rest of the run, %s", name, err.Error()))
+	spotify.Stats.FailedSteps = append(spotify.Stats.FailedSteps, FailedStep{Name: name, Err: err.Error()})
+	return nil
+}
+
+// stepFailed reports whether the named step was rolled back under the best-effort policy
+func (spotify *SpotifyIngest) stepFailed(name string) bool {
+	for _, step := range spotify.Stats.FailedSteps {
+		if step.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dropRolledBack removes the entities a rolled back step would have inserted, so the steps after it defer their
+// references to them through unresolved_references instead of pointing at rows that were never stored
+func (spotify *SpotifyIngest) dropRolledBack(dbData DBData, name string) DBData {
+	if !spotify.stepFailed(name) {
+		return dbData
+	}
+
+	switch name {
+	case "artists":
+		dbData.Artists = storedOnly(dbData.Artists, func(artist models.Artist) bool { return artist.NeedsUpdate })
+	case "albums":
+		dbData.Albums = storedOnly(dbData.Albums, func(album models.Album) bool { return album.NeedsUpdate })
+	case "songs":
+		dbData.Songs = storedOnly(dbData.Songs, func(song models.Song) bool { return song.NeedsUpdate })
+	case "shows":
+		dbData.Shows = storedOnly(dbData.Shows, func(show models.Show) bool { return show.NeedsUpdate })
+	case "episodes":
+		dbData.Episodes = storedOnly(dbData.Episodes, func(episode models.Episode) bool { return episode.NeedsUpdate })
+	}
+	return dbData
+}
+
+// storedOnly keeps the entities that were already stored before this run
+func storedOnly[T any](entities []T, needsUpdate func(T) bool) []T {
+	stored := []T{}
+	for _, entity := range entities {
+		if !needsUpdate(entity) {
+			stored = append(stored, entity)
+		}
+	}
+	return stored
+}
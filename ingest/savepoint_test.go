@@ -0,0 +1,98 @@
+package ingest
+
+import (
+	"errors"
+	"reflect"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+)
+
+// savepointDatabase fails inserts into the failing table and records the savepoint calls made around them
+type savepointDatabase struct {
+	*database.MockDatabase
+	failing string
+	calls   []string
+}
+
+func (db *savepointDatabase) Create(model models.Model, values []interface{}) error {
+	if model.TableName() == db.failing {
+		return errors.New("insert failed")
+	}
+	return db.MockDatabase.Create(model, values)
+}
+
+func (db *savepointDatabase) Savepoint(name string) error {
+	db.calls = append(db.calls, "savepoint "+name)
+	return nil
+}
+
+func (db *savepointDatabase) RollbackToSavepoint(name string) error {
+	db.calls = append(db.calls, "rollback "+name)
+	return nil
+}
+
+func (db *savepointDatabase) ReleaseSavepoint(name string) error {
+	db.calls = append(db.calls, "release "+name)
+	return nil
+}
+
+func TestAttachAndInsertFreshData_policies(t *testing.T) {
+	artist := models.NewArtist("Artist", "artist-spotify-id", true)
+	album := models.NewAlbum("Album", "artist-spotify-id", "album-spotify-id", true)
+	dbData := DBData{
+		Artists: []models.Artist{artist},
+		Albums:  []models.Album{album},
+	}
+
+	mock := database.NewMockDatabase()
+	db := savepointDatabase{MockDatabase: &mock, failing: "artists"}
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{FailurePolicy: PolicyStrict})
+	_, err := spotify.AttachAndInsertFreshData(APIData{}, dbData)
+	if err == nil {
+		t.Fatal("Expected the strict policy to fail the run")
+	}
+
+	expected := []string{"savepoint artists", "rollback artists"}
+	if !reflect.DeepEqual(db.calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, db.calls)
+	}
+
+	bestEffortMock := database.NewMockDatabase()
+	db = savepointDatabase{MockDatabase: &bestEffortMock, failing: "artists"}
+	spotify = NewSpotifyIngest(&db, nil, SpotifyIngestOptions{FailurePolicy: PolicyBestEffort})
+	dbData, err = spotify.AttachAndInsertFreshData(APIData{}, dbData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.calls) < 4 || db.calls[0] != "savepoint artists" || db.calls[1] != "rollback artists" || db.calls[3] != "release albums" {
+		t.Errorf("Expected only the artists savepoint to be rolled back, got %v", db.calls)
+	}
+
+	if !spotify.Stats.Partial() || len(spotify.Stats.FailedSteps) != 1 || spotify.Stats.FailedSteps[0].Name != "artists" {
+		t.Errorf("Expected the artists step to be reported as failed, got %+v", spotify.Stats.FailedSteps)
+	}
+
+	if len(dbData.Artists) != 0 {
+		t.Errorf("Expected the rolled back artist to be dropped, got %+v", dbData.Artists)
+	}
+
+	albumValues := bestEffortMock.SavedValues["Album"]
+	for i, column := range utils.ReflectColumns(&models.Album{}) {
+		if column == "artist_id" && albumValues[i] != nil {
+			t.Errorf("Expected the album to be inserted without the rolled back artist, got artist_id %v", albumValues[i])
+		}
+	}
+
+	referenceValues := map[string]interface{}{}
+	for i, column := range utils.ReflectColumns(&models.UnresolvedReference{}) {
+		if i < len(bestEffortMock.SavedValues["UnresolvedReference"]) {
+			referenceValues[column] = bestEffortMock.SavedValues["UnresolvedReference"][i]
+		}
+	}
+	if referenceValues["source_table"] != "albums" || referenceValues["spotify_id"] != "artist-spotify-id" {
+		t.Errorf("Expected the albums artist to be deferred to an unresolved reference, got %v", referenceValues)
+	}
+}
@@ -0,0 +1,233 @@
+package ingest
+
+import (
+	"fmt"
+	"sort"
+	"spotify/api"
+	"spotify/models"
+	"spotify/scrobble"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	// scrobbles are stamped when a track starts, spotify stamps played_at when it ends, so the same play can be minutes apart
+	scrobbleDedupWindow = 5 * time.Minute
+)
+
+type ScrobbleDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	FetchRecentListensByUserIDBetween(userID string, from utils.Time, to utils.Time) ([]models.RecentListen, error)
+	FetchScrobbleMatches(keys []interface{}) ([]models.ScrobbleMatch, error)
+	Commit()
+	Rollback()
+}
+
+// ScrobbleImporter matches scrobbles from other services to spotify tracks by searching for them, caching every
+// match (and miss) in scrobble_matches, then records them as recent listens tagged with where they came from
+type ScrobbleImporter struct {
+	database  ScrobbleDatabase
+	spotify   *SpotifyIngest
+	source    string
+	batchSize int
+
+	Matched   int
+	Unmatched int
+}
+
+func NewScrobbleImporter(database ScrobbleDatabase, spotify *SpotifyIngest, source string, batchSize int) ScrobbleImporter {
+	return ScrobbleImporter{
+		database:  database,
+		spotify:   spotify,
+		source:    source,
+		batchSize: batchSize,
+	}
+}
+
+// Import commits after every batch, rerunning an import skips anything already recorded as a duplicate listen
+func (s *ScrobbleImporter) Import(scrobbles []scrobble.Scrobble) error {
+	sort.Slice(scrobbles, func(i, j int) bool {
+		return scrobbles[i].ListenedAt.Before(scrobbles[j].ListenedAt)
+	})
+
+	for start := 0; start < len(scrobbles); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(scrobbles) {
+			end = len(scrobbles)
+		}
+
+		err := s.importBatch(scrobbles[start:end])
+		if err != nil {
+			s.database.Rollback()
+			return err
+		}
+
+		logger.Log(fmt.Sprintf("Imported %d of %d scrobbles", end, len(scrobbles)), logger.Debug)
+		s.database.Commit()
+	}
+
+	logger.Log(fmt.Sprintf("Matched %d scrobbles, %d could not be found on spotify", s.Matched, s.Unmatched), logger.Info)
+	return nil
+}
+
+func (s *ScrobbleImporter) importBatch(batch []scrobble.Scrobble) error {
+	matches, err := s.match(batch)
+	if err != nil {
+		return err
+	}
+
+	trackSpotifyIDs := utils.NewStringArgs()
+	for _, spotifyID := range matches {
+		if spotifyID != "" {
+			trackSpotifyIDs.Add(spotifyID)
+		}
+	}
+
+	if len(trackSpotifyIDs.UniqueMap) == 0 {
+		s.Unmatched += len(batch)
+		return nil
+	}
+
+	songs, err := s.spotify.ResolveTracks(trackSpotifyIDs)
+	if err != nil {
+		return err
+	}
+
+	from, to := batch[0].ListenedAt, batch[len(batch)-1].ListenedAt
+	existingRecentListens, err := s.database.FetchRecentListensByUserIDBetween(s.spotify.Options.UserID, utils.Time{Time: from.Add(-scrobbleDedupWindow)}, utils.Time{Time: to.Add(scrobbleDedupWindow)})
+	if err != nil {
+		return err
+	}
+
+	recentListenValues := []interface{}{}
+	detailValues := []interface{}{}
+	for _, scrobbled := range batch {
+		spotifyID := matches[scrobbleKey(scrobbled)]
+		song, exists := getSongBySpotifyID(songs, spotifyID)
+		if spotifyID == "" || !exists {
+			logger.Log(fmt.Sprintf("No spotify match for %s - %s", scrobbled.ArtistName, scrobbled.TrackName), logger.Trace)
+			s.Unmatched++
+			continue
+		}
+		s.Matched++
+
+		if isDuplicateListen(existingRecentListens, song.ID, scrobbled.ListenedAt, scrobbleDedupWindow) {
+			continue
+		}
+
+		recentListen := models.NewRecentListen(song.ID, s.spotify.Options.UserID, scrobbled.ListenedAt)
+		existingRecentListens = append(existingRecentListens, recentListen)
+		s.spotify.OnNewEntityEvent(&recentListen)
+		recentListenValues = append(recentListenValues, utils.ReflectValues(recentListen)...)
+
+		detail := models.NewRecentListenDetail(recentListen.ID, 0, "", "", false, false, false, "", s.source)
+		detailValues = append(detailValues, utils.ReflectValues(detail)...)
+	}
+
+	if len(recentListenValues) == 0 {
+		logger.Log("No new listens in batch", logger.Debug)
+		return nil
+	}
+
+	recentListenRecords := len(recentListenValues) / len(utils.ReflectColumns(&models.RecentListen{}))
+	logger.Log(fmt.Sprintf("Inserting %d new recent_listen records", recentListenRecords), logger.Debug)
+	err = s.database.Create(&models.RecentListen{}, recentListenValues)
+	if err != nil {
+		return err
+	}
+
+	return s.database.Create(&models.RecentListenDetail{}, detailValues)
+}
+
+type matchKey struct {
+	artist string
+	track  string
+}
+
+func scrobbleKey(scrobbled scrobble.Scrobble) matchKey {
+	artist, track := scrobbled.Key()
+	return matchKey{artist, track}
+}
+
+// match maps every distinct track in the batch to a spotify id, or an empty string when there is no match.
+// Cached matches are used where there are any, the rest are searched for and added to the cache
+func (s *ScrobbleImporter) match(batch []scrobble.Scrobble) (map[matchKey]string, error) {
+	matches := map[matchKey]string{}
+	uncached := map[matchKey]scrobble.Scrobble{}
+	for _, scrobbled := range batch {
+		key := scrobbleKey(scrobbled)
+		if key.artist == "" || key.track == "" {
+			matches[key] = ""
+			continue
+		}
+		uncached[key] = scrobbled
+	}
+
+	if len(uncached) == 0 {
+		return matches, nil
+	}
+
+	keys := []interface{}{}
+	for key := range uncached {
+		keys = append(keys, key.artist, key.track)
+	}
+
+	cached, err := s.database.FetchScrobbleMatches(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range cached {
+		key := matchKey{match.ArtistKey, match.TrackKey}
+		matches[key] = match.SpotifyID.String
+		delete(uncached, key)
+	}
+
+	if len(uncached) == 0 {
+		return matches, nil
+	}
+
+	logger.Log(fmt.Sprintf("Searching spotify for %d unmatched tracks", len(uncached)), logger.Debug)
+	matchValues := []interface{}{}
+	for key, scrobbled := range uncached {
+		spotifyID := scrobbled.SpotifyID
+		if spotifyID == "" {
+			results, err := s.spotify.API.SearchTracks(scrobbled.TrackName, scrobbled.ArtistName)
+			if err != nil {
+				return nil, err
+			}
+			spotifyID = bestMatch(results, key)
+		}
+
+		matches[key] = spotifyID
+		match := models.NewScrobbleMatch(key.artist, key.track, spotifyID)
+		matchValues = append(matchValues, utils.ReflectValues(match)...)
+	}
+
+	err = s.database.Create(&models.ScrobbleMatch{}, matchValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// bestMatch picks the first search result whose name and one of whose artists match the scrobble once normalized,
+// search happily returns covers and karaoke versions so the top result alone can't be trusted
+func bestMatch(results []api.Song, key matchKey) string {
+	for _, result := range results {
+		if result.ID == "" || scrobble.Normalize(result.Name) != key.track {
+			continue
+		}
+
+		for _, artist := range result.Artists {
+			if scrobble.Normalize(artist.Name) == key.artist {
+				return result.ID
+			}
+		}
+	}
+
+	return ""
+}
@@ -3,6 +3,7 @@ package ingest
 import (
 	"spotify/api"
 	"spotify/models"
+	"spotify/utils"
 	"time"
 
 	"github.com/batzz-00/goutils/logger"
@@ -20,11 +21,15 @@ type SpotifyIngestStats struct {
 	Artists   EntityMetric
 	StartTime time.Time
 	EndTime   time.Time
+	// Stages holds how long each pipeline stage took, in the order they ran
+	Stages []StageTiming
+	// FailedSteps holds the steps rolled back under the best-effort policy, the run only partially succeeded if any
+	FailedSteps []FailedStep
 }
 
-type SpotifyIngestEvents struct {
-	OnNewEntity *func(model models.Model)
-	OnFinish    *func(stats SpotifyIngestStats)
+// Partial reports whether any steps were rolled back while the rest of the run was committed
+func (stats SpotifyIngestStats) Partial() bool {
+	return len(stats.FailedSteps) > 0
 }
 
 func (e *EntityMetric) IncrementCount(new bool) {
@@ -54,10 +59,31 @@ type SpotifyIngestOptions struct {
 	RecentListen       bool
 	TopSongs           bool
 	TopArtists         bool
+	SavedLibrary       bool
+	FollowedArtists    bool
+	Playlists          bool
+	Podcasts           bool
 	UserID             string
+	SpotifyUserID      string
 	VariousArtistsUUID string
 	EnvUsers           []string
-	Events             SpotifyIngestEvents
+	// TopDepths is how many top songs and artists to fetch per term (short, medium, long), DefaultTopDepth if unset
+	TopDepths map[string]int
+	// CatalogMaxAge is how old an artist, album or song can get before it's re-fetched, at most CatalogRefreshBudget
+	// per run. Refreshing is off while either is zero
+	CatalogMaxAge        time.Duration
+	CatalogRefreshBudget int
+	// RunID tags the rows recorded during this run, eg. unresolved references
+	RunID string
+	// CheckpointDir is where each stages outputs are checkpointed, under the run id, so a failed run can be resumed.
+	// Checkpointing is off when it's empty, which keeps the whole run in one transaction. While it's set each stage is
+	// committed as it completes, so a failure only rolls back the failing stage and the earlier ones stay stored
+	CheckpointDir string
+	// FailurePolicy decides what a failed step does, PolicyStrict (the default) fails the run while PolicyBestEffort
+	// rolls back only that step
+	FailurePolicy string
+	// Events is where the ingest publishes what it does, nothing is published while it's nil
+	Events *EventBus
 }
 
 type SpotifyIngestContext struct {
@@ -76,16 +102,46 @@ type IngestDatabase interface {
 	FetchArtistByID(id string) (models.Artist, error)
 	FetchRecentListensByUserIDAndTime(userID string, recentListenedToIDs []interface{}, earliestTime interface{}) ([]models.RecentListen, error)
 	FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error)
+	FetchSavedTracksByUserID(userID string) ([]models.UserSavedTrack, error)
+	FetchSavedAlbumsByUserID(userID string) ([]models.UserSavedAlbum, error)
+	FetchFollowedArtistsByUserID(userID string) ([]models.UserFollowedArtist, error)
+	FetchLatestPlaylistSnapshotsByUserID(userID string) ([]models.PlaylistSnapshot, error)
+	FetchShowsBySpotifyID(spotifyIDs []interface{}) ([]models.Show, error)
+	FetchEpisodesBySpotifyID(spotifyIDs []interface{}) ([]models.Episode, error)
+	FetchLatestEpisodeResumePointsByUserID(userID string) ([]models.EpisodeResumePoint, error)
+	FetchLatestTopListEntries(userID string, listType string) ([]models.TopListEntry, error)
+	SetTimeByIDs(model models.Model, column string, ids []interface{}, at utils.Time) error
+	FetchStaleEntities(before utils.Time, limit int) ([]models.StaleEntity, error)
+	UpdateByID(model models.Model, id string, values map[string]interface{}, at utils.Time) error
+	FetchUnresolvedReferences(limit int) ([]models.UnresolvedReference, error)
+	ResolveReference(reference models.UnresolvedReference, resolvedID string, at utils.Time) error
+	RecordResolveAttempts(ids []interface{}, at utils.Time) error
+	FetchIngestStagesByRunID(runID string) ([]models.IngestStage, error)
+	Commit()
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
 }
 
 type API interface {
 	Me() (api.MeResponse, error)
 	RecentlyPlayedByUser() (api.RecentlyPlayedResponse, error)
-	TopArtistsForUser(period string) (api.TopArtistsResponse, error)
-	TopTracksForUser(period string) (api.TopTracksResponse, error)
+	TopArtistsForUser(period string, depth int) (api.TopArtistsResponse, error)
+	TopTracksForUser(period string, depth int) (api.TopTracksResponse, error)
+	SavedTracks() (api.SavedTracksResponse, error)
+	SavedAlbums() (api.SavedAlbumsResponse, error)
+	FollowedArtists() (api.FollowedArtistsResponse, error)
+	Playlists() (api.PlaylistsResponse, error)
+	PlaylistTracks(playlistID string) (api.PlaylistTracksResponse, error)
+	CurrentlyPlaying() (api.CurrentlyPlayingResponse, error)
+	SavedShows() (api.SavedShowsResponse, error)
+	SavedEpisodes() (api.SavedEpisodesResponse, error)
+	SearchTracks(track string, artist string) ([]api.Song, error)
 	ArtistsBySpotifyID(ids []string) ([]api.Artist, error)
 	TracksBySpotifyID(ids []string) ([]api.Song, error)
 	AlbumsBySpotifyID(ids []string) ([]api.Album, error)
+	ShowsBySpotifyID(ids []string) ([]api.Show, error)
+	EpisodesBySpotifyID(ids []string) ([]api.Episode, error)
 	Authorize(code string) error
 	Refresh() error
 }
@@ -103,6 +159,19 @@ type APIData struct {
 	Songs   map[string]api.TopTracksResponse
 	Artists map[string]api.TopArtistsResponse
 	Recents api.RecentlyPlayedResponse
+
+	SavedTracks api.SavedTracksResponse
+	SavedAlbums api.SavedAlbumsResponse
+
+	FollowedArtists api.FollowedArtistsResponse
+	Playlists       []PlaylistData
+
+	SavedShows    api.SavedShowsResponse
+	SavedEpisodes api.SavedEpisodesResponse
+
+	// Tracks and Episodes hold loose items picked up outside of the user endpoints, eg. by the currently playing poller
+	Tracks   []api.Song
+	Episodes []api.Episode
 }
 
 type DBData struct {
@@ -110,11 +179,19 @@ type DBData struct {
 	Albums        []models.Album
 	Artists       []models.Artist
 	RecentListens []models.RecentListen
+	Shows         []models.Show
+	Episodes      []models.Episode
 }
 
+// NewIngestContext reuses the options run id when set, eg. when resuming a failed run
 func NewIngestContext(options SpotifyIngestOptions) SpotifyIngestContext {
+	id := options.RunID
+	if id == "" {
+		id = uuid.NewString()
+	}
+
 	return SpotifyIngestContext{
-		Id:      uuid.NewString(),
+		Id:      id,
 		Options: options,
 	}
 }
@@ -130,62 +207,56 @@ func NewSpotifyIngest(database IngestDatabase, api API, options SpotifyIngestOpt
 	}
 }
 
-func (spotify *SpotifyIngest) OnNewEntityEvent(model models.Model) {
-	if spotify.Options.Events.OnNewEntity != nil {
-		(*spotify.Options.Events.OnNewEntity)(model)
+// topDepth is how deep the users top lists for the term should go
+func (spotify *SpotifyIngest) topDepth(term string) int {
+	if depth, exists := spotify.Options.TopDepths[term]; exists && depth > 0 {
+		return depth
 	}
+	return DefaultTopDepth
+}
+
+// OnNewEntityEvent publishes a newly created entity
+func (spotify *SpotifyIngest) OnNewEntityEvent(model models.Model) {
+	spotify.Options.Events.Publish(EntityCreated{Model: model, New: true})
+}
+
+// warn logs a warning and publishes it
+func (spotify *SpotifyIngest) warn(message string) {
+	logger.Log(message, logger.Warning)
+	spotify.Options.Events.Publish(Warning{Message: message})
 }
 
 func (spotify *SpotifyIngest) OnNewAlbum(model *models.Album, new bool) {
-	spotify.OnNewEntityEvent(model)
+	spotify.Options.Events.Publish(EntityCreated{Model: model, New: new})
 	spotify.Stats.Albums.IncrementCount(new)
 }
 
 func (spotify *SpotifyIngest) OnNewArtist(model *models.Artist, new bool) {
-	spotify.OnNewEntityEvent(model)
+	spotify.Options.Events.Publish(EntityCreated{Model: model, New: new})
 	spotify.Stats.Artists.IncrementCount(new)
 }
 
 func (spotify *SpotifyIngest) OnNewSong(model *models.Song, new bool) {
-	spotify.OnNewEntityEvent(model)
+	spotify.Options.Events.Publish(EntityCreated{Model: model, New: new})
 	spotify.Stats.Songs.IncrementCount(new)
 }
 
 func (spotify *SpotifyIngest) Ingest() error {
-	logger.Log("Fetching user data from spotify API", logger.Info)
-	APIData, err := spotify.FetchAPIData()
-	if err != nil {
-		return err
-	}
-
-	logger.Log("Fetching related data for songs, albums etc from database and then the spotify API if we don't already have it", logger.Info)
-	relatedData, err := spotify.FetchRelated(APIData)
-	if err != nil {
-		return err
-	}
-
-	logger.Log("Attaching appropriate UUIDs based on spotify ID, and inserting any missed data freshly gathered from spotify API", logger.Info)
-	relatedData, err = spotify.AttachAndInsertFreshData(APIData, relatedData)
-	if err != nil {
-		return err
-	}
-
-	logger.Log("Fetching all existing recently listened to songs", logger.Info)
-	relatedData.RecentListens, err = spotify.FetchExistingRecentListens(APIData.Recents)
-	if err != nil {
-		logger.Log("Failed to fetch recently listened to songs from the database", logger.Error)
-		return err
-	}
-
-	logger.Log("Prefetch and insert phase complete, moving on to user data insert", logger.Info)
-	err = spotify.InsertUserData(APIData, relatedData)
-	if err != nil {
-		logger.Log("Failed to insert user data", logger.Error)
-		return err
+	var checkpoints *Checkpoints
+	if spotify.Options.CheckpointDir != "" && spotify.Options.RunID != "" {
+		runCheckpoints := NewCheckpoints(spotify.Options.CheckpointDir, spotify.Options.RunID)
+		checkpoints = &runCheckpoints
 	}
 
+	err := spotify.RunStages(spotify.Stages(), checkpoints)
 	spotify.Stats.EndTime = time.Now()
-	return nil
+	spotify.Options.Events.Publish(RunFinished{
+		RunID:  spotify.Options.RunID,
+		UserID: spotify.Options.UserID,
+		Stats:  spotify.Stats,
+		Err:    err,
+	})
+	return err
 }
 
 func (spotify *SpotifyIngest) FetchAPIData() (APIData, error) {
@@ -210,67 +281,170 @@ func (spotify *SpotifyIngest) FetchAPIData() (APIData, error) {
 		return APIData{}, err
 	}
 
-	return APIData{
+	APIData := APIData{
 		Songs:   songs,
 		Artists: artists,
 		Recents: recents,
-	}, nil
+	}
+
+	if spotify.Options.SavedLibrary {
+		logger.Log("Attempting to fetch users saved tracks", logger.Info)
+		APIData.SavedTracks, err = spotify.SavedTracks()
+		if err != nil {
+			logger.Log("Failed to fetch users saved tracks!", logger.Error)
+			return APIData, err
+		}
+
+		logger.Log("Attempting to fetch users saved albums", logger.Info)
+		APIData.SavedAlbums, err = spotify.SavedAlbums()
+		if err != nil {
+			logger.Log("Failed to fetch users saved albums!", logger.Error)
+			return APIData, err
+		}
+	}
+
+	if spotify.Options.FollowedArtists {
+		logger.Log("Attempting to fetch users followed artists", logger.Info)
+		APIData.FollowedArtists, err = spotify.FollowedArtists()
+		if err != nil {
+			logger.Log("Failed to fetch users followed artists!", logger.Error)
+			return APIData, err
+		}
+	}
+
+	if spotify.Options.Playlists {
+		logger.Log("Attempting to fetch users changed playlists", logger.Info)
+		APIData.Playlists, err = spotify.Playlists()
+		if err != nil {
+			logger.Log("Failed to fetch users playlists!", logger.Error)
+			return APIData, err
+		}
+	}
+
+	if spotify.Options.Podcasts {
+		logger.Log("Attempting to fetch users saved shows", logger.Info)
+		APIData.SavedShows, err = spotify.SavedShows()
+		if err != nil {
+			logger.Log("Failed to fetch users saved shows!", logger.Error)
+			return APIData, err
+		}
+
+		logger.Log("Attempting to fetch users saved episodes", logger.Info)
+		APIData.SavedEpisodes, err = spotify.SavedEpisodes()
+		if err != nil {
+			logger.Log("Failed to fetch users saved episodes!", logger.Error)
+			return APIData, err
+		}
+	}
+
+	return APIData, nil
 }
 
 func (spotify *SpotifyIngest) FetchRelated(APIData APIData) (DBData, error) {
 	logger.Log("Fetching tracks from database, then API if missing", logger.Info)
-	dbSongs, err := spotify.PopulateTracks(APIData.Songs, APIData.Recents)
+	dbSongs, err := spotify.PopulateTracks(APIData)
 	if err != nil {
 		logger.Log("Failed to fetch tracks from database", logger.Error)
 		return DBData{}, err
 	}
 
 	logger.Log("Fetching artists from database, then API if missing", logger.Info)
-	dbArtists, err := spotify.PopulateArtists(APIData.Songs, APIData.Artists, APIData.Recents)
+	dbArtists, err := spotify.PopulateArtists(APIData)
 	if err != nil {
 		logger.Log("Failed to fetch spotify artists!", logger.Error)
 		return DBData{}, err
 	}
 
 	logger.Log("Fetching albums from database, then API if missing", logger.Info)
-	dbAlbums, err := spotify.PopulateAlbums(APIData.Songs, APIData.Recents)
+	dbAlbums, err := spotify.PopulateAlbums(APIData)
 	if err != nil {
 		logger.Log("Failed to fetch spotify recently played albums!", logger.Error)
 		return DBData{}, err
 	}
 
+	logger.Log("Fetching shows from database, creating any we don't have", logger.Info)
+	dbShows, err := spotify.PopulateShows(APIData)
+	if err != nil {
+		logger.Log("Failed to fetch shows!", logger.Error)
+		return DBData{}, err
+	}
+
+	logger.Log("Fetching episodes from database, creating any we don't have", logger.Info)
+	dbEpisodes, err := spotify.PopulateEpisodes(APIData)
+	if err != nil {
+		logger.Log("Failed to fetch episodes!", logger.Error)
+		return DBData{}, err
+	}
+
 	return DBData{
-		Songs:   dbSongs,
-		Artists: dbArtists,
-		Albums:  dbAlbums,
+		Songs:    dbSongs,
+		Artists:  dbArtists,
+		Albums:   dbAlbums,
+		Shows:    dbShows,
+		Episodes: dbEpisodes,
 	}, err
 }
 
 func (spotify *SpotifyIngest) AttachAndInsertFreshData(APIData APIData, dbData DBData) (DBData, error) {
 	logger.Log("Artists dont need related data, simply inserting", logger.Info)
-	err := spotify.InsertArtists(dbData.Artists)
+	err := spotify.inSavepoint("artists", func() error {
+		return spotify.InsertArtists(dbData.Artists)
+	})
 	if err != nil {
 		logger.Log("Failed to insert artists into the database", logger.Error)
 		return dbData, err
 	}
+	dbData = spotify.dropRolledBack(dbData, "artists")
 
 	logger.Log("Attaching appropriate UUIDs to albums to be inserted, then inserting", logger.Info)
-	err = spotify.AttachAlbumUUIDs(dbData.Albums, dbData.Artists)
+	err = spotify.inSavepoint("albums", func() error {
+		return spotify.AttachAlbumUUIDs(dbData.Albums, dbData.Artists)
+	})
 	if err != nil {
 		logger.Log("Failed to attach and insert albums into the database", logger.Error)
 		return dbData, err
 	}
+	dbData = spotify.dropRolledBack(dbData, "albums")
 
 	logger.Log("Attaching appropriate UUIDs to tracks to be inserted, then inserting", logger.Info)
-	dbSongs, err := spotify.AttachTrackUUIDs(dbData.Songs, dbData.Artists, dbData.Albums)
+	err = spotify.inSavepoint("songs", func() error {
+		dbSongs, err := spotify.AttachTrackUUIDs(dbData.Songs, dbData.Artists, dbData.Albums)
+		if err != nil {
+			return err
+		}
+		dbData.Songs = dbSongs
+		return nil
+	})
 	if err != nil {
 		logger.Log("Failed to attach and insert songs into the database", logger.Error)
 		return dbData, err
 	}
-	dbData.Songs = dbSongs
+	dbData = spotify.dropRolledBack(dbData, "songs")
+
+	logger.Log("Shows dont need related data, simply inserting", logger.Info)
+	err = spotify.inSavepoint("shows", func() error {
+		return spotify.InsertShows(dbData.Shows)
+	})
+	if err != nil {
+		logger.Log("Failed to insert shows into the database", logger.Error)
+		return dbData, err
+	}
+	dbData = spotify.dropRolledBack(dbData, "shows")
+
+	logger.Log("Attaching appropriate UUIDs to episodes to be inserted, then inserting", logger.Info)
+	err = spotify.inSavepoint("episodes", func() error {
+		return spotify.AttachEpisodeUUIDs(dbData.Episodes, dbData.Shows)
+	})
+	if err != nil {
+		logger.Log("Failed to attach and insert episodes into the database", logger.Error)
+		return dbData, err
+	}
+	dbData = spotify.dropRolledBack(dbData, "episodes")
 
 	logger.Log("Inserting all relevant thumbnails into DB", logger.Info)
-	err = spotify.InsertThumbnails(APIData.Songs, APIData.Recents, APIData.Artists, dbData.Artists, dbData.Albums)
+	err = spotify.inSavepoint("thumbnails", func() error {
+		return spotify.InsertThumbnails(APIData, dbData)
+	})
 	if err != nil {
 		logger.Log("Failed to insert thumbnails!", logger.Error)
 		return dbData, err
@@ -282,7 +456,9 @@ func (spotify *SpotifyIngest) AttachAndInsertFreshData(APIData APIData, dbData D
 func (spotify *SpotifyIngest) InsertUserData(APIData APIData, dbData DBData) error {
 	if spotify.Options.RecentListen {
 		logger.Log("Inserting all recently listened to songs", logger.Info)
-		err := spotify.InsertRecentListens(APIData.Recents, dbData.Songs, dbData.RecentListens)
+		err := spotify.inSavepoint("recent_listens", func() error {
+			return spotify.InsertRecentListens(APIData.Recents, dbData.Songs, dbData.RecentListens)
+		})
 		if err != nil {
 			logger.Log("Failed to insert recentlistens into the database", logger.Error)
 			return err
@@ -291,22 +467,79 @@ func (spotify *SpotifyIngest) InsertUserData(APIData APIData, dbData DBData) err
 
 	if spotify.Options.TopSongs {
 		logger.Log("Inserting all top songs", logger.Info)
-		err := spotify.InsertTopSongs(APIData.Songs, dbData.Songs)
+		err := spotify.inSavepoint("top_songs", func() error {
+			return spotify.InsertTopSongs(APIData.Songs, dbData.Songs)
+		})
 		if err != nil {
 			logger.Log("Failed to insert top songs into the database", logger.Error)
 			return err
 		}
 	}
 
-	if spotify.Options.TopSongs {
+	if spotify.Options.TopArtists {
 		logger.Log("Inserting all top artists", logger.Info)
-		err := spotify.InsertTopArtists(APIData.Artists, dbData.Artists)
+		err := spotify.inSavepoint("top_artists", func() error {
+			return spotify.InsertTopArtists(APIData.Artists, dbData.Artists)
+		})
 		if err != nil {
 			logger.Log("Failed to insert top artists into the database", logger.Error)
 			return err
 		}
 	}
 
+	if spotify.Options.SavedLibrary {
+		logger.Log("Inserting all saved tracks", logger.Info)
+		err := spotify.inSavepoint("saved_tracks", func() error {
+			return spotify.InsertSavedTracks(APIData.SavedTracks, dbData.Songs)
+		})
+		if err != nil {
+			logger.Log("Failed to insert saved tracks into the database", logger.Error)
+			return err
+		}
+
+		logger.Log("Inserting all saved albums", logger.Info)
+		err = spotify.inSavepoint("saved_albums", func() error {
+			return spotify.InsertSavedAlbums(APIData.SavedAlbums, dbData.Albums)
+		})
+		if err != nil {
+			logger.Log("Failed to insert saved albums into the database", logger.Error)
+			return err
+		}
+	}
+
+	if spotify.Options.FollowedArtists {
+		logger.Log("Inserting all followed artists", logger.Info)
+		err := spotify.inSavepoint("followed_artists", func() error {
+			return spotify.InsertFollowedArtists(APIData.FollowedArtists, dbData.Artists)
+		})
+		if err != nil {
+			logger.Log("Failed to insert followed artists into the database", logger.Error)
+			return err
+		}
+	}
+
+	if spotify.Options.Playlists {
+		logger.Log("Inserting all changed playlist snapshots", logger.Info)
+		err := spotify.inSavepoint("playlist_snapshots", func() error {
+			return spotify.InsertPlaylistSnapshots(APIData.Playlists, dbData.Songs)
+		})
+		if err != nil {
+			logger.Log("Failed to insert playlist snapshots into the database", logger.Error)
+			return err
+		}
+	}
+
+	if spotify.Options.Podcasts {
+		logger.Log("Inserting changed episode resume points", logger.Info)
+		err := spotify.inSavepoint("episode_resume_points", func() error {
+			return spotify.InsertEpisodeResumePoints(APIData.SavedEpisodes, dbData.Episodes)
+		})
+		if err != nil {
+			logger.Log("Failed to insert episode resume points into the database", logger.Error)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -344,8 +577,21 @@ func BootstrapSpotifyingest(database IngestDatabase, api API, preingest *PreInge
 		RecentListen:       args.RecentListen,
 		TopSongs:           args.TopSongs,
 		TopArtists:         args.TopArtists,
+		SavedLibrary:       args.SavedLibrary,
+		FollowedArtists:    args.FollowedArtists,
+		Playlists:          args.Playlists,
+		Podcasts:           args.Podcasts,
 		UserID:             userId,
+		SpotifyUserID:      me.ID,
 		VariousArtistsUUID: variousArtistsId,
+		TopDepths:          args.TopDepths,
+
+		CatalogMaxAge:        args.CatalogMaxAge,
+		CatalogRefreshBudget: args.CatalogRefreshBudget,
+		RunID:                args.RunID,
+		CheckpointDir:        args.CheckpointDir,
+		FailurePolicy:        args.FailurePolicy,
+		Events:               args.Events,
 	}
 
 	return NewSpotifyIngest(database, api, options)
@@ -10,58 +10,76 @@ import (
 )
 
 // too many args should use struct tbh
-func (spotify *SpotifyIngest) InsertThumbnails(songs map[string]api.TopTracksResponse, recents api.RecentlyPlayedResponse, artists map[string]api.TopArtistsResponse, dbArtists []models.Artist, dbAlbums []models.Album) error {
+func (spotify *SpotifyIngest) InsertThumbnails(APIData APIData, dbData DBData) error {
+	dbArtists, dbAlbums := dbData.Artists, dbData.Albums
 	thumbnails := make(map[string]models.Thumbnail)
 	// TODO: normalize then iterate over one loop plss
-	for _, key := range utils.MapOrderedKeys(songs) {
-		for _, song := range songs[key].Items {
-			dbAlbum, exists := getAlbumBySpotifyID(dbAlbums, song.Album.ID)
-			if !exists {
-				logger.Log(fmt.Sprintf("Failed to attach album ID for album %s", song.Album.Name), logger.Warning)
-			}
-			for _, image := range song.Album.Images {
-				thumbnail := models.NewThumbnail("Album", "", image.URL, image.Height, image.Width)
-				spotify.OnNewEntityEvent(&thumbnail)
-				if exists {
-					thumbnail.EntityID = dbAlbum.ID
-				}
-				thumbnails[thumbnail.UniqueID()] = thumbnail
-			}
+	for _, key := range utils.MapOrderedKeys(APIData.Songs) {
+		for _, song := range APIData.Songs[key].Items {
+			spotify.addAlbumThumbnails(thumbnails, dbAlbums, song.Album.ID, song.Album.Name, song.Album.Images)
 		}
 	}
 
-	for _, key := range utils.MapOrderedKeys(artists) {
-		for _, artist := range artists[key].Items {
-			dbArtist, exists := getArtistBySpotifyID(dbArtists, artist.ID)
-			if !exists {
-				logger.Log(fmt.Sprintf("Failed to attach artist ID for artist %s", artist.Name), logger.Warning)
-			}
-			for _, image := range artist.Images {
-				thumbnail := models.NewThumbnail("Artist", "", image.URL, image.Height, image.Width)
-				spotify.OnNewEntityEvent(&thumbnail)
-				if exists {
-					thumbnail.EntityID = dbArtist.ID
-				}
-				thumbnails[thumbnail.UniqueID()] = thumbnail
-			}
+	for _, key := range utils.MapOrderedKeys(APIData.Artists) {
+		for _, artist := range APIData.Artists[key].Items {
+			spotify.addArtistThumbnails(thumbnails, dbArtists, artist.ID, artist.Name, artist.Images)
 		}
 	}
 
-	for _, song := range recents.Items {
-		dbAlbum, exists := getAlbumBySpotifyID(dbAlbums, song.Track.Album.ID)
-		if !exists {
-			logger.Log(fmt.Sprintf("Failed to attach album ID for track %s", song.Track.Album.Name), logger.Warning)
+	for _, song := range APIData.Recents.Items {
+		spotify.addAlbumThumbnails(thumbnails, dbAlbums, song.Track.Album.ID, song.Track.Album.Name, song.Track.Album.Images)
+	}
+
+	for _, saved := range APIData.SavedTracks.Items {
+		spotify.addAlbumThumbnails(thumbnails, dbAlbums, saved.Track.Album.ID, saved.Track.Album.Name, saved.Track.Album.Images)
+	}
+
+	for _, saved := range APIData.SavedAlbums.Items {
+		spotify.addAlbumThumbnails(thumbnails, dbAlbums, saved.Album.ID, saved.Album.Name, saved.Album.Images)
+	}
+
+	for _, artist := range APIData.FollowedArtists.Artists.Items {
+		spotify.addArtistThumbnails(thumbnails, dbArtists, artist.ID, artist.Name, artist.Images)
+	}
+
+	for _, playlist := range APIData.Playlists {
+		for _, item := range playlist.Items {
+			spotify.addAlbumThumbnails(thumbnails, dbAlbums, item.Track.Album.ID, item.Track.Album.Name, item.Track.Album.Images)
 		}
-		for _, image := range song.Track.Album.Images {
-			thumbnail := models.NewThumbnail("Album", "", image.URL, image.Height, image.Width)
-			spotify.OnNewEntityEvent(&thumbnail)
-			if exists {
-				thumbnail.EntityID = dbAlbum.ID
+	}
+
+	for _, song := range APIData.Tracks {
+		spotify.addAlbumThumbnails(thumbnails, dbAlbums, song.Album.ID, song.Album.Name, song.Album.Images)
+	}
+
+	for _, saved := range APIData.SavedShows.Items {
+		spotify.addShowThumbnails(thumbnails, dbData.Shows, saved.Show)
+	}
+
+	for _, saved := range APIData.SavedEpisodes.Items {
+		spotify.addShowThumbnails(thumbnails, dbData.Shows, saved.Episode.Show)
+		spotify.addEpisodeThumbnails(thumbnails, dbData.Episodes, saved.Episode)
+	}
+
+	for _, episode := range APIData.Episodes {
+		spotify.addShowThumbnails(thumbnails, dbData.Shows, episode.Show)
+		spotify.addEpisodeThumbnails(thumbnails, dbData.Episodes, episode)
+	}
+
+	// Artists only referenced through songs and recents get their images from the artist lookup
+	for _, artist := range dbArtists {
+		for _, thumbnail := range artist.Thumbnails {
+			if _, exists := thumbnails[thumbnail.UniqueID()]; !exists {
+				thumbnails[thumbnail.UniqueID()] = thumbnail
 			}
-			thumbnails[thumbnail.UniqueID()] = thumbnail
 		}
 	}
 
+	if len(thumbnails) == 0 {
+		logger.Log("No thumbnails to ingest", logger.Debug)
+		return nil
+	}
+
 	entityIDs := make([]interface{}, len(thumbnails))
 	for _, thumbnail := range thumbnails {
 		entityIDs = append(entityIDs, thumbnail.EntityID)
@@ -73,12 +91,9 @@ func (spotify *SpotifyIngest) InsertThumbnails(songs map[string]api.TopTracksRes
 	}
 
 	thumbnailsToInsert := []interface{}{}
-	for _, key := range utils.MapOrderedKeys(thumbnails) {
-		thumbnail := thumbnails[key]
-		_, exists := getThumbnailByEntityIDAndDimensions(dbThumbnails, thumbnail.EntityID, thumbnail.Height, thumbnail.Width)
-		if !exists {
-			thumbnailsToInsert = append(thumbnailsToInsert, utils.ReflectValues(thumbnail)...)
-		}
+	newThumbnails, supersededIDs := thumbnailVersions(thumbnails, dbThumbnails)
+	for _, thumbnail := range newThumbnails {
+		thumbnailsToInsert = append(thumbnailsToInsert, utils.ReflectValues(thumbnail)...)
 	}
 
 	if len(thumbnailsToInsert) == 0 {
@@ -91,5 +106,86 @@ func (spotify *SpotifyIngest) InsertThumbnails(songs map[string]api.TopTracksRes
 	if err != nil {
 		return err
 	}
+
+	for i := range newThumbnails {
+		spotify.OnNewEntityEvent(&newThumbnails[i])
+	}
+
+	if len(supersededIDs) > 0 {
+		logger.Log(fmt.Sprintf("Superseding %d changed thumbnails", len(supersededIDs)), logger.Debug)
+		err = spotify.Database.SetTimeByIDs(&models.Thumbnail{}, "superseded_at", supersededIDs, utils.NewTime())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// thumbnailVersions returns the thumbnails that need inserting along with the ids of the current rows they replace. A
+// different url at the same size is a new version of the image, the old row is superseded rather than updated so the
+// history is kept
+func thumbnailVersions(thumbnails map[string]models.Thumbnail, dbThumbnails []models.Thumbnail) ([]models.Thumbnail, []interface{}) {
+	newThumbnails := []models.Thumbnail{}
+	supersededIDs := []interface{}{}
+	for _, key := range utils.MapOrderedKeys(thumbnails) {
+		thumbnail := thumbnails[key]
+		current, exists := getThumbnailByEntityIDAndDimensions(dbThumbnails, thumbnail.EntityID, thumbnail.Height, thumbnail.Width)
+		if exists && current.URL == thumbnail.URL {
+			continue
+		}
+		if exists {
+			logger.Log(fmt.Sprintf("Thumbnail %s changed from %s to %s", key, current.URL, thumbnail.URL), logger.Trace)
+			supersededIDs = append(supersededIDs, current.ID)
+		}
+		newThumbnails = append(newThumbnails, thumbnail)
+	}
+	return newThumbnails, supersededIDs
+}
+
+func (spotify *SpotifyIngest) addAlbumThumbnails(thumbnails map[string]models.Thumbnail, dbAlbums []models.Album, albumSpotifyID string, albumName string, images []api.Image) {
+	dbAlbum, exists := getAlbumBySpotifyID(dbAlbums, albumSpotifyID)
+	if !exists {
+		spotify.warn(fmt.Sprintf("Failed to attach album ID for album %s, skipping its thumbnails", albumName))
+		return
+	}
+	for _, image := range images {
+		thumbnail := models.NewThumbnail("Album", dbAlbum.ID, image.URL, image.Height, image.Width)
+		thumbnails[thumbnail.UniqueID()] = thumbnail
+	}
+}
+
+func (spotify *SpotifyIngest) addArtistThumbnails(thumbnails map[string]models.Thumbnail, dbArtists []models.Artist, artistSpotifyID string, artistName string, images []api.Image) {
+	dbArtist, exists := getArtistBySpotifyID(dbArtists, artistSpotifyID)
+	if !exists {
+		spotify.warn(fmt.Sprintf("Failed to attach artist ID for artist %s, skipping its thumbnails", artistName))
+		return
+	}
+	for _, image := range images {
+		thumbnail := models.NewThumbnail("Artist", dbArtist.ID, image.URL, image.Height, image.Width)
+		thumbnails[thumbnail.UniqueID()] = thumbnail
+	}
+}
+
+func (spotify *SpotifyIngest) addShowThumbnails(thumbnails map[string]models.Thumbnail, dbShows []models.Show, show api.Show) {
+	dbShow, exists := getShowBySpotifyID(dbShows, show.ID)
+	if !exists {
+		spotify.warn(fmt.Sprintf("Failed to attach show ID for show %s, skipping its thumbnails", show.Name))
+		return
+	}
+	for _, image := range show.Images {
+		thumbnail := models.NewThumbnail("Show", dbShow.ID, image.URL, image.Height, image.Width)
+		thumbnails[thumbnail.UniqueID()] = thumbnail
+	}
+}
+
+func (spotify *SpotifyIngest) addEpisodeThumbnails(thumbnails map[string]models.Thumbnail, dbEpisodes []models.Episode, episode api.Episode) {
+	dbEpisode, exists := getEpisodeBySpotifyID(dbEpisodes, episode.ID)
+	if !exists {
+		spotify.warn(fmt.Sprintf("Failed to attach episode ID for episode %s, skipping its thumbnails", episode.Name))
+		return
+	}
+	for _, image := range episode.Images {
+		thumbnail := models.NewThumbnail("Episode", dbEpisode.ID, image.URL, image.Height, image.Width)
+		thumbnails[thumbnail.UniqueID()] = thumbnail
+	}
+}
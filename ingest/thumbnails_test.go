@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+)
+
+func TestThumbnailVersions(t *testing.T) {
+	current := models.Thumbnail{ID: "old", Entity: "Artist", EntityID: "artist", URL: "https://i.scdn.co/image/a", Width: 640, Height: 640}
+	small := models.Thumbnail{ID: "small", Entity: "Artist", EntityID: "artist", URL: "https://i.scdn.co/image/b", Width: 160, Height: 160}
+	dbThumbnails := []models.Thumbnail{current, small}
+
+	tests := []struct {
+		name       string
+		thumbnail  models.Thumbnail
+		inserted   bool
+		superseded []interface{}
+	}{
+		{"Unchanged", models.Thumbnail{ID: "new", EntityID: "artist", URL: current.URL, Width: 640, Height: 640}, false, []interface{}{}},
+		{"Changed url", models.Thumbnail{ID: "new", EntityID: "artist", URL: "https://i.scdn.co/image/c", Width: 640, Height: 640}, true, []interface{}{"old"}},
+		{"New size", models.Thumbnail{ID: "new", EntityID: "artist", URL: current.URL, Width: 300, Height: 300}, true, []interface{}{}},
+		{"New entity", models.Thumbnail{ID: "new", EntityID: "other", URL: current.URL, Width: 640, Height: 640}, true, []interface{}{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thumbnails := map[string]models.Thumbnail{test.thumbnail.UniqueID(): test.thumbnail}
+			inserted, superseded := thumbnailVersions(thumbnails, dbThumbnails)
+			if test.inserted != (len(inserted) == 1) {
+				t.Errorf("Expected inserted to be %t, got %+v", test.inserted, inserted)
+			}
+			if len(superseded) != len(test.superseded) || (len(superseded) == 1 && superseded[0] != test.superseded[0]) {
+				t.Errorf("Expected %v to be superseded, got %v", test.superseded, superseded)
+			}
+		})
+	}
+}
+
+func TestInsertThumbnails_skipsUnattached(t *testing.T) {
+	stored := models.NewAlbum("Stored", "artist-spotify-id", "stored-spotify-id", false)
+	APIData := APIData{}
+	for _, id := range []string{"stored-spotify-id", "missing-spotify-id"} {
+		album := api.Album{ID: id, Images: []api.Image{{URL: "https://i.scdn.co/image/" + id, Height: 640, Width: 640}}}
+		APIData.SavedAlbums.Items = append(APIData.SavedAlbums.Items, api.SavedAlbum{Album: album})
+	}
+
+	db := database.NewMockDatabase()
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{})
+	err := spotify.InsertThumbnails(APIData, DBData{Albums: []models.Album{stored}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := utils.ReflectColumns(&models.Thumbnail{})
+	values := db.SavedValues["Thumbnail"]
+	if len(values) != len(columns) {
+		t.Fatalf("Expected only the stored albums thumbnail inserted, got %v", values)
+	}
+	for i, column := range columns {
+		if column == "entity_id" && values[i] != stored.ID {
+			t.Errorf("Expected the thumbnail attached to %s, got %v", stored.ID, values[i])
+		}
+	}
+}
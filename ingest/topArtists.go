@@ -9,36 +9,68 @@ import (
 	"github.com/batzz-00/goutils/logger"
 )
 
+// InsertTopArtists is InsertTopSongs for top artists
 func (spotify *SpotifyIngest) InsertTopArtists(topArtists map[string]api.TopArtistsResponse, dbArtists []models.Artist) error {
 	topArtistDataValues := []interface{}{}
 	topArtistValues := []interface{}{}
-	newTopArtist := models.NewTopArtist(spotify.Options.UserID)
-	spotify.OnNewEntityEvent(&newTopArtist)
+	changes := []models.TopListChange{}
+	references := []models.UnresolvedReference{}
+	// unresolved holds the spotify id of each data row whose artist couldn't be found
+	unresolved := map[string]string{}
+
+	previous, err := spotify.previousTopLists(models.TopListArtist)
+	if err != nil {
+		return err
+	}
 
+	newTopArtist := models.NewTopArtist(spotify.Options.UserID)
 	for term, resp := range topArtists {
+		termData := []models.TopArtistData{}
+		artistIDs := []string{}
 		for i, artist := range resp.Items {
 			dbArtist, exists := getArtistBySpotifyID(dbArtists, artist.ID)
 			newTopArtistData := models.NewTopArtistData(artist.Name, "", i+1, term, newTopArtist.ID)
-			spotify.OnNewEntityEvent(&newTopArtistData)
 			if exists {
 				newTopArtistData.ArtistID = dbArtist.ID
 			} else {
-				logger.Log(fmt.Sprintf("Failed to attach artist ID for artist %s", artist.Name), logger.Warning)
+				unresolved[newTopArtistData.ID] = artist.ID
+			}
+
+			termData = append(termData, newTopArtistData)
+			artistIDs = append(artistIDs, newTopArtistData.ArtistID)
+		}
+
+		termChanges := topListChanges(spotify.Options.UserID, models.TopListArtist, term, newTopArtist.ID, previous[term], artistIDs)
+		if _, exists := previous[term]; exists {
+			if len(termChanges) == 0 {
+				logger.Log(fmt.Sprintf("Top artists for %s term unchanged since last snapshot, skipping", term), logger.Debug)
+				continue
 			}
-			topArtistDataValues = append(topArtistDataValues, utils.ReflectValues(newTopArtistData)...)
+			changes = append(changes, termChanges...)
+		}
+
+		for _, data := range termData {
+			spotify.OnNewEntityEvent(&data)
+			values := utils.ReflectValues(data)
+			if spotifyID, missing := unresolved[data.ID]; missing {
+				references = append(references, spotify.deferReference(&data, values, data.ID, "artist_id", models.EntityArtist, spotifyID))
+			}
+			topArtistDataValues = append(topArtistDataValues, values...)
 		}
 	}
 
-	topArtistValues = append(topArtistValues, utils.ReflectValues(newTopArtist)...)
 	if len(topArtistDataValues) == 0 {
 		logger.Log("No top artist data to ingest.", logger.Info)
 		return nil
 	}
 
+	spotify.OnNewEntityEvent(&newTopArtist)
+	topArtistValues = append(topArtistValues, utils.ReflectValues(newTopArtist)...)
+
 	logger.Log("Inserting new top artist record", logger.Info)
 	topArtistRecords := len(topArtistValues) / len(utils.ReflectColumns(&models.TopArtist{}))
 	logger.Log(fmt.Sprintf("Inserting %d top_artist records", topArtistRecords), logger.Debug)
-	err := spotify.Database.Create(&models.TopArtist{}, topArtistValues)
+	err = spotify.Database.Create(&models.TopArtist{}, topArtistValues)
 	if err != nil {
 		return err
 	}
@@ -51,5 +83,10 @@ func (spotify *SpotifyIngest) InsertTopArtists(topArtists map[string]api.TopArti
 		return err
 	}
 
-	return nil
+	err = spotify.insertUnresolvedReferences(references)
+	if err != nil {
+		return err
+	}
+
+	return spotify.insertTopListChanges(changes)
 }
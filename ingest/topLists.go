@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// previousTopLists groups the ranks of the users latest snapshot for each term, ready for topListChanges
+func (spotify *SpotifyIngest) previousTopLists(listType string) (map[string][]models.TopListEntry, error) {
+	entries, err := spotify.Database.FetchLatestTopListEntries(spotify.Options.UserID, listType)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := map[string][]models.TopListEntry{}
+	for _, entry := range entries {
+		terms[entry.TimePeriod] = append(terms[entry.TimePeriod], entry)
+	}
+	return terms, nil
+}
+
+// topListChanges compares a terms fresh ranking, item ids in rank order, against the previous snapshots. Items that
+// couldn't be attached ("") are left out, so an empty result means the list is unchanged
+func topListChanges(userID string, listType string, term string, snapshotID string, previous []models.TopListEntry, current []string) []models.TopListChange {
+	previousRanks := map[string]int{}
+	for _, entry := range previous {
+		if entry.ItemID != "" {
+			previousRanks[entry.ItemID] = entry.Order
+		}
+	}
+
+	changes := []models.TopListChange{}
+	for i, itemID := range current {
+		if itemID == "" {
+			continue
+		}
+
+		rank := i + 1
+		previousRank, exists := previousRanks[itemID]
+		delete(previousRanks, itemID)
+		switch {
+		case !exists:
+			changes = append(changes, models.NewTopListChange(userID, listType, term, itemID, snapshotID, 0, rank, models.TopListMovementNew))
+		case rank < previousRank:
+			changes = append(changes, models.NewTopListChange(userID, listType, term, itemID, snapshotID, previousRank, rank, models.TopListMovementUp))
+		case rank > previousRank:
+			changes = append(changes, models.NewTopListChange(userID, listType, term, itemID, snapshotID, previousRank, rank, models.TopListMovementDown))
+		}
+	}
+
+	for _, entry := range previous {
+		if _, dropped := previousRanks[entry.ItemID]; dropped {
+			changes = append(changes, models.NewTopListChange(userID, listType, term, entry.ItemID, snapshotID, entry.Order, 0, models.TopListMovementDropped))
+		}
+	}
+
+	return changes
+}
+
+func (spotify *SpotifyIngest) insertTopListChanges(changes []models.TopListChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changeValues := []interface{}{}
+	for _, change := range changes {
+		spotify.OnNewEntityEvent(&change)
+		changeValues = append(changeValues, utils.ReflectValues(change)...)
+	}
+
+	logger.Log(fmt.Sprintf("Inserting %d top_list_changes records", len(changes)), logger.Debug)
+	return spotify.Database.Create(&models.TopListChange{}, changeValues)
+}
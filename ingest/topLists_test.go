@@ -0,0 +1,40 @@
+package ingest
+
+import (
+	"fmt"
+	"spotify/models"
+	"testing"
+)
+
+func TestTopListChanges(t *testing.T) {
+	previous := []models.TopListEntry{
+		{TimePeriod: "short", ItemID: "a", Order: 1},
+		{TimePeriod: "short", ItemID: "b", Order: 2},
+		{TimePeriod: "short", ItemID: "c", Order: 3},
+	}
+
+	tests := []struct {
+		name     string
+		current  []string
+		expected []string
+	}{
+		{"Unchanged", []string{"a", "b", "c"}, []string{}},
+		{"Unattached items are ignored", []string{"a", "b", "c", ""}, []string{}},
+		{"Swapped", []string{"b", "a", "c"}, []string{"b up 2->1", "a down 1->2"}},
+		{"New entry pushes one out", []string{"d", "a", "b"}, []string{"d new 0->1", "a down 1->2", "b down 2->3", "c dropped 3->0"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := topListChanges("user", models.TopListSong, "short", "snapshot", previous, test.current)
+
+			actual := []string{}
+			for _, change := range changes {
+				actual = append(actual, fmt.Sprintf("%s %s %d->%d", change.ItemID, change.Movement, change.PreviousRank.Int32, change.Rank.Int32))
+			}
+			if fmt.Sprint(actual) != fmt.Sprint(test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
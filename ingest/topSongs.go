@@ -9,38 +9,69 @@ import (
 	"github.com/batzz-00/goutils/logger"
 )
 
+// InsertTopSongs stores a new top songs snapshot holding only the terms whose ranking changed since the previous
+// snapshot, unchanged terms stay represented by the earlier snapshot, along with the rank movements
 func (spotify *SpotifyIngest) InsertTopSongs(songs map[string]api.TopTracksResponse, dbSongs []models.Song) error {
 	topSongDataValues := []interface{}{}
 	topSongValues := []interface{}{}
+	changes := []models.TopListChange{}
+	references := []models.UnresolvedReference{}
+	// unresolved holds the spotify id of each data row whose song couldn't be found
+	unresolved := map[string]string{}
+
+	previous, err := spotify.previousTopLists(models.TopListSong)
+	if err != nil {
+		return err
+	}
 
 	topSong := models.NewTopSong(spotify.Options.UserID)
-	spotify.OnNewEntityEvent(&topSong)
 	for term, resp := range songs {
+		termData := []models.TopSongData{}
+		songIDs := []string{}
 		for i, song := range resp.Items {
 			dbSong, exists := getSongBySpotifyID(dbSongs, song.ID)
 			newTopSongData := models.NewTopSongData(topSong.ID, "", i+1, term)
-			spotify.OnNewEntityEvent(&newTopSongData)
 			if exists {
 				newTopSongData.SongID = dbSong.ID
 			} else {
-				logger.Log(fmt.Sprintf("Failed to attach song ID for song %s", song.Name), logger.Warning)
+				unresolved[newTopSongData.ID] = song.ID
 			}
 
-			topSongDataValues = append(topSongDataValues, utils.ReflectValues(newTopSongData)...)
+			termData = append(termData, newTopSongData)
+			songIDs = append(songIDs, newTopSongData.SongID)
 		}
-	}
 
-	topSongValues = append(topSongValues, utils.ReflectValues(topSong)...)
+		termChanges := topListChanges(spotify.Options.UserID, models.TopListSong, term, topSong.ID, previous[term], songIDs)
+		if _, exists := previous[term]; exists {
+			if len(termChanges) == 0 {
+				logger.Log(fmt.Sprintf("Top songs for %s term unchanged since last snapshot, skipping", term), logger.Debug)
+				continue
+			}
+			changes = append(changes, termChanges...)
+		}
+
+		for _, data := range termData {
+			spotify.OnNewEntityEvent(&data)
+			values := utils.ReflectValues(data)
+			if spotifyID, missing := unresolved[data.ID]; missing {
+				references = append(references, spotify.deferReference(&data, values, data.ID, "song_id", models.EntitySong, spotifyID))
+			}
+			topSongDataValues = append(topSongDataValues, values...)
+		}
+	}
 
 	if len(topSongDataValues) == 0 {
 		logger.Log("No top song data to ingest.", logger.Info)
 		return nil
 	}
 
+	spotify.OnNewEntityEvent(&topSong)
+	topSongValues = append(topSongValues, utils.ReflectValues(topSong)...)
+
 	topSongRecordsToInsert := len(topSongValues) / len(utils.ReflectColumns(topSong))
 	logger.Log("Inserting new top song record", logger.Info)
 	logger.Log(fmt.Sprintf("Inserting %d top_song records", topSongRecordsToInsert), logger.Debug)
-	err := spotify.Database.Create(&models.TopSong{}, topSongValues)
+	err = spotify.Database.Create(&models.TopSong{}, topSongValues)
 	if err != nil {
 		return err
 	}
@@ -53,5 +84,10 @@ func (spotify *SpotifyIngest) InsertTopSongs(songs map[string]api.TopTracksRespo
 		return err
 	}
 
-	return nil
+	err = spotify.insertUnresolvedReferences(references)
+	if err != nil {
+		return err
+	}
+
+	return spotify.insertTopListChanges(changes)
 }
@@ -14,7 +14,7 @@ func (spotify *SpotifyIngest) Tracks() (map[string]api.TopTracksResponse, error)
 
 	for _, period := range spotify.Times {
 		logger.Log(fmt.Sprintf("Processing %s_term time range for tracks endpoint", period), logger.Debug)
-		tracks, err := spotify.API.TopTracksForUser(period + "_term")
+		tracks, err := spotify.API.TopTracksForUser(period+"_term", spotify.topDepth(period))
 		if err != nil {
 			return nil, err
 		}
@@ -24,25 +24,48 @@ func (spotify *SpotifyIngest) Tracks() (map[string]api.TopTracksResponse, error)
 	return topTrackResp, nil
 }
 
-func (spotify *SpotifyIngest) PopulateTracks(songs map[string]api.TopTracksResponse, recents api.RecentlyPlayedResponse) ([]models.Song, error) {
+func (spotify *SpotifyIngest) PopulateTracks(APIData APIData) ([]models.Song, error) {
 	// Songs to attempt to fetch from DB
 	songSpotifyIDs := utils.NewStringArgs()
-	for _, resp := range songs {
+	for _, resp := range APIData.Songs {
 		for _, song := range resp.Items {
 			songSpotifyIDs.Add(song.ID)
 		}
 	}
 
-	for _, song := range recents.Items {
+	for _, song := range APIData.Recents.Items {
 		songSpotifyIDs.Add(song.Track.ID)
 	}
 
+	for _, saved := range APIData.SavedTracks.Items {
+		songSpotifyIDs.Add(saved.Track.ID)
+	}
+
+	for _, playlist := range APIData.Playlists {
+		for _, item := range playlist.Items {
+			songSpotifyIDs.Add(item.Track.ID)
+		}
+	}
+
+	for _, song := range APIData.Tracks {
+		songSpotifyIDs.Add(song.ID)
+	}
+
+	if len(songSpotifyIDs.UniqueMap) == 0 {
+		logger.Log("No song references to populate", logger.Debug)
+		return []models.Song{}, nil
+	}
+
 	logger.Log(fmt.Sprintf("Querying database for %d songs", len(songSpotifyIDs.Args())), logger.Debug)
 	dbSongs, err := spotify.Database.FetchSongsBySpotifyID(songSpotifyIDs.Args())
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range dbSongs {
+		spotify.OnNewSong(&dbSongs[i], false)
+	}
+
 	// Songs to attempt to fetch from API
 	dbSongIds := utils.NewStringArgsFromModel(dbSongs)
 	diffedIds := songSpotifyIDs.Diff(dbSongIds)
@@ -54,14 +77,35 @@ func (spotify *SpotifyIngest) PopulateTracks(songs map[string]api.TopTracksRespo
 		return dbSongs, nil
 	}
 
-	logger.Log(fmt.Sprintf("Fetching %d songs from spotify api", len(songSpotifyIDs.Args())), logger.Debug)
-	apiSongs, err := spotify.API.TracksBySpotifyID(songsToFetch)
-	if err != nil {
-		return nil, err
+	// loose tracks are already full track objects, no need to ask the api for them again
+	apiSongs := []api.Song{}
+	remainingToFetch := []string{}
+Outer:
+	for _, id := range songsToFetch {
+		for _, song := range APIData.Tracks {
+			if song.ID == id {
+				apiSongs = append(apiSongs, song)
+				continue Outer
+			}
+		}
+		remainingToFetch = append(remainingToFetch, id)
+	}
+
+	if len(remainingToFetch) > 0 {
+		logger.Log(fmt.Sprintf("Fetching %d songs from spotify api", len(remainingToFetch)), logger.Debug)
+		fetchedSongs, err := spotify.API.TracksBySpotifyID(remainingToFetch)
+		if err != nil {
+			return nil, err
+		}
+		apiSongs = append(apiSongs, fetchedSongs...)
 	}
 
 	for _, song := range apiSongs {
-		newSong := models.NewSong(song.Name, song.ID, song.Album.ID, song.Artists[0].ID, true)
+		// spotify returns null for ids it no longer knows about
+		if song.ID == "" || len(song.Artists) == 0 {
+			continue
+		}
+		newSong := models.NewSong(song.Name, song.ID, song.Album.ID, song.Artists[0].ID, int(song.DurationMs), true)
 		spotify.OnNewSong(&newSong, true)
 		dbSongs = append(dbSongs, newSong)
 	}
@@ -71,34 +115,41 @@ func (spotify *SpotifyIngest) PopulateTracks(songs map[string]api.TopTracksRespo
 
 func (spotify *SpotifyIngest) AttachTrackUUIDs(songs []models.Song, artists []models.Artist, albums []models.Album) ([]models.Song, error) {
 	songValues := []interface{}{}
+	references := []models.UnresolvedReference{}
 	for i, song := range songs {
 		if !song.NeedsUpdate {
 			continue
 		}
 
+		artistSpotifyID, artistExists := songs[i].ArtistID, false
 		for _, artist := range artists {
 			if songs[i].ArtistID == artist.SpotifyID {
 				songs[i].ArtistID = artist.ID
+				artistExists = true
 				break
 			}
 		}
 
-		if len(songs[i].ArtistID) != 36 {
-			logger.Log(fmt.Sprintf("Artist with spotify ID %s was not fetched from spotify", songs[i].ArtistID), logger.Warning)
-		}
-
+		albumSpotifyID, albumExists := songs[i].AlbumID, false
 		for _, album := range albums {
 			if songs[i].AlbumID == album.SpotifyID {
 				songs[i].AlbumID = album.ID
+				albumExists = true
 				break
 			}
 		}
 
-		if len(songs[i].AlbumID) != 36 {
-			logger.Log(fmt.Sprintf("Album with spotify ID %s was not fetched from spotify", songs[i].AlbumID), logger.Warning)
+		// spotify ids mustn't reach the uuid columns, they're left null until resolved
+		values := utils.ReflectValues(songs[i])
+		if !artistExists {
+			songs[i].ArtistID = ""
+			references = append(references, spotify.deferReference(&songs[i], values, songs[i].ID, "artist_id", models.EntityArtist, artistSpotifyID))
 		}
-
-		songValues = append(songValues, utils.ReflectValues(songs[i])...)
+		if !albumExists {
+			songs[i].AlbumID = ""
+			references = append(references, spotify.deferReference(&songs[i], values, songs[i].ID, "album_id", models.EntityAlbum, albumSpotifyID))
+		}
+		songValues = append(songValues, values...)
 	}
 
 	if len(songValues) == 0 {
@@ -113,5 +164,50 @@ func (spotify *SpotifyIngest) AttachTrackUUIDs(songs []models.Song, artists []mo
 		return nil, err
 	}
 
+	err = spotify.insertUnresolvedReferences(references)
+	if err != nil {
+		return nil, err
+	}
+
 	return songs, nil
 }
+
+// ResolveTracks returns songs for every track id, creating any missing songs (and their albums and artists)
+// through the same populate and attach flow the regular ingest uses
+func (spotify *SpotifyIngest) ResolveTracks(trackSpotifyIDs utils.StringArgs) ([]models.Song, error) {
+	dbSongs, err := spotify.Database.FetchSongsBySpotifyID(trackSpotifyIDs.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	dbSongIDs := utils.NewStringArgsFromModel(dbSongs)
+	missingIDs := trackSpotifyIDs.Diff(dbSongIDs)
+	if len(missingIDs.UniqueMap) == 0 {
+		return dbSongs, nil
+	}
+
+	logger.Log(fmt.Sprintf("Fetching %d unseen tracks from spotify api", len(missingIDs.UniqueMap)), logger.Debug)
+	tracks, err := spotify.API.TracksBySpotifyID(missingIDs.ToString())
+	if err != nil {
+		return nil, err
+	}
+
+	APIData := APIData{Tracks: []api.Song{}}
+	for _, track := range tracks {
+		if track.ID != "" {
+			APIData.Tracks = append(APIData.Tracks, track)
+		}
+	}
+
+	relatedData, err := spotify.FetchRelated(APIData)
+	if err != nil {
+		return nil, err
+	}
+
+	relatedData, err = spotify.AttachAndInsertFreshData(APIData, relatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dbSongs, relatedData.Songs...), nil
+}
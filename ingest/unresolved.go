@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"database/sql"
+	"fmt"
+	"spotify/api"
+	"spotify/models"
+	"spotify/utils"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// deferReference nulls column in the models reflected values, so the row can be inserted without the reference, and
+// returns the unresolved reference the resolve command later uses to fill it in
+func (spotify *SpotifyIngest) deferReference(model models.Model, values []interface{}, sourceID string, column string, missingKind string, spotifyID string) models.UnresolvedReference {
+	for i, name := range utils.ReflectColumns(model) {
+		if name == column {
+			values[i] = sql.NullString{}
+		}
+	}
+
+	spotify.warn(fmt.Sprintf("Deferring %s.%s for %s, %s %s not found", model.TableName(), column, sourceID, missingKind, spotifyID))
+	reference := models.NewUnresolvedReference(spotify.Options.RunID, model.TableName(), sourceID, column, missingKind, spotifyID)
+	spotify.OnNewEntityEvent(&reference)
+	return reference
+}
+
+func (spotify *SpotifyIngest) insertUnresolvedReferences(references []models.UnresolvedReference) error {
+	if len(references) == 0 {
+		return nil
+	}
+
+	referenceValues := []interface{}{}
+	for _, reference := range references {
+		referenceValues = append(referenceValues, utils.ReflectValues(reference)...)
+	}
+
+	logger.Log(fmt.Sprintf("Inserting %d unresolved_reference records", len(references)), logger.Debug)
+	return spotify.Database.Create(&models.UnresolvedReference{}, referenceValues)
+}
+
+// ResolveReferences retries up to limit unresolved references through the regular populate and attach flow, patching
+// the referencing rows of any it finds. References spotify still doesn't know about have the miss counted and are left
+// for a later run
+func (spotify *SpotifyIngest) ResolveReferences(limit int) (int, error) {
+	references, err := spotify.Database.FetchUnresolvedReferences(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(references) == 0 {
+		logger.Log("No unresolved references to retry", logger.Info)
+		return 0, nil
+	}
+
+	spotifyIDs := map[string]utils.StringArgs{}
+	for _, reference := range references {
+		if _, exists := spotifyIDs[reference.MissingKind]; !exists {
+			spotifyIDs[reference.MissingKind] = utils.NewStringArgs()
+		}
+		ids := spotifyIDs[reference.MissingKind]
+		ids.Add(reference.SpotifyID)
+	}
+
+	resolvedIDs := map[string]map[string]string{}
+	for _, kind := range utils.MapOrderedKeys(spotifyIDs) {
+		logger.Log(fmt.Sprintf("Resolving %d %s references", len(spotifyIDs[kind].UniqueMap), kind), logger.Info)
+		resolvedIDs[kind], err = spotify.ResolveSpotifyIDs(kind, spotifyIDs[kind])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	resolved := 0
+	missed := []interface{}{}
+	at := utils.NewTime()
+	for _, reference := range references {
+		id, exists := resolvedIDs[reference.MissingKind][reference.SpotifyID]
+		if !exists {
+			spotify.warn(fmt.Sprintf("Still unable to resolve %s %s for %s %s", reference.MissingKind, reference.SpotifyID, reference.SourceTable, reference.SourceID))
+			missed = append(missed, reference.ID)
+			continue
+		}
+
+		err = spotify.Database.ResolveReference(reference, id, at)
+		if err != nil {
+			return resolved, err
+		}
+		resolved++
+	}
+
+	if len(missed) == 0 {
+		return resolved, nil
+	}
+
+	return resolved, spotify.Database.RecordResolveAttempts(missed, at)
+}
+
+// ResolveSpotifyIDs returns the stored id for each spotify id of the kind (song, artist or album), creating any that
+// aren't stored yet
+func (spotify *SpotifyIngest) ResolveSpotifyIDs(kind string, spotifyIDs utils.StringArgs) (map[string]string, error) {
+	ids := map[string]string{}
+	switch kind {
+	case models.EntitySong:
+		songs, err := spotify.ResolveTracks(spotifyIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			ids[song.SpotifyID] = song.ID
+		}
+	case models.EntityArtist:
+		APIData := APIData{}
+		for _, id := range spotifyIDs.ToString() {
+			APIData.FollowedArtists.Artists.Items = append(APIData.FollowedArtists.Artists.Items, api.Artist{ID: id})
+		}
+		relatedData, err := spotify.resolveCatalog(APIData)
+		if err != nil {
+			return nil, err
+		}
+		for _, artist := range relatedData.Artists {
+			ids[artist.SpotifyID] = artist.ID
+		}
+	case models.EntityAlbum:
+		APIData := APIData{}
+		for _, id := range spotifyIDs.ToString() {
+			APIData.SavedAlbums.Items = append(APIData.SavedAlbums.Items, api.SavedAlbum{Album: api.Album{ID: id}})
+		}
+		relatedData, err := spotify.resolveCatalog(APIData)
+		if err != nil {
+			return nil, err
+		}
+		for _, album := range relatedData.Albums {
+			ids[album.SpotifyID] = album.ID
+		}
+	default:
+		return nil, fmt.Errorf("unknown reference kind %s", kind)
+	}
+	return ids, nil
+}
+
+// resolveCatalog runs bare artist and album references through the populate and attach flow, which looks up and
+// inserts any that aren't stored yet
+func (spotify *SpotifyIngest) resolveCatalog(APIData APIData) (DBData, error) {
+	relatedData, err := spotify.FetchRelated(APIData)
+	if err != nil {
+		return DBData{}, err
+	}
+	return spotify.AttachAndInsertFreshData(APIData, relatedData)
+}
@@ -0,0 +1,117 @@
+package ingest
+
+import (
+	"reflect"
+	"spotify/api"
+	"spotify/database"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+)
+
+func TestAttachTrackUUIDs_defersMissingReferences(t *testing.T) {
+	db := database.NewMockDatabase()
+	spotify := NewSpotifyIngest(&db, nil, SpotifyIngestOptions{RunID: "run"})
+
+	artist := models.NewArtist("Artist", "artist-spotify-id", false)
+	album := models.NewAlbum("Album", artist.ID, "album-spotify-id", false)
+	songs := []models.Song{
+		models.NewSong("Resolved", "resolved-spotify-id", "album-spotify-id", "artist-spotify-id", 180000, true),
+		models.NewSong("Missing artist", "missing-spotify-id", "album-spotify-id", "unknown-artist-id", 180000, true),
+	}
+
+	songs, err := spotify.AttachTrackUUIDs(songs, []models.Artist{artist}, []models.Album{album})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if songs[0].ArtistID != artist.ID || songs[0].AlbumID != album.ID {
+		t.Errorf("Expected the first song to be attached, got %+v", songs[0])
+	}
+	if songs[1].ArtistID != "" || songs[1].AlbumID != album.ID {
+		t.Errorf("Expected only the second songs artist to be left empty, got %+v", songs[1])
+	}
+
+	columns := utils.ReflectColumns(&models.Song{})
+	values := db.SavedValues["Song"]
+	for i, column := range columns {
+		if column == "artist_id" && values[len(columns)+i] != nil {
+			t.Errorf("Expected artist_id to be inserted as null, got %v", values[len(columns)+i])
+		}
+	}
+
+	references := db.SavedValues["UnresolvedReference"]
+	referenceColumns := utils.ReflectColumns(&models.UnresolvedReference{})
+	if len(references) != len(referenceColumns) {
+		t.Fatalf("Expected a single unresolved reference, got %v", references)
+	}
+
+	expected := map[string]interface{}{
+		"run_id":        "run",
+		"source_table":  "songs",
+		"source_id":     songs[1].ID,
+		"source_column": "artist_id",
+		"missing_kind":  models.EntityArtist,
+		"spotify_id":    "unknown-artist-id",
+	}
+	for i, column := range referenceColumns {
+		if value, exists := expected[column]; exists && references[i] != value {
+			t.Errorf("Expected %s to be %v, got %v", column, value, references[i])
+		}
+	}
+}
+
+// mockResolveDatabase serves the references to retry and records the ones whose attempt was counted
+type mockResolveDatabase struct {
+	*database.MockDatabase
+	references []models.UnresolvedReference
+	attempted  []interface{}
+}
+
+func (db *mockResolveDatabase) FetchUnresolvedReferences(limit int) ([]models.UnresolvedReference, error) {
+	return db.references, nil
+}
+
+func (db *mockResolveDatabase) RecordResolveAttempts(ids []interface{}, at utils.Time) error {
+	db.attempted = append(db.attempted, ids...)
+	return nil
+}
+
+// unknownCatalogAPI answers every artist and album lookup with the null entries spotify returns for unknown ids
+type unknownCatalogAPI struct {
+	API
+}
+
+func (a *unknownCatalogAPI) ArtistsBySpotifyID(ids []string) ([]api.Artist, error) {
+	return make([]api.Artist, len(ids)), nil
+}
+
+func (a *unknownCatalogAPI) AlbumsBySpotifyID(ids []string) ([]api.Album, error) {
+	return make([]api.Album, len(ids)), nil
+}
+
+func TestResolveReferences_unknownIDs(t *testing.T) {
+	artistReference := models.NewUnresolvedReference("run", "songs", "song", "artist_id", models.EntityArtist, "unknown-artist-id")
+	albumReference := models.NewUnresolvedReference("run", "songs", "song", "album_id", models.EntityAlbum, "unknown-album-id")
+
+	mock := database.NewMockDatabase()
+	db := mockResolveDatabase{MockDatabase: &mock, references: []models.UnresolvedReference{artistReference, albumReference}}
+	spotify := NewSpotifyIngest(&db, &unknownCatalogAPI{}, SpotifyIngestOptions{})
+
+	resolved, err := spotify.ResolveReferences(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != 0 {
+		t.Errorf("Expected nothing to be resolved, got %d", resolved)
+	}
+
+	if len(mock.SavedValues) != 0 {
+		t.Errorf("Expected no rows to be created for unknown ids, got %v", mock.SavedValues)
+	}
+
+	expected := []interface{}{artistReference.ID, albumReference.ID}
+	if !reflect.DeepEqual(db.attempted, expected) {
+		t.Errorf("Expected attempts to be counted for %v, got %v", expected, db.attempted)
+	}
+}
@@ -47,7 +47,7 @@ func TestIntegration(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	modelSlice := []models.Model{&models.Song{}, &models.Artist{}, &models.RecentListen{}, &models.Thumbnail{}, &models.User{}, &models.Album{}}
+	modelSlice := []models.Model{&models.Song{}, &models.Artist{}, &models.RecentListen{}, &models.Thumbnail{}, &models.User{}, &models.Album{}, &models.ArtistGenre{}}
 	expectedInserts := loadExpectedInserts("recent-listens", modelSlice)
 
 	dbBytes, _ := json.Marshal(db.SavedValues)
@@ -2,11 +2,15 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
 
 	"spotify/api"
 	"spotify/database"
 	"spotify/ingest"
 	"spotify/metrics"
+	"spotify/sessions"
+	"spotify/stats"
 
 	"github.com/batzz-00/goutils/logger"
 )
@@ -17,9 +21,20 @@ import (
 // more integration test cases (some data in db ^ network errors etc ^ calls to event logging)
 func main() {
 	logger.Setup(logger.Debug, nil, logger.NewLoggerOptions("2006-01-02 15:04:05"))
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	args := parseArgs()
 	env := LoadEnv(args.UserID)
 	args.EnvUsers = env.Users
+	args.CheckpointDir = env.CheckpointDir
+	if args.RunID != "" && args.CheckpointDir == "" {
+		log.Fatalf("checkpoint_dir must be set to resume a run!")
+	}
 
 	database := database.Database{Auth: env.DbAuth}
 	err := database.Connect()
@@ -31,21 +46,27 @@ func main() {
 	database.StartTX()
 
 	ingestContext := ingest.NewIngestContext(args)
+	args.RunID = ingestContext.Id
 	metricHandler, err := metrics.NewMetricHandler(env.LogstashAuth, env.ElasticAuth, ingestContext)
 	if err != nil {
 		logger.Log("Failed to make metrics handler", logger.Error)
 		panic(err)
 	}
 
-	var addIngestFinishedIndex = metricHandler.AddIngestFinishedIndex
-	var addOnNewEntityIndex = metricHandler.AddNewModel
-	args.Events = ingest.SpotifyIngestEvents{
-		OnNewEntity: &addOnNewEntityIndex,
-		OnFinish:    &addIngestFinishedIndex,
+	events := ingest.NewEventBus()
+	events.Subscribe(metricHandler.HandleEvent)
+	dispatcher := mustOpenDispatcher(&database, env)
+	if dispatcher != nil {
+		events.Subscribe(dispatcher.HandleEvent)
 	}
+	args.Events = events
 
-	api := api.NewSpotifyAPI("https://accounts.spotify.com/", &metricHandler, env.ApiAuth, api.NewAPIOptions(3))
-	logger.Log(fmt.Sprintf("Beginning spotify data ingest, user id %s.", args.UserID), logger.Info)
+	api := api.NewSpotifyAPI("https://accounts.spotify.com/", events.PublishApiCalls(&metricHandler), env.ApiAuth, api.NewAPIOptions(3))
+	if archiver := mustOpenArchiver(&database, env, ingestContext.Id, args.UserID); archiver != nil {
+		api.Archiver = archiver
+		defer closeArchiver(archiver)
+	}
+	logger.Log(fmt.Sprintf("Beginning spotify data ingest, user id %s, run %s.", args.UserID, ingestContext.Id), logger.Info)
 
 	err = Refresh(&api)
 	if err != nil {
@@ -57,12 +78,19 @@ func main() {
 	preingest := ingest.NewPreIngest(&database, args.EnvUsers)
 	spotify := ingest.BootstrapSpotifyingest(&database, &api, &preingest, args)
 	err = spotify.Ingest()
+	events.Close()
 	if err != nil {
 		database.Rollback()
 		metricHandler.AddNewFailure("INGEST", err)
+		dispatchWebhooks(dispatcher)
 		panic(err)
 	}
 
+	for _, step := range spotify.Stats.FailedSteps {
+		logger.Log(fmt.Sprintf("Ingest partially succeeded, step %s was rolled back, %s", step.Name, step.Err), logger.Warning)
+		metricHandler.AddNewFailure("INGEST_STEP", fmt.Errorf("%s: %s", step.Name, step.Err))
+	}
+
 	err = metricHandler.Close()
 	if err != nil {
 		database.Rollback()
@@ -70,4 +98,19 @@ func main() {
 	}
 
 	database.Commit()
+	dispatchWebhooks(dispatcher)
+
+	logger.Log("Refreshing listening sessions", logger.Info)
+	refreshSessions(sessions.NewSessionizer(&database), spotify.Options.UserID)
+
+	logger.Log("Refreshing daily stats", logger.Info)
+	refreshStats(stats.NewStats(&database), spotify.Options.UserID)
+
+	if env.ThumbnailStore != "" {
+		logger.Log("Mirroring new thumbnails", logger.Info)
+		err = mirrorThumbnails(&database, env, defaultMirrorConcurrency)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Failed to mirror thumbnails, %s", err.Error()), logger.Error)
+		}
+	}
 }
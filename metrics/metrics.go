@@ -114,6 +114,7 @@ const (
 	FAILURE    = "FAILURE"
 	APIREQUEST = "API_REQUEST"
 	ENTITY     = "ENTITY"
+	STAGE      = "STAGE"
 )
 
 func (m *MetricHandler) AddApiRequestIndex(method string, url string, reqBody string, timeTakenMS int64, bodySize int) error {
@@ -136,6 +137,24 @@ func (m *MetricHandler) AddIngestFinishedIndex(stats ingest.SpotifyIngestStats)
 	m.bulkIndexer.Add(stats)
 }
 
+// HandleEvent indexes the ingest events worth keeping, subscribe it to the ingests event bus
+func (m *MetricHandler) HandleEvent(event ingest.Event) {
+	switch e := event.(type) {
+	case ingest.EntityCreated:
+		if e.New {
+			m.AddNewModel(e.Model)
+		}
+	case ingest.StageFinished:
+		m.AddStageIndex(e.Timing)
+	case ingest.RunFinished:
+		m.AddIngestFinishedIndex(e.Stats)
+	}
+}
+
+func (m *MetricHandler) AddStageIndex(timing ingest.StageTiming) {
+	m.bulkIndexer.Add(newStageIndexBody(timing))
+}
+
 func newModel(tableName string, value interface{}) map[string]interface{} {
 	data := make(map[string]interface{})
 	data["type"] = ENTITY
@@ -165,3 +184,14 @@ func newApiRequestIndexBody(method string, url string, reqBody string, timeTaken
 
 	return data
 }
+
+func newStageIndexBody(timing ingest.StageTiming) map[string]interface{} {
+	data := make(map[string]interface{})
+	data["type"] = STAGE
+	data["stage"] = timing.Name
+	data["timeTaken"] = timing.Duration.Milliseconds()
+	data["resumed"] = timing.Resumed
+	data["err"] = timing.Err
+
+	return data
+}
@@ -43,3 +43,10 @@ func (m *MockMetricHandler) AddNewEntity(entity string, name string, url string)
 func (m *MockMetricHandler) AddIngestFinishedIndex(stats ingest.SpotifyIngestStats) error {
 	return nil
 }
+
+func (m *MockMetricHandler) AddStageIndex(timing ingest.StageTiming) error {
+	return nil
+}
+
+func (m *MockMetricHandler) HandleEvent(event ingest.Event) {
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"spotify/blob"
+	"spotify/database"
+	"spotify/mirror"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const defaultMirrorConcurrency = 4
+
+func mirrorThumbnailsCommand(args []string) {
+	flags := flag.NewFlagSet("mirror-thumbnails", flag.ExitOnError)
+	user := flags.String("u", "", "Username whose env to load")
+	concurrency := flags.Int("c", defaultMirrorConcurrency, "How many thumbnails to download at once")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	env := LoadEnv(*user)
+	if env.ThumbnailStore == "" {
+		log.Fatalf("thumbnail_store must be set in env to mirror thumbnails!")
+	}
+
+	database := mustConnectDatabase(env)
+	err := mirrorThumbnails(&database, env, *concurrency)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// mirrorThumbnails copies any thumbnails not yet mirrored into the configured store
+func mirrorThumbnails(db *database.Database, env SpotifyIngestEnv, concurrency int) error {
+	store, err := blob.Open(env.ThumbnailStore, env.S3Auth)
+	if err != nil {
+		return err
+	}
+
+	thumbnailMirror := mirror.NewMirror(db, store, concurrency)
+	mirrored, err := thumbnailMirror.Run()
+	if err != nil {
+		return err
+	}
+
+	logger.Log(fmt.Sprintf("Mirrored %d thumbnails to %s", mirrored, env.ThumbnailStore), logger.Info)
+	return nil
+}
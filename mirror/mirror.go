@@ -0,0 +1,198 @@
+package mirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"spotify/blob"
+	"spotify/models"
+	"spotify/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// maxImageBytes guards against a misbehaving cdn, spotifys largest artwork is well under a megabyte
+const maxImageBytes = 10 << 20
+
+type MirrorDatabase interface {
+	FetchUnmirroredThumbnails() ([]models.Thumbnail, error)
+	SetThumbnailStorage(id string, storageKey string, contentType string, byteSize int64) error
+	Commit()
+	Rollback()
+}
+
+// Mirror copies thumbnails off the spotify cdn into a blob store, keyed by the sha256 of the image so the same
+// artwork referenced by several thumbnails is only stored once
+type Mirror struct {
+	database    MirrorDatabase
+	store       blob.Store
+	Client      *http.Client
+	Concurrency int
+	Retries     int
+	RetryWait   time.Duration
+	BatchSize   int
+}
+
+func NewMirror(database MirrorDatabase, store blob.Store, concurrency int) Mirror {
+	return Mirror{
+		database:    database,
+		store:       store,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		Concurrency: concurrency,
+		Retries:     3,
+		RetryWait:   time.Second,
+		BatchSize:   200,
+	}
+}
+
+type result struct {
+	thumbnail   models.Thumbnail
+	key         string
+	contentType string
+	size        int64
+	err         error
+}
+
+// Run mirrors every thumbnail not yet mirrored, committing after each batch. Thumbnails that still fail after
+// retrying are logged and left for the next run, returning how many were mirrored
+func (m *Mirror) Run() (int, error) {
+	thumbnails, err := m.database.FetchUnmirroredThumbnails()
+	if err != nil {
+		m.database.Rollback()
+		return 0, err
+	}
+
+	if len(thumbnails) == 0 {
+		logger.Log("No thumbnails to mirror", logger.Debug)
+		return 0, nil
+	}
+
+	logger.Log(fmt.Sprintf("Mirroring %d thumbnails", len(thumbnails)), logger.Info)
+	mirrored := 0
+	for _, batch := range utils.ChunkSlice(thumbnails, m.BatchSize) {
+		for _, result := range m.mirrorAll(batch) {
+			if result.err != nil {
+				logger.Log(fmt.Sprintf("Failed to mirror thumbnail %s, %s", result.thumbnail.URL, result.err.Error()), logger.Warning)
+				continue
+			}
+
+			err = m.database.SetThumbnailStorage(result.thumbnail.ID, result.key, result.contentType, result.size)
+			if err != nil {
+				m.database.Rollback()
+				return mirrored, err
+			}
+			mirrored++
+		}
+		m.database.Commit()
+	}
+
+	return mirrored, nil
+}
+
+// mirrorAll downloads and stores thumbnails with at most Concurrency in flight, results are in the same order
+func (m *Mirror) mirrorAll(thumbnails []models.Thumbnail) []result {
+	results := make([]result, len(thumbnails))
+	slots := make(chan struct{}, max(m.Concurrency, 1))
+	wg := sync.WaitGroup{}
+
+	for i, thumbnail := range thumbnails {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = m.mirror(thumbnail)
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+func (m *Mirror) mirror(thumbnail models.Thumbnail) result {
+	data, contentType, err := m.download(thumbnail.URL)
+	if err != nil {
+		return result{thumbnail: thumbnail, err: err}
+	}
+
+	sum := sha256.Sum256(data)
+	key := fmt.Sprintf("thumbnails/%s/%s%s", hex.EncodeToString(sum[:1]), hex.EncodeToString(sum[:]), extension(contentType))
+
+	exists, err := m.store.Exists(key)
+	if err != nil {
+		return result{thumbnail: thumbnail, err: err}
+	}
+	if !exists {
+		err = m.store.Put(key, contentType, data)
+		if err != nil {
+			return result{thumbnail: thumbnail, err: err}
+		}
+	}
+
+	return result{thumbnail: thumbnail, key: key, contentType: contentType, size: int64(len(data))}
+}
+
+// download fetches an image, retrying connection failures, rate limits and server errors
+func (m *Mirror) download(url string) ([]byte, string, error) {
+	for attempt := 0; ; attempt++ {
+		data, contentType, retryable, err := m.fetch(url)
+		if err == nil {
+			return data, contentType, nil
+		}
+		if !retryable || attempt >= m.Retries {
+			return nil, "", err
+		}
+		time.Sleep(time.Duration(attempt+1) * m.RetryWait)
+	}
+}
+
+func (m *Mirror) fetch(url string) ([]byte, string, bool, error) {
+	resp, err := m.Client.Get(url)
+	if err != nil {
+		return nil, "", true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, "", retryable, fmt.Errorf("GET returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, "", true, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, "", false, fmt.Errorf("image is over %d bytes", maxImageBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, false, nil
+}
+
+func extension(contentType string) string {
+	switch strings.ToLower(contentType) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,111 @@
+package mirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"spotify/models"
+	"sync"
+	"testing"
+)
+
+type mockMirrorDatabase struct {
+	thumbnails []models.Thumbnail
+	stored     map[string]string
+	commits    int
+}
+
+func (db *mockMirrorDatabase) FetchUnmirroredThumbnails() ([]models.Thumbnail, error) {
+	return db.thumbnails, nil
+}
+
+func (db *mockMirrorDatabase) SetThumbnailStorage(id string, storageKey string, contentType string, byteSize int64) error {
+	db.stored[id] = storageKey
+	return nil
+}
+
+func (db *mockMirrorDatabase) Commit() {
+	db.commits++
+}
+
+func (db *mockMirrorDatabase) Rollback() {}
+
+type countingStore struct {
+	lock  sync.Mutex
+	blobs map[string][]byte
+}
+
+func (s *countingStore) Exists(key string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, exists := s.blobs[key]
+	return exists, nil
+}
+
+func (s *countingStore) Get(key string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.blobs[key], nil
+}
+
+func (s *countingStore) Put(key string, contentType string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.blobs[key] = data
+	return nil
+}
+
+func TestMirror(t *testing.T) {
+	lock := sync.Mutex{}
+	attempts := map[string]int{}
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts[r.URL.Path]++
+		attempt := attempts[r.URL.Path]
+		lock.Unlock()
+
+		switch r.URL.Path {
+		case "/flaky":
+			if attempt == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("same artwork"))
+	}))
+	defer cdn.Close()
+
+	db := &mockMirrorDatabase{stored: map[string]string{}, thumbnails: []models.Thumbnail{
+		{ID: "1", URL: cdn.URL + "/a"},
+		{ID: "2", URL: cdn.URL + "/flaky"},
+		{ID: "3", URL: cdn.URL + "/gone"},
+	}}
+	store := &countingStore{blobs: map[string][]byte{}}
+
+	mirror := NewMirror(db, store, 2)
+	mirror.RetryWait = 0
+	mirror.BatchSize = 2
+	mirrored, err := mirror.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mirrored != 2 || db.stored["1"] == "" || db.stored["1"] != db.stored["2"] {
+		t.Errorf("Expected both copies of the artwork to share one key, got %v", db.stored)
+	}
+	if _, exists := db.stored["3"]; exists {
+		t.Error("Expected the missing image to be left unmirrored")
+	}
+	if attempts["/gone"] != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d attempts", attempts["/gone"])
+	}
+	if len(store.blobs) != 1 {
+		t.Errorf("Expected one stored blob, got %d", len(store.blobs))
+	}
+	if db.commits != 2 {
+		t.Errorf("Expected a commit per batch, got %d", db.commits)
+	}
+}
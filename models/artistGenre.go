@@ -0,0 +1,27 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type ArtistGenre struct {
+	ID        string     `db:"id"`
+	ArtistID  string     `db:"artist_id"`
+	Genre     string     `db:"genre"`
+	CreatedAt utils.Time `db:"created_at"`
+	UpdatedAt utils.Time `db:"updated_at"`
+}
+
+func NewArtistGenre(artistID string, genre string) ArtistGenre {
+	return ArtistGenre{
+		ID:        utils.GenerateUUID(),
+		ArtistID:  artistID,
+		Genre:     genre,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *ArtistGenre) TableName() string {
+	return "artist_genres"
+}
@@ -11,6 +11,10 @@ type Artist struct {
 	CreatedAt utils.Time `db:"created_at"`
 	UpdatedAt utils.Time `db:"updated_at"`
 
+	// Genres come from the api and are stored separately in artist_genres
+	Genres []string
+	// Thumbnails are the artists images, only set when the artist was looked up from the api
+	Thumbnails  []Thumbnail
 	NeedsUpdate bool
 }
 
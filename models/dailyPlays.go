@@ -0,0 +1,11 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// DailyPlays is how many listens a user had on a day, as read back out for the api
+type DailyPlays struct {
+	Day   utils.Time `db:"day"`
+	Plays int        `db:"plays"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+const (
+	EntityArtist = "artist"
+	EntityAlbum  = "album"
+	EntitySong   = "song"
+)
+
+// EntityChange records a catalog field spotify changed since we stored it, eg. an artist renamed or a song re-titled
+type EntityChange struct {
+	ID         string     `db:"id"`
+	EntityType string     `db:"entity_type"`
+	EntityID   string     `db:"entity_id"`
+	Field      string     `db:"field"`
+	OldValue   string     `db:"old_value"`
+	NewValue   string     `db:"new_value"`
+	CreatedAt  utils.Time `db:"created_at"`
+	UpdatedAt  utils.Time `db:"updated_at"`
+}
+
+func NewEntityChange(entityType string, entityID string, field string, oldValue string, newValue string) EntityChange {
+	return EntityChange{
+		ID:         utils.GenerateUUID(),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Field:      field,
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
+	}
+}
+
+func (r *EntityChange) TableName() string {
+	return "entity_changes"
+}
+
+// StaleEntity is an artist, album or song due a refresh, with the fields a refresh can change. DurationMs is only
+// set for songs
+type StaleEntity struct {
+	EntityType string     `db:"entity_type"`
+	ID         string     `db:"id"`
+	SpotifyID  string     `db:"spotify_id"`
+	Name       string     `db:"name"`
+	DurationMs int        `db:"duration_ms"`
+	UpdatedAt  utils.Time `db:"updated_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"spotify/utils"
+	"time"
+)
+
+type EpisodeListen struct {
+	ID         string     `db:"id"`
+	UserID     string     `db:"user_id"`
+	EpisodeID  string     `db:"episode_id"`
+	StartedAt  utils.Time `db:"started_at"`
+	EndedAt    utils.Time `db:"ended_at"`
+	ListenedMs int        `db:"listened_ms"`
+	CreatedAt  utils.Time `db:"created_at"`
+	UpdatedAt  utils.Time `db:"updated_at"`
+}
+
+func NewEpisodeListen(userID string, episodeID string, startedAt time.Time, endedAt time.Time, listenedMs int) EpisodeListen {
+	return EpisodeListen{
+		ID:         utils.GenerateUUID(),
+		UserID:     userID,
+		EpisodeID:  episodeID,
+		StartedAt:  utils.Time{Time: startedAt},
+		EndedAt:    utils.Time{Time: endedAt},
+		ListenedMs: listenedMs,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
+	}
+}
+
+func (r *EpisodeListen) TableName() string {
+	return "episode_listens"
+}
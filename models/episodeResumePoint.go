@@ -0,0 +1,31 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type EpisodeResumePoint struct {
+	ID               string     `db:"id"`
+	UserID           string     `db:"user_id"`
+	EpisodeID        string     `db:"episode_id"`
+	ResumePositionMs int        `db:"resume_position_ms"`
+	FullyPlayed      bool       `db:"fully_played"`
+	CreatedAt        utils.Time `db:"created_at"`
+	UpdatedAt        utils.Time `db:"updated_at"`
+}
+
+func NewEpisodeResumePoint(userID string, episodeID string, resumePositionMs int, fullyPlayed bool) EpisodeResumePoint {
+	return EpisodeResumePoint{
+		ID:               utils.GenerateUUID(),
+		UserID:           userID,
+		EpisodeID:        episodeID,
+		ResumePositionMs: resumePositionMs,
+		FullyPlayed:      fullyPlayed,
+		CreatedAt:        utils.NewTime(),
+		UpdatedAt:        utils.NewTime(),
+	}
+}
+
+func (r *EpisodeResumePoint) TableName() string {
+	return "episode_resume_points"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type Episode struct {
+	ID          string     `db:"id"`
+	ShowID      string     `db:"show_id"`
+	Name        string     `db:"name"`
+	SpotifyID   string     `db:"spotify_id"`
+	DurationMs  int        `db:"duration_ms"`
+	ReleaseDate string     `db:"release_date"`
+	CreatedAt   utils.Time `db:"created_at"`
+	UpdatedAt   utils.Time `db:"updated_at"`
+
+	NeedsUpdate bool
+}
+
+func NewEpisode(name string, showID string, spotifyID string, durationMs int, releaseDate string, needsUpdate bool) Episode {
+	return Episode{
+		ID:          utils.GenerateUUID(),
+		ShowID:      showID,
+		Name:        name,
+		SpotifyID:   spotifyID,
+		DurationMs:  durationMs,
+		ReleaseDate: releaseDate,
+		CreatedAt:   utils.NewTime(),
+		UpdatedAt:   utils.NewTime(),
+		NeedsUpdate: needsUpdate,
+	}
+}
+
+func (r *Episode) Identifier() string {
+	return r.ID
+}
+
+func (r *Episode) TableName() string {
+	return "episodes"
+}
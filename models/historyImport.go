@@ -0,0 +1,29 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type HistoryImport struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	FileName  string     `db:"file_name"`
+	Processed int        `db:"processed"`
+	Completed bool       `db:"completed"`
+	CreatedAt utils.Time `db:"created_at"`
+	UpdatedAt utils.Time `db:"updated_at"`
+}
+
+func NewHistoryImport(userID string, fileName string) HistoryImport {
+	return HistoryImport{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		FileName:  fileName,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *HistoryImport) TableName() string {
+	return "history_imports"
+}
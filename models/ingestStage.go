@@ -0,0 +1,25 @@
+package models
+
+import "spotify/utils"
+
+// IngestStage marks a stage of a checkpointed run as done, it's inserted in the same transaction as the stages data so
+// a resume only skips stages whose data was committed
+type IngestStage struct {
+	ID        string     `db:"id"`
+	RunID     string     `db:"run_id"`
+	Stage     string     `db:"stage"`
+	CreatedAt utils.Time `db:"created_at"`
+}
+
+func NewIngestStage(runID string, stage string) IngestStage {
+	return IngestStage{
+		ID:        utils.GenerateUUID(),
+		RunID:     runID,
+		Stage:     stage,
+		CreatedAt: utils.NewTime(),
+	}
+}
+
+func (r *IngestStage) TableName() string {
+	return "ingest_stages"
+}
@@ -0,0 +1,23 @@
+package models
+
+// Integrity checks the doctor command runs, each names a query returning the offending rows
+const (
+	IntegritySongArtistNotUUID   = "song_artist_not_uuid"
+	IntegritySongAlbumNotUUID    = "song_album_not_uuid"
+	IntegrityAlbumArtistNotUUID  = "album_artist_not_uuid"
+	IntegritySongArtistOrphan    = "song_artist_orphan"
+	IntegritySongAlbumOrphan     = "song_album_orphan"
+	IntegrityAlbumArtistMismatch = "album_artist_mismatch"
+	IntegrityRecentListenOrphan  = "recent_listen_orphan"
+	IntegrityThumbnailUnattached = "thumbnail_unattached"
+	IntegrityDuplicateArtists    = "duplicate_artist_spotify_ids"
+	IntegrityDuplicateAlbums     = "duplicate_album_spotify_ids"
+	IntegrityDuplicateSongs      = "duplicate_song_spotify_ids"
+)
+
+// IntegrityIssue is one row failing an integrity check, Value is whatever's wrong with it eg. the bad foreign key
+type IntegrityIssue struct {
+	ID        string `db:"id"`
+	SpotifyID string `db:"spotify_id"`
+	Value     string `db:"value"`
+}
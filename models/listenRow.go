@@ -0,0 +1,16 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// ListenRow is a recent listen joined with the catalog, as read back out for exports
+type ListenRow struct {
+	// ID is the recent listens id, only read back where listens are paged
+	ID            string     `db:"id"`
+	PlayedAt      utils.Time `db:"played_at"`
+	SongName      string     `db:"song_name"`
+	SongSpotifyID string     `db:"song_spotify_id"`
+	AlbumName     string     `db:"album_name"`
+	ArtistName    string     `db:"artist_name"`
+}
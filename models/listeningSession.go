@@ -0,0 +1,72 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// ListeningSession is a run of a users listens with no gap longer than the session gap between one ending and the next starting
+type ListeningSession struct {
+	ID          string     `db:"id"`
+	UserID      string     `db:"user_id"`
+	StartedAt   utils.Time `db:"started_at"`
+	EndedAt     utils.Time `db:"ended_at"`
+	Listens     int        `db:"listens"`
+	Skips       int        `db:"skips"`
+	EstimatedMs int64      `db:"estimated_ms"`
+	CreatedAt   utils.Time `db:"created_at"`
+	UpdatedAt   utils.Time `db:"updated_at"`
+}
+
+func NewListeningSession(userID string, startedAt utils.Time) ListeningSession {
+	return ListeningSession{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		StartedAt: startedAt,
+		EndedAt:   startedAt,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *ListeningSession) TableName() string {
+	return "listening_sessions"
+}
+
+// ListenEstimate is how long a listen was probably played for and whether it looks skipped, taken from the streaming
+// history when it was imported and otherwise inferred from when the next listen started
+type ListenEstimate struct {
+	ID                 string     `db:"id"`
+	RecentListenID     string     `db:"recent_listen_id"`
+	ListeningSessionID string     `db:"listening_session_id"`
+	EstimatedMsPlayed  int64      `db:"estimated_ms_played"`
+	Skipped            bool       `db:"skipped"`
+	CreatedAt          utils.Time `db:"created_at"`
+	UpdatedAt          utils.Time `db:"updated_at"`
+}
+
+func NewListenEstimate(recentListenID string, listeningSessionID string, estimatedMsPlayed int64, skipped bool) ListenEstimate {
+	return ListenEstimate{
+		ID:                 utils.GenerateUUID(),
+		RecentListenID:     recentListenID,
+		ListeningSessionID: listeningSessionID,
+		EstimatedMsPlayed:  estimatedMsPlayed,
+		Skipped:            skipped,
+		CreatedAt:          utils.NewTime(),
+		UpdatedAt:          utils.NewTime(),
+	}
+}
+
+func (r *ListenEstimate) TableName() string {
+	return "listen_estimates"
+}
+
+// SessionListen is a listen with what sessionizing needs. MsPlayed and Skipped are only known (HasDetail) for
+// listens that came from a streaming history import
+type SessionListen struct {
+	RecentListenID string     `db:"recent_listen_id"`
+	PlayedAt       utils.Time `db:"played_at"`
+	DurationMs     int64      `db:"duration_ms"`
+	HasDetail      bool       `db:"has_detail"`
+	MsPlayed       int64      `db:"ms_played"`
+	Skipped        bool       `db:"skipped"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql"
+	"spotify/utils"
+	"time"
+)
+
+const (
+	PlaybackEndedFinished = "finished"
+	PlaybackEndedSkipped  = "skipped"
+	PlaybackEndedPaused   = "paused"
+)
+
+type PlaybackSession struct {
+	ID             string         `db:"id"`
+	UserID         string         `db:"user_id"`
+	SongID         string         `db:"song_id"`
+	StartedAt      utils.Time     `db:"started_at"`
+	EndedAt        utils.Time     `db:"ended_at"`
+	ProgressMs     int            `db:"progress_ms"`
+	DurationMs     int            `db:"duration_ms"`
+	EndedReason    string         `db:"ended_reason"`
+	RecentListenID sql.NullString `db:"recent_listen_id"`
+	CreatedAt      utils.Time     `db:"created_at"`
+	UpdatedAt      utils.Time     `db:"updated_at"`
+}
+
+func NewPlaybackSession(userID string, songID string, startedAt time.Time, progressMs int, durationMs int) PlaybackSession {
+	return PlaybackSession{
+		ID:         utils.GenerateUUID(),
+		UserID:     userID,
+		SongID:     songID,
+		StartedAt:  utils.Time{Time: startedAt},
+		EndedAt:    utils.Time{Time: startedAt},
+		ProgressMs: progressMs,
+		DurationMs: durationMs,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
+	}
+}
+
+func (r *PlaybackSession) TableName() string {
+	return "playback_sessions"
+}
+
+// PlaybackSessionMatch pairs an unmatched playback session with a recent listen inside its match window
+type PlaybackSessionMatch struct {
+	SessionID      string `db:"session_id"`
+	RecentListenID string `db:"recent_listen_id"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type PlaylistSnapshot struct {
+	ID         string     `db:"id"`
+	UserID     string     `db:"user_id"`
+	SpotifyID  string     `db:"spotify_id"`
+	SnapshotID string     `db:"snapshot_id"`
+	Name       string     `db:"name"`
+	TrackCount int        `db:"track_count"`
+	CreatedAt  utils.Time `db:"created_at"`
+	UpdatedAt  utils.Time `db:"updated_at"`
+}
+
+func NewPlaylistSnapshot(userID string, spotifyID string, snapshotID string, name string, trackCount int) PlaylistSnapshot {
+	return PlaylistSnapshot{
+		ID:         utils.GenerateUUID(),
+		UserID:     userID,
+		SpotifyID:  spotifyID,
+		SnapshotID: snapshotID,
+		Name:       name,
+		TrackCount: trackCount,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
+	}
+}
+
+func (r *PlaylistSnapshot) TableName() string {
+	return "playlist_snapshots"
+}
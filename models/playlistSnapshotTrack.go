@@ -0,0 +1,31 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type PlaylistSnapshotTrack struct {
+	ID                 string         `db:"id"`
+	PlaylistSnapshotID string         `db:"playlist_snapshot_id"`
+	SongID             string         `db:"song_id"`
+	Position           int            `db:"position"`
+	AddedAt            utils.NullTime `db:"added_at"`
+	CreatedAt          utils.Time     `db:"created_at"`
+	UpdatedAt          utils.Time     `db:"updated_at"`
+}
+
+func NewPlaylistSnapshotTrack(playlistSnapshotID string, songID string, position int, addedAt utils.NullTime) PlaylistSnapshotTrack {
+	return PlaylistSnapshotTrack{
+		ID:                 utils.GenerateUUID(),
+		PlaylistSnapshotID: playlistSnapshotID,
+		SongID:             songID,
+		Position:           position,
+		AddedAt:            addedAt,
+		CreatedAt:          utils.NewTime(),
+		UpdatedAt:          utils.NewTime(),
+	}
+}
+
+func (r *PlaylistSnapshotTrack) TableName() string {
+	return "playlist_snapshot_tracks"
+}
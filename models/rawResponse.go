@@ -0,0 +1,41 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// RawResponse indexes one archived spotify response body, Sequence orders the responses within a run so repeated
+// requests to the same endpoint replay in the order they were made
+type RawResponse struct {
+	ID          string     `db:"id"`
+	RunID       string     `db:"run_id"`
+	Username    string     `db:"username"`
+	Method      string     `db:"method"`
+	Endpoint    string     `db:"endpoint"`
+	Sequence    int        `db:"sequence"`
+	StorageKey  string     `db:"storage_key"`
+	ByteSize    int        `db:"byte_size"`
+	RequestedAt utils.Time `db:"requested_at"`
+	CreatedAt   utils.Time `db:"created_at"`
+	UpdatedAt   utils.Time `db:"updated_at"`
+}
+
+func NewRawResponse(runID string, username string, method string, endpoint string, sequence int, storageKey string, byteSize int) RawResponse {
+	return RawResponse{
+		ID:          utils.GenerateUUID(),
+		RunID:       runID,
+		Username:    username,
+		Method:      method,
+		Endpoint:    endpoint,
+		Sequence:    sequence,
+		StorageKey:  storageKey,
+		ByteSize:    byteSize,
+		RequestedAt: utils.NewTime(),
+		CreatedAt:   utils.NewTime(),
+		UpdatedAt:   utils.NewTime(),
+	}
+}
+
+func (r *RawResponse) TableName() string {
+	return "raw_responses"
+}
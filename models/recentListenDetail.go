@@ -0,0 +1,41 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type RecentListenDetail struct {
+	ID             string     `db:"id"`
+	RecentListenID string     `db:"recent_listen_id"`
+	MsPlayed       int        `db:"ms_played"`
+	ReasonStart    string     `db:"reason_start"`
+	ReasonEnd      string     `db:"reason_end"`
+	Skipped        bool       `db:"skipped"`
+	Shuffle        bool       `db:"shuffle"`
+	Offline        bool       `db:"offline"`
+	Platform       string     `db:"platform"`
+	Source         string     `db:"source"`
+	CreatedAt      utils.Time `db:"created_at"`
+	UpdatedAt      utils.Time `db:"updated_at"`
+}
+
+func NewRecentListenDetail(recentListenID string, msPlayed int, reasonStart string, reasonEnd string, skipped bool, shuffle bool, offline bool, platform string, source string) RecentListenDetail {
+	return RecentListenDetail{
+		ID:             utils.GenerateUUID(),
+		RecentListenID: recentListenID,
+		MsPlayed:       msPlayed,
+		ReasonStart:    reasonStart,
+		ReasonEnd:      reasonEnd,
+		Skipped:        skipped,
+		Shuffle:        shuffle,
+		Offline:        offline,
+		Platform:       platform,
+		Source:         source,
+		CreatedAt:      utils.NewTime(),
+		UpdatedAt:      utils.NewTime(),
+	}
+}
+
+func (r *RecentListenDetail) TableName() string {
+	return "recent_listen_details"
+}
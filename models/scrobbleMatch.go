@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+	"spotify/utils"
+)
+
+// ScrobbleMatch caches which spotify track a scrobbled artist and track name resolved to, keyed on the normalized names.
+// Misses are cached too, with no spotify id, so unmatchable scrobbles aren't searched for on every import
+type ScrobbleMatch struct {
+	ID        string         `db:"id"`
+	ArtistKey string         `db:"artist_key"`
+	TrackKey  string         `db:"track_key"`
+	SpotifyID sql.NullString `db:"spotify_id"`
+	CreatedAt utils.Time     `db:"created_at"`
+	UpdatedAt utils.Time     `db:"updated_at"`
+}
+
+func NewScrobbleMatch(artistKey string, trackKey string, spotifyID string) ScrobbleMatch {
+	return ScrobbleMatch{
+		ID:        utils.GenerateUUID(),
+		ArtistKey: artistKey,
+		TrackKey:  trackKey,
+		SpotifyID: sql.NullString{String: spotifyID, Valid: spotifyID != ""},
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *ScrobbleMatch) TableName() string {
+	return "scrobble_matches"
+}
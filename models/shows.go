@@ -0,0 +1,36 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+type Show struct {
+	ID        string     `db:"id"`
+	Name      string     `db:"name"`
+	Publisher string     `db:"publisher"`
+	SpotifyID string     `db:"spotify_id"`
+	CreatedAt utils.Time `db:"created_at"`
+	UpdatedAt utils.Time `db:"updated_at"`
+
+	NeedsUpdate bool
+}
+
+func NewShow(name string, publisher string, spotifyID string, needsUpdate bool) Show {
+	return Show{
+		ID:          utils.GenerateUUID(),
+		Name:        name,
+		Publisher:   publisher,
+		SpotifyID:   spotifyID,
+		CreatedAt:   utils.NewTime(),
+		UpdatedAt:   utils.NewTime(),
+		NeedsUpdate: needsUpdate,
+	}
+}
+
+func (r *Show) Identifier() string {
+	return r.ID
+}
+
+func (r *Show) TableName() string {
+	return "shows"
+}
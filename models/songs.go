@@ -5,13 +5,15 @@ import (
 )
 
 type Song struct {
-	ID        string     `db:"id"`
-	SpotifyID string     `db:"spotify_id"`
-	AlbumID   string     `db:"album_id"`
-	ArtistID  string     `db:"artist_id"`
-	Name      string     `db:"name"`
-	CreatedAt utils.Time `db:"created_at"`
-	UpdatedAt utils.Time `db:"updated_at"`
+	ID        string `db:"id"`
+	SpotifyID string `db:"spotify_id"`
+	AlbumID   string `db:"album_id"`
+	ArtistID  string `db:"artist_id"`
+	Name      string `db:"name"`
+	// DurationMs is 0 for songs ingested before durations were stored
+	DurationMs int        `db:"duration_ms"`
+	CreatedAt  utils.Time `db:"created_at"`
+	UpdatedAt  utils.Time `db:"updated_at"`
 
 	NeedsUpdate bool
 
@@ -22,15 +24,16 @@ func (r Song) Identifier() string {
 	return r.SpotifyID
 }
 
-func NewSong(name string, spotifyID string, albumID string, artistID string, needsUpdate bool) Song {
+func NewSong(name string, spotifyID string, albumID string, artistID string, durationMs int, needsUpdate bool) Song {
 	return Song{
-		ID:        utils.GenerateUUID(),
-		Name:      name,
-		SpotifyID: spotifyID,
-		ArtistID:  artistID,
-		AlbumID:   albumID,
-		CreatedAt: utils.NewTime(),
-		UpdatedAt: utils.NewTime(),
+		ID:         utils.GenerateUUID(),
+		Name:       name,
+		SpotifyID:  spotifyID,
+		ArtistID:   artistID,
+		AlbumID:    albumID,
+		DurationMs: durationMs,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
 
 		NeedsUpdate: needsUpdate,
 	}
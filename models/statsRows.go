@@ -0,0 +1,64 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// StatsListen is a listen with what the daily stats need to roll it up. EstimatedMs is how long was actually played
+// when known (from history imports), otherwise the songs duration
+type StatsListen struct {
+	PlayedAt    utils.Time `db:"played_at"`
+	SongID      string     `db:"song_id"`
+	AlbumID     string     `db:"album_id"`
+	ArtistID    string     `db:"artist_id"`
+	EstimatedMs int64      `db:"estimated_ms"`
+}
+
+// FirstListen is when a user first played a song or artist, for counting discoveries
+type FirstListen struct {
+	ItemType string     `db:"item_type"`
+	ItemID   string     `db:"item_id"`
+	PlayedAt utils.Time `db:"played_at"`
+}
+
+// TopItemRow is a song, album or artist with its plays summed over a window. ThumbnailID is the entity its
+// thumbnails hang off, the album for songs and the item itself otherwise
+type TopItemRow struct {
+	ItemID      string `db:"item_id"`
+	Name        string `db:"name"`
+	SpotifyID   string `db:"spotify_id"`
+	ThumbnailID string `db:"thumbnail_id"`
+	Plays       int    `db:"plays"`
+	EstimatedMs int64  `db:"estimated_ms"`
+}
+
+// SongPlays is how many times a user has played a song all time
+type SongPlays struct {
+	SongID    string `db:"song_id"`
+	Name      string `db:"name"`
+	SpotifyID string `db:"spotify_id"`
+	Plays     int    `db:"plays"`
+}
+
+// GenrePlays is how many plays a users artists tagged with a genre had over a window
+type GenrePlays struct {
+	Genre       string `db:"genre"`
+	Plays       int    `db:"plays"`
+	EstimatedMs int64  `db:"estimated_ms"`
+}
+
+// ReplayedDay is the most plays a single song had on one day
+type ReplayedDay struct {
+	Day       utils.Time `db:"day"`
+	SongID    string     `db:"song_id"`
+	Name      string     `db:"name"`
+	SpotifyID string     `db:"spotify_id"`
+	Plays     int        `db:"plays"`
+}
+
+// WeeklyTotal is a users estimated listening time and distinct artists played over a week starting monday
+type WeeklyTotal struct {
+	Week          utils.Time `db:"week"`
+	EstimatedMs   int64      `db:"estimated_ms"`
+	UniqueArtists int        `db:"unique_artists"`
+}
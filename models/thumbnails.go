@@ -1,19 +1,26 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
 	"spotify/utils"
 )
 
 type Thumbnail struct {
-	ID        string     `db:"id"`
-	Entity    string     `db:"entity_type"`
-	EntityID  string     `db:"entity_id"`
-	URL       string     `db:"url"`
-	Width     int        `db:"width"`
-	Height    int        `db:"height"`
-	CreatedAt utils.Time `db:"created_at"`
-	UpdatedAt utils.Time `db:"updated_at"`
+	ID       string `db:"id"`
+	Entity   string `db:"entity_type"`
+	EntityID string `db:"entity_id"`
+	URL      string `db:"url"`
+	Width    int    `db:"width"`
+	Height   int    `db:"height"`
+	// StorageKey, ContentType and ByteSize are set once the image has been mirrored to the blob store
+	StorageKey  sql.NullString `db:"storage_key"`
+	ContentType sql.NullString `db:"content_type"`
+	ByteSize    sql.NullInt64  `db:"byte_size"`
+	// SupersededAt is set once spotify serves a different image at the same size, the newer row replaces it
+	SupersededAt utils.NullTime `db:"superseded_at"`
+	CreatedAt    utils.Time     `db:"created_at"`
+	UpdatedAt    utils.Time     `db:"updated_at"`
 }
 
 func NewThumbnail(entity string, entityID string, URL string, height float64, width float64) Thumbnail {
@@ -37,3 +44,10 @@ func (r *Thumbnail) TableName() string {
 func (r *Thumbnail) UniqueID() string {
 	return fmt.Sprintf("%s-%d-%d", r.EntityID, r.Width, r.Height)
 }
+
+// ThumbnailOwner is an artist, album, show or episode without a current thumbnail, one an unattached thumbnail could belong to
+type ThumbnailOwner struct {
+	EntityType string `db:"entity_type"`
+	ID         string `db:"id"`
+	SpotifyID  string `db:"spotify_id"`
+}
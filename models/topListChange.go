@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"spotify/utils"
+)
+
+const (
+	TopListSong   = "song"
+	TopListArtist = "artist"
+
+	TopListMovementNew     = "new"
+	TopListMovementUp      = "up"
+	TopListMovementDown    = "down"
+	TopListMovementDropped = "dropped"
+)
+
+// TopListChange is one items movement in a users top songs or artists for a term between the previous snapshot
+// and SnapshotID. PreviousRank is null for new entries and Rank is null for items that dropped out
+type TopListChange struct {
+	ID           string        `db:"id"`
+	UserID       string        `db:"user_id"`
+	ListType     string        `db:"list_type"`
+	TimePeriod   string        `db:"time_period"`
+	ItemID       string        `db:"item_id"`
+	SnapshotID   string        `db:"snapshot_id"`
+	PreviousRank sql.NullInt32 `db:"previous_rank"`
+	Rank         sql.NullInt32 `db:"rank"`
+	Movement     string        `db:"movement"`
+	CreatedAt    utils.Time    `db:"created_at"`
+	UpdatedAt    utils.Time    `db:"updated_at"`
+}
+
+func NewTopListChange(userID string, listType string, timePeriod string, itemID string, snapshotID string, previousRank int, rank int, movement string) TopListChange {
+	return TopListChange{
+		ID:           utils.GenerateUUID(),
+		UserID:       userID,
+		ListType:     listType,
+		TimePeriod:   timePeriod,
+		ItemID:       itemID,
+		SnapshotID:   snapshotID,
+		PreviousRank: sql.NullInt32{Int32: int32(previousRank), Valid: previousRank > 0},
+		Rank:         sql.NullInt32{Int32: int32(rank), Valid: rank > 0},
+		Movement:     movement,
+		CreatedAt:    utils.NewTime(),
+		UpdatedAt:    utils.NewTime(),
+	}
+}
+
+func (r *TopListChange) TableName() string {
+	return "top_list_changes"
+}
+
+// TopListEntry is an items rank for a term in a stored top songs or artists snapshot
+type TopListEntry struct {
+	TimePeriod string `db:"time_period"`
+	ItemID     string `db:"item_id"`
+	Order      int    `db:"order"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// TopSongRow is one entry of a top songs snapshot joined with the catalog, as read back out for exports
+type TopSongRow struct {
+	SnapshotAt    utils.Time `db:"snapshot_at"`
+	TimePeriod    string     `db:"time_period"`
+	Order         int        `db:"order"`
+	SongName      string     `db:"song_name"`
+	SongSpotifyID string     `db:"song_spotify_id"`
+	AlbumName     string     `db:"album_name"`
+	ArtistName    string     `db:"artist_name"`
+}
+
+// TopArtistRow is one entry of a top artists snapshot joined with the catalog, as read back out for exports
+type TopArtistRow struct {
+	SnapshotAt      utils.Time `db:"snapshot_at"`
+	TimePeriod      string     `db:"time_period"`
+	Order           int        `db:"order"`
+	ArtistName      string     `db:"artist_name"`
+	ArtistSpotifyID string     `db:"artist_spotify_id"`
+}
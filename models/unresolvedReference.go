@@ -0,0 +1,44 @@
+package models
+
+import (
+	"spotify/utils"
+)
+
+// MaxResolveAttempts is how many times the resolve command retries a reference before giving up on it
+const MaxResolveAttempts = 10
+
+// UnresolvedReference records a row inserted without one of its references because the spotify id couldn't be
+// looked up, the column is left null until the resolve command finds it. MissingKind is one of the Entity constants
+type UnresolvedReference struct {
+	ID           string         `db:"id"`
+	RunID        string         `db:"run_id"`
+	SourceTable  string         `db:"source_table"`
+	SourceID     string         `db:"source_id"`
+	SourceColumn string         `db:"source_column"`
+	MissingKind  string         `db:"missing_kind"`
+	SpotifyID    string         `db:"spotify_id"`
+	ResolvedAt   utils.NullTime `db:"resolved_at"`
+	// Attempts and LastAttemptAt track the resolve command's misses, so references it keeps missing don't starve the rest
+	Attempts      int            `db:"attempts"`
+	LastAttemptAt utils.NullTime `db:"last_attempt_at"`
+	CreatedAt     utils.Time     `db:"created_at"`
+	UpdatedAt     utils.Time     `db:"updated_at"`
+}
+
+func NewUnresolvedReference(runID string, sourceTable string, sourceID string, sourceColumn string, missingKind string, spotifyID string) UnresolvedReference {
+	return UnresolvedReference{
+		ID:           utils.GenerateUUID(),
+		RunID:        runID,
+		SourceTable:  sourceTable,
+		SourceID:     sourceID,
+		SourceColumn: sourceColumn,
+		MissingKind:  missingKind,
+		SpotifyID:    spotifyID,
+		CreatedAt:    utils.NewTime(),
+		UpdatedAt:    utils.NewTime(),
+	}
+}
+
+func (r *UnresolvedReference) TableName() string {
+	return "unresolved_references"
+}
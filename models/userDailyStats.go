@@ -0,0 +1,98 @@
+package models
+
+import (
+	"spotify/utils"
+	"time"
+)
+
+const (
+	StatsItemSong   = "song"
+	StatsItemAlbum  = "album"
+	StatsItemArtist = "artist"
+)
+
+// UserDailyStat rolls up a users listens for a utc day. ComputedAt is when the day was last recomputed,
+// any listen created after it marks the day as needing recomputing
+type UserDailyStat struct {
+	ID          string     `db:"id"`
+	UserID      string     `db:"user_id"`
+	Day         utils.Time `db:"day"`
+	Plays       int        `db:"plays"`
+	EstimatedMs int64      `db:"estimated_ms"`
+	NewSongs    int        `db:"new_songs"`
+	NewArtists  int        `db:"new_artists"`
+	ComputedAt  utils.Time `db:"computed_at"`
+	CreatedAt   utils.Time `db:"created_at"`
+	UpdatedAt   utils.Time `db:"updated_at"`
+}
+
+func NewUserDailyStat(userID string, day time.Time, computedAt utils.Time) UserDailyStat {
+	return UserDailyStat{
+		ID:         utils.GenerateUUID(),
+		UserID:     userID,
+		Day:        utils.Time{Time: day},
+		ComputedAt: computedAt,
+		CreatedAt:  utils.NewTime(),
+		UpdatedAt:  utils.NewTime(),
+	}
+}
+
+func (r *UserDailyStat) TableName() string {
+	return "user_daily_stats"
+}
+
+// UserDailyHourStat is how many listens a user had in one utc hour of a day, for hour of day and day of week heatmaps
+type UserDailyHourStat struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	Day       utils.Time `db:"day"`
+	Hour      int        `db:"hour"`
+	Plays     int        `db:"plays"`
+	CreatedAt utils.Time `db:"created_at"`
+	UpdatedAt utils.Time `db:"updated_at"`
+}
+
+func NewUserDailyHourStat(userID string, day time.Time, hour int, plays int) UserDailyHourStat {
+	return UserDailyHourStat{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		Day:       utils.Time{Time: day},
+		Hour:      hour,
+		Plays:     plays,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *UserDailyHourStat) TableName() string {
+	return "user_daily_hour_stats"
+}
+
+// UserDailyItemStat is how much a user played one song, album or artist on a day, summed over a window for top lists
+type UserDailyItemStat struct {
+	ID          string     `db:"id"`
+	UserID      string     `db:"user_id"`
+	Day         utils.Time `db:"day"`
+	ItemType    string     `db:"item_type"`
+	ItemID      string     `db:"item_id"`
+	Plays       int        `db:"plays"`
+	EstimatedMs int64      `db:"estimated_ms"`
+	CreatedAt   utils.Time `db:"created_at"`
+	UpdatedAt   utils.Time `db:"updated_at"`
+}
+
+func NewUserDailyItemStat(userID string, day time.Time, itemType string, itemID string) UserDailyItemStat {
+	return UserDailyItemStat{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		Day:       utils.Time{Time: day},
+		ItemType:  itemType,
+		ItemID:    itemID,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *UserDailyItemStat) TableName() string {
+	return "user_daily_item_stats"
+}
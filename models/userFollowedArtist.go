@@ -0,0 +1,31 @@
+package models
+
+import (
+	"spotify/utils"
+	"time"
+)
+
+type UserFollowedArtist struct {
+	ID                 string         `db:"id"`
+	UserID             string         `db:"user_id"`
+	ArtistID           string         `db:"artist_id"`
+	FollowedFirstSeen  utils.Time     `db:"followed_first_seen"`
+	UnfollowedDetected utils.NullTime `db:"unfollowed_detected"`
+	CreatedAt          utils.Time     `db:"created_at"`
+	UpdatedAt          utils.Time     `db:"updated_at"`
+}
+
+func NewUserFollowedArtist(userID string, artistID string, firstSeen time.Time) UserFollowedArtist {
+	return UserFollowedArtist{
+		ID:                utils.GenerateUUID(),
+		UserID:            userID,
+		ArtistID:          artistID,
+		FollowedFirstSeen: utils.Time{Time: firstSeen},
+		CreatedAt:         utils.NewTime(),
+		UpdatedAt:         utils.NewTime(),
+	}
+}
+
+func (r *UserFollowedArtist) TableName() string {
+	return "user_followed_artists"
+}
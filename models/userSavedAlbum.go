@@ -0,0 +1,31 @@
+package models
+
+import (
+	"spotify/utils"
+	"time"
+)
+
+type UserSavedAlbum struct {
+	ID        string         `db:"id"`
+	UserID    string         `db:"user_id"`
+	AlbumID   string         `db:"album_id"`
+	AddedAt   utils.Time     `db:"added_at"`
+	RemovedAt utils.NullTime `db:"removed_at"`
+	CreatedAt utils.Time     `db:"created_at"`
+	UpdatedAt utils.Time     `db:"updated_at"`
+}
+
+func NewUserSavedAlbum(userID string, albumID string, addedAt time.Time) UserSavedAlbum {
+	return UserSavedAlbum{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		AlbumID:   albumID,
+		AddedAt:   utils.Time{Time: addedAt},
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *UserSavedAlbum) TableName() string {
+	return "user_saved_albums"
+}
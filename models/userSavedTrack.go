@@ -0,0 +1,31 @@
+package models
+
+import (
+	"spotify/utils"
+	"time"
+)
+
+type UserSavedTrack struct {
+	ID        string         `db:"id"`
+	UserID    string         `db:"user_id"`
+	SongID    string         `db:"song_id"`
+	AddedAt   utils.Time     `db:"added_at"`
+	RemovedAt utils.NullTime `db:"removed_at"`
+	CreatedAt utils.Time     `db:"created_at"`
+	UpdatedAt utils.Time     `db:"updated_at"`
+}
+
+func NewUserSavedTrack(userID string, songID string, addedAt time.Time) UserSavedTrack {
+	return UserSavedTrack{
+		ID:        utils.GenerateUUID(),
+		UserID:    userID,
+		SongID:    songID,
+		AddedAt:   utils.Time{Time: addedAt},
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *UserSavedTrack) TableName() string {
+	return "user_saved_tracks"
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"database/sql"
+	"spotify/utils"
+)
+
+// WebhookDelivery logs one event sent to one webhook. StatusCode is the last response the webhook gave, null when it
+// never answered, and DeliveredAt stays null when every attempt failed
+type WebhookDelivery struct {
+	ID          string         `db:"id"`
+	RunID       string         `db:"run_id"`
+	URL         string         `db:"url"`
+	Event       string         `db:"event"`
+	Payload     string         `db:"payload"`
+	Attempts    int            `db:"attempts"`
+	StatusCode  sql.NullInt32  `db:"status_code"`
+	Error       string         `db:"error"`
+	DeliveredAt utils.NullTime `db:"delivered_at"`
+	CreatedAt   utils.Time     `db:"created_at"`
+	UpdatedAt   utils.Time     `db:"updated_at"`
+}
+
+func NewWebhookDelivery(runID string, url string, event string, payload string) WebhookDelivery {
+	return WebhookDelivery{
+		ID:        utils.GenerateUUID(),
+		RunID:     runID,
+		URL:       url,
+		Event:     event,
+		Payload:   payload,
+		CreatedAt: utils.NewTime(),
+		UpdatedAt: utils.NewTime(),
+	}
+}
+
+func (r *WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"spotify/ingest"
+	"syscall"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func pollCommand(args []string) {
+	flags := flag.NewFlagSet("poll", flag.ExitOnError)
+	user := flags.String("u", "", "Username to poll the spotify API for, must have relevant refresh_token in env")
+	interval := flags.Int("i", 5, "Seconds between currently playing polls")
+	matchOnly := flags.Bool("m", false, "Match existing playback sessions to recent listens and exit, without polling")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	spotify, metricHandler := mustBootstrapIngest(env, &database, ingest.SpotifyIngestOptions{UserID: *user, EnvUsers: env.Users})
+	defer metricHandler.Close()
+	database.Commit()
+
+	poller := ingest.NewPoller(&database, &spotify, time.Duration(*interval)*time.Second)
+	if *matchOnly {
+		err := poller.Match()
+		if err != nil {
+			metricHandler.AddNewFailure("POLL_MATCH", err)
+			panic(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Log("Beginning currently playing poller, stop with SIGINT or SIGTERM", logger.Info)
+	err := poller.Run(ctx)
+	if err != nil {
+		metricHandler.AddNewFailure("POLL", err)
+		panic(err)
+	}
+}
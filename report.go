@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"spotify/report"
+	"spotify/stats"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+var renderers = map[string]func(w io.Writer, r report.Report) error{
+	"html": report.RenderHTML,
+	"md":   report.RenderMarkdown,
+}
+
+func reportCommand(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	user := flags.String("u", "", "Username to report on")
+	year := flags.Int("y", time.Now().UTC().Year(), "Year to report on")
+	format := flags.String("f", "html", "Output format, one of html or md")
+	limit := flags.Int("n", 10, "Number of top songs, albums, artists and genres to list")
+	output := flags.String("o", "", "File to write to, defaults to stdout")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	render, exists := renderers[*format]
+	if !exists {
+		log.Fatalf("Unknown format %q!", *format)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output == "" {
+		silenceLogger()
+	} else {
+		f, err := os.Create(*output)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+
+	dbUser, err := database.FetchUserByName(*user)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %s", *user, err.Error())
+	}
+
+	dailyStats := stats.NewStats(&database)
+	err = dailyStats.Refresh(dbUser.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	yearReport, err := report.Build(&database, *user, dbUser.ID, *year, *limit)
+	if err != nil {
+		panic(err)
+	}
+	database.Rollback()
+
+	err = render(out, yearReport)
+	if err != nil {
+		panic(err)
+	}
+
+	logger.Log(fmt.Sprintf("Rendered %d report for user %s", *year, *user), logger.Info)
+}
@@ -0,0 +1,57 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+const (
+	chartWidth   = 640
+	chartHeight  = 200
+	chartPadding = 24
+)
+
+// barChart draws values as an inline svg bar chart, labelled underneath with labels and above with each value
+func barChart(title string, labels []string, values []float64) template.HTML {
+	max := 0.0
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+
+	slot := float64(chartWidth) / float64(len(values))
+	plotHeight := float64(chartHeight - 2*chartPadding)
+
+	svg := strings.Builder{}
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img" aria-label="%s">`, chartWidth, chartHeight, html.EscapeString(title))
+	for i, value := range values {
+		height := 0.0
+		if max > 0 {
+			height = value / max * plotHeight
+		}
+		x := float64(i)*slot + slot*0.15
+		y := float64(chartPadding) + plotHeight - height
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#1db954"><title>%s: %s</title></rect>`,
+			x, y, slot*0.7, height, html.EscapeString(labels[i]), formatValue(value))
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle">%s</text>`, x+slot*0.35, y-4, formatValue(value))
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" font-size="12" text-anchor="middle">%s</text>`, x+slot*0.35, chartHeight-6, html.EscapeString(labels[i]))
+	}
+	svg.WriteString(`</svg>`)
+
+	return template.HTML(svg.String())
+}
+
+// textBar is barChart for markdown, a run of blocks up to width long
+func textBar(value float64, max float64, width int) string {
+	if max <= 0 {
+		return ""
+	}
+	return strings.Repeat("█", int(value/max*float64(width)+0.5))
+}
+
+func formatValue(value float64) string {
+	return fmt.Sprintf("%.0f", value)
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+
+var funcs = map[string]interface{}{
+	"add":     func(a int, b int) int { return a + b },
+	"percent": func(share float64) string { return formatValue(share * 100) },
+	"minutes": formatValue,
+	"date":    func(r ReplayedDay) string { return r.Day.Format("Monday 2 January") },
+	"playsChart": func(r Report) htmltemplate.HTML {
+		labels, plays, _ := monthSeries(r)
+		return barChart("Plays per month", labels, plays)
+	},
+	"minutesChart": func(r Report) htmltemplate.HTML {
+		labels, _, minutes := monthSeries(r)
+		return barChart("Minutes per month", labels, minutes)
+	},
+	"monthBar": func(r Report, month Month) string {
+		_, plays, _ := monthSeries(r)
+		max := 0.0
+		for _, p := range plays {
+			if p > max {
+				max = p
+			}
+		}
+		return textBar(float64(month.Plays), max, 30)
+	},
+}
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(htmlSource))
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(markdownSource))
+
+// RenderHTML writes the report as a single html page, charts are inline svg so the only external requests are thumbnails
+func RenderHTML(w io.Writer, report Report) error {
+	return htmlTemplate.Execute(w, report)
+}
+
+func RenderMarkdown(w io.Writer, report Report) error {
+	return markdownTemplate.Execute(w, report)
+}
+
+func monthSeries(report Report) ([]string, []float64, []float64) {
+	labels, plays, minutes := []string{}, []float64{}, []float64{}
+	for _, month := range report.Months {
+		labels = append(labels, month.Month.String()[:3])
+		plays = append(plays, float64(month.Plays))
+		minutes = append(minutes, month.Minutes)
+	}
+	return labels, plays, minutes
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Username}}'s {{.Year}} in listening</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; color: #191414; }
+h1, h2 { font-weight: 600; }
+.totals { display: flex; gap: 2em; font-size: 1.2em; }
+.items { list-style: none; padding: 0; }
+.items li { display: flex; align-items: center; gap: 1em; margin: 0.5em 0; }
+.items img { width: 64px; height: 64px; object-fit: cover; border-radius: 4px; }
+.highlight { display: flex; align-items: center; gap: 1em; }
+.highlight img { width: 96px; height: 96px; object-fit: cover; border-radius: 4px; }
+svg { width: 100%; height: auto; }
+table { border-collapse: collapse; }
+td { padding: 0.2em 1em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Username}}'s {{.Year}} in listening</h1>
+<div class="totals">
+<div><strong>{{minutes .Minutes}}</strong> minutes</div>
+<div><strong>{{.Plays}}</strong> plays</div>
+<div><strong>{{.ListenedDays}}</strong> days listened</div>
+</div>
+{{- with .Discovery}}
+<h2>Biggest new discovery</h2>
+<div class="highlight">{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="">{{end}}<div><strong>{{.Name}}</strong><br>{{.Plays}} plays, {{minutes .Minutes}} minutes</div></div>
+{{- end}}
+{{- with .MostReplayed}}
+<h2>Most replayed day</h2>
+<p>On {{date .}} you played <strong>{{.Song}}</strong> {{.Plays}} times.</p>
+{{- end}}
+<h2>Plays per month</h2>
+{{playsChart .}}
+<h2>Minutes per month</h2>
+{{minutesChart .}}
+{{- range $list := .Lists}}
+<h2>{{$list.Title}}</h2>
+<ol class="items">
+{{- range $list.Items}}
+<li>{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="">{{end}}<div><strong>{{.Name}}</strong><br>{{.Plays}} plays, {{minutes .Minutes}} minutes</div></li>
+{{- end}}
+</ol>
+{{- end}}
+{{- if .Genres}}
+<h2>Genres</h2>
+<table>
+{{- range .Genres}}
+<tr><td>{{.Name}}</td><td>{{.Plays}} plays</td><td>{{percent .Share}}%</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`
+
+const markdownSource = `# {{.Username}}'s {{.Year}} in listening
+
+**{{minutes .Minutes}}** minutes, **{{.Plays}}** plays over **{{.ListenedDays}}** days.
+{{- with .Discovery}}
+
+## Biggest new discovery
+
+{{if .Thumbnail}}![]({{.Thumbnail}}) {{end}}**{{.Name}}**, {{.Plays}} plays, {{minutes .Minutes}} minutes
+{{- end}}
+{{- with .MostReplayed}}
+
+## Most replayed day
+
+On {{date .}} you played **{{.Song}}** {{.Plays}} times.
+{{- end}}
+
+## Months
+
+| Month | Plays | Minutes | |
+| --- | ---: | ---: | --- |
+{{- range .Months}}
+| {{.Month}} | {{.Plays}} | {{minutes .Minutes}} | {{monthBar $ .}} |
+{{- end}}
+{{- range $list := .Lists}}
+
+## {{$list.Title}}
+
+| # | | Name | Plays | Minutes |
+| ---: | --- | --- | ---: | ---: |
+{{- range $i, $item := $list.Items}}
+| {{add $i 1}} | {{if $item.Thumbnail}}![]({{$item.Thumbnail}}){{end}} | {{$item.Name}} | {{$item.Plays}} | {{minutes $item.Minutes}} |
+{{- end}}
+{{- end}}
+{{- if .Genres}}
+
+## Genres
+
+| Genre | Plays | Share |
+| --- | ---: | ---: |
+{{- range .Genres}}
+| {{.Name}} | {{.Plays}} | {{percent .Share}}% |
+{{- end}}
+{{- end}}
+`
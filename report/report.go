@@ -0,0 +1,213 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+)
+
+// thumbnailWidth is the width the report shows thumbnails at, the smallest image at least this wide is used
+const thumbnailWidth = 160
+
+type ReportDatabase interface {
+	FetchUserDailyStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyStat, error)
+	FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error)
+	FetchTopDiscoveries(userID string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error)
+	FetchGenrePlays(userID string, from utils.Time, to utils.Time, limit int) ([]models.GenrePlays, error)
+	FetchMostReplayedDay(userID string, from utils.Time, to utils.Time) (models.ReplayedDay, error)
+	FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error)
+}
+
+type Item struct {
+	Name      string
+	SpotifyID string
+	Plays     int
+	Minutes   float64
+	Thumbnail string
+}
+
+type Month struct {
+	Month   time.Month
+	Plays   int
+	Minutes float64
+}
+
+type Genre struct {
+	Name  string
+	Plays int
+	// Share is the genres part of all genre tagged plays, artists with several genres count towards each
+	Share float64
+}
+
+type ReplayedDay struct {
+	Day   time.Time
+	Song  string
+	Plays int
+}
+
+// Report is a users year in listening, built only from the daily stats tables and the catalog in the database
+type Report struct {
+	Username     string
+	Year         int
+	Plays        int
+	Minutes      float64
+	ListenedDays int
+	Months       [12]Month
+	TopSongs     []Item
+	TopAlbums    []Item
+	TopArtists   []Item
+	Genres       []Genre
+	// MostReplayed and Discovery are nil when the year had no plays
+	MostReplayed *ReplayedDay
+	Discovery    *Item
+}
+
+// Build gathers the report for the utc calendar year, the users daily stats should be refreshed beforehand
+func Build(database ReportDatabase, username string, userID string, year int, limit int) (Report, error) {
+	from := utils.Time{Time: time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	to := utils.Time{Time: from.AddDate(1, 0, 0)}
+	report := Report{Username: username, Year: year}
+	for i := range report.Months {
+		report.Months[i].Month = time.Month(i + 1)
+	}
+
+	days, err := database.FetchUserDailyStats(userID, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var estimatedMs int64
+	monthMs := [12]int64{}
+	for _, dailyStat := range days {
+		month := dailyStat.Day.UTC().Month() - 1
+		report.Months[month].Plays += dailyStat.Plays
+		monthMs[month] += dailyStat.EstimatedMs
+		report.Plays += dailyStat.Plays
+		estimatedMs += dailyStat.EstimatedMs
+		if dailyStat.Plays > 0 {
+			report.ListenedDays++
+		}
+	}
+	report.Minutes = msToMinutes(estimatedMs)
+	for i := range report.Months {
+		report.Months[i].Minutes = msToMinutes(monthMs[i])
+	}
+
+	rows := map[string][]models.TopItemRow{}
+	for _, itemType := range []string{models.StatsItemSong, models.StatsItemAlbum, models.StatsItemArtist} {
+		rows[itemType], err = database.FetchTopItems(userID, itemType, from, to, limit)
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	discoveries, err := database.FetchTopDiscoveries(userID, from, to, 1)
+	if err != nil {
+		return Report{}, err
+	}
+
+	allRows := discoveries
+	for _, itemRows := range rows {
+		allRows = append(allRows, itemRows...)
+	}
+	thumbnails, err := fetchThumbnails(database, allRows)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report.TopSongs = items(rows[models.StatsItemSong], thumbnails)
+	report.TopAlbums = items(rows[models.StatsItemAlbum], thumbnails)
+	report.TopArtists = items(rows[models.StatsItemArtist], thumbnails)
+	if len(discoveries) > 0 {
+		report.Discovery = &items(discoveries, thumbnails)[0]
+	}
+
+	genres, err := database.FetchGenrePlays(userID, from, to, limit)
+	if err != nil {
+		return Report{}, err
+	}
+
+	genrePlays := 0
+	for _, genre := range genres {
+		genrePlays += genre.Plays
+	}
+	report.Genres = []Genre{}
+	for _, genre := range genres {
+		report.Genres = append(report.Genres, Genre{Name: genre.Genre, Plays: genre.Plays, Share: float64(genre.Plays) / float64(genrePlays)})
+	}
+
+	replayed, err := database.FetchMostReplayedDay(userID, from, to)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Report{}, err
+	}
+	if err == nil {
+		report.MostReplayed = &ReplayedDay{Day: replayed.Day.UTC(), Song: replayed.Name, Plays: replayed.Plays}
+	}
+
+	return report, nil
+}
+
+// fetchThumbnails returns the url of the thumbnail to show for each rows ThumbnailID
+func fetchThumbnails(database ReportDatabase, rows []models.TopItemRow) (map[string]string, error) {
+	entityIDs := utils.NewStringArgs()
+	for _, row := range rows {
+		entityIDs.Add(row.ThumbnailID)
+	}
+
+	urls := map[string]string{}
+	if len(entityIDs.UniqueMap) == 0 {
+		return urls, nil
+	}
+
+	thumbnails, err := database.FetchThumbnailsByEntityID(entityIDs.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := map[string]models.Thumbnail{}
+	for _, thumbnail := range thumbnails {
+		current, exists := chosen[thumbnail.EntityID]
+		if !exists || betterThumbnail(thumbnail, current) {
+			chosen[thumbnail.EntityID] = thumbnail
+		}
+	}
+	for entityID, thumbnail := range chosen {
+		urls[entityID] = thumbnail.URL
+	}
+	return urls, nil
+}
+
+// betterThumbnail prefers the smallest thumbnail that is still wide enough, or the widest when none are
+func betterThumbnail(candidate models.Thumbnail, current models.Thumbnail) bool {
+	if (candidate.Width >= thumbnailWidth) != (current.Width >= thumbnailWidth) {
+		return candidate.Width >= thumbnailWidth
+	}
+	if candidate.Width >= thumbnailWidth {
+		return candidate.Width < current.Width
+	}
+	return candidate.Width > current.Width
+}
+
+func items(rows []models.TopItemRow, thumbnails map[string]string) []Item {
+	items := []Item{}
+	for _, row := range rows {
+		items = append(items, Item{Name: row.Name, SpotifyID: row.SpotifyID, Plays: row.Plays, Minutes: msToMinutes(row.EstimatedMs), Thumbnail: thumbnails[row.ThumbnailID]})
+	}
+	return items
+}
+
+func msToMinutes(ms int64) float64 {
+	return float64(ms/1000) / 60
+}
+
+type List struct {
+	Title string
+	Items []Item
+}
+
+// Lists is the top songs, albums and artists in the order the report shows them
+func (r Report) Lists() []List {
+	return []List{{"Top artists", r.TopArtists}, {"Top songs", r.TopSongs}, {"Top albums", r.TopAlbums}}
+}
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"database/sql"
+	"spotify/models"
+	"spotify/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockReportDatabase struct {
+	days []models.UserDailyStat
+}
+
+func (db *mockReportDatabase) FetchUserDailyStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyStat, error) {
+	return db.days, nil
+}
+
+func (db *mockReportDatabase) FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	if len(db.days) == 0 {
+		return nil, nil
+	}
+	return []models.TopItemRow{{ItemID: itemType + "-1", Name: "<" + itemType + ">", ThumbnailID: "album-1", Plays: 3, EstimatedMs: 540000}}, nil
+}
+
+func (db *mockReportDatabase) FetchTopDiscoveries(userID string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	if len(db.days) == 0 {
+		return nil, nil
+	}
+	return []models.TopItemRow{{ItemID: "artist-2", Name: "New artist", ThumbnailID: "artist-2", Plays: 2, EstimatedMs: 360000}}, nil
+}
+
+func (db *mockReportDatabase) FetchGenrePlays(userID string, from utils.Time, to utils.Time, limit int) ([]models.GenrePlays, error) {
+	if len(db.days) == 0 {
+		return nil, nil
+	}
+	return []models.GenrePlays{{Genre: "doomgaze", Plays: 3}, {Genre: "dark pop", Plays: 1}}, nil
+}
+
+func (db *mockReportDatabase) FetchMostReplayedDay(userID string, from utils.Time, to utils.Time) (models.ReplayedDay, error) {
+	if len(db.days) == 0 {
+		return models.ReplayedDay{}, sql.ErrNoRows
+	}
+	return models.ReplayedDay{Day: db.days[0].Day, Name: "Song", Plays: 3}, nil
+}
+
+func (db *mockReportDatabase) FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error) {
+	return []models.Thumbnail{
+		{EntityID: "album-1", URL: "large", Width: 640},
+		{EntityID: "album-1", URL: "medium", Width: 300},
+		{EntityID: "album-1", URL: "small", Width: 64},
+		{EntityID: "artist-2", URL: "tiny", Width: 64},
+	}, nil
+}
+
+func TestBuild(t *testing.T) {
+	march := utils.Time{Time: time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)}
+	db := &mockReportDatabase{days: []models.UserDailyStat{
+		{Day: march, Plays: 3, EstimatedMs: 540000},
+		{Day: utils.Time{Time: march.AddDate(0, 0, 1)}, Plays: 1, EstimatedMs: 180000},
+	}}
+
+	report, err := Build(db, "user", "1", 2024, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Plays != 4 || report.Minutes != 12 || report.ListenedDays != 2 || report.Months[time.March-1].Plays != 4 {
+		t.Errorf("Unexpected totals %+v", report)
+	}
+	if report.TopAlbums[0].Thumbnail != "medium" || report.Discovery == nil || report.Discovery.Thumbnail != "tiny" {
+		t.Errorf("Expected the smallest wide enough thumbnail, or the widest, got %+v and %+v", report.TopAlbums, report.Discovery)
+	}
+	if report.Genres[0].Share != 0.75 {
+		t.Errorf("Expected doomgaze to have 75%% of genre plays, got %+v", report.Genres)
+	}
+	if report.MostReplayed == nil || !report.MostReplayed.Day.Equal(march.Time) {
+		t.Errorf("Expected the most replayed day to be march 2nd, got %+v", report.MostReplayed)
+	}
+
+	html := bytes.Buffer{}
+	err = RenderHTML(&html, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(html.String(), "<svg") != 2 || strings.Contains(html.String(), "<song>") || !strings.Contains(html.String(), "&lt;song&gt;") {
+		t.Errorf("Expected two inline charts and escaped names, got %s", html.String())
+	}
+
+	markdown := bytes.Buffer{}
+	err = RenderMarkdown(&markdown, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown.String(), "| March | 4 | 12 |") || !strings.Contains(markdown.String(), "## Biggest new discovery") {
+		t.Errorf("Unexpected markdown %s", markdown.String())
+	}
+}
+
+func TestBuildEmptyYear(t *testing.T) {
+	report, err := Build(&mockReportDatabase{}, "user", "1", 2024, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MostReplayed != nil || report.Discovery != nil {
+		t.Errorf("Expected no highlights for an empty year, got %+v", report)
+	}
+
+	err = RenderHTML(&bytes.Buffer{}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
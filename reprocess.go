@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"spotify/api"
+	"spotify/archive"
+	"spotify/blob"
+	"spotify/database"
+	"spotify/ingest"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func reprocessCommand(args []string) {
+	flags := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	runID := flags.String("run", "", "Id of the run whose archived responses to ingest again")
+	dryRun := flags.Bool("dry-run", false, "Roll back instead of committing what the reprocessed run inserts")
+	options := ingestFlags(flags)
+	flags.Parse(args)
+
+	if *runID == "" {
+		log.Fatalf("Run must be specified!")
+	}
+
+	dbAuth, _ := LoadUsersEnv()
+	database := mustConnect(dbAuth)
+	responses, err := database.FetchRawResponsesByRunID(*runID)
+	if err != nil {
+		panic(err)
+	}
+	if len(responses) == 0 {
+		log.Fatalf("No responses archived for run %s!", *runID)
+	}
+
+	env := LoadEnv(responses[0].Username)
+	if env.ArchiveStore == "" {
+		log.Fatalf("response_archive must be set in env to reprocess a run!")
+	}
+
+	store, err := blob.Open(env.ArchiveStore, env.S3Auth)
+	if err != nil {
+		panic(err)
+	}
+
+	ingestArgs := options()
+	ingestArgs.UserID = responses[0].Username
+	ingestArgs.EnvUsers = env.Users
+	ingestArgs.RunID = ingest.NewIngestContext(ingestArgs).Id
+
+	logger.Log(fmt.Sprintf("Reprocessing %d responses archived by run %s for user %s", len(responses), *runID, ingestArgs.UserID), logger.Info)
+	replayAPI := api.NewReplayAPI(archive.NewReplay(store, *runID, responses), api.NewAPIOptions(0))
+	preingest := ingest.NewPreIngest(&database, env.Users)
+	spotify := ingest.BootstrapSpotifyingest(&database, &replayAPI, &preingest, ingestArgs)
+	err = spotify.Ingest()
+	if err != nil {
+		database.Rollback()
+		panic(err)
+	}
+
+	for _, step := range spotify.Stats.FailedSteps {
+		logger.Log(fmt.Sprintf("Reprocess partially succeeded, step %s was rolled back, %s", step.Name, step.Err), logger.Warning)
+	}
+
+	if *dryRun {
+		database.Rollback()
+		return
+	}
+	database.Commit()
+}
+
+// mustOpenArchiver returns an archiver for the run when a response archive is configured, or nil when it isn't
+func mustOpenArchiver(db *database.Database, env SpotifyIngestEnv, runID string, username string) *archive.Archiver {
+	if env.ArchiveStore == "" {
+		return nil
+	}
+
+	store, err := blob.Open(env.ArchiveStore, env.S3Auth)
+	if err != nil {
+		logger.Log("Failed to open response archive", logger.Error)
+		panic(err)
+	}
+	return archive.NewArchiver(db, store, runID, username)
+}
+
+// closeArchiver writes the index of the runs archived responses, a failure here doesn't undo the ingest
+func closeArchiver(archiver *archive.Archiver) {
+	err := archiver.Close()
+	if err != nil {
+		logger.Log(fmt.Sprintf("Failed to index archived responses, %s", err.Error()), logger.Error)
+	}
+}
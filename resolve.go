@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"spotify/ingest"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func resolveCommand(args []string) {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	user := flags.String("u", "", "Username whose spotify token to look references up with, must have relevant refresh_token in env")
+	limit := flags.Int("n", 500, "Number of unresolved references to retry")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+	spotify, metricHandler := mustBootstrapIngest(env, &database, ingest.SpotifyIngestOptions{UserID: *user, EnvUsers: env.Users})
+	defer metricHandler.Close()
+
+	resolved, err := spotify.ResolveReferences(*limit)
+	if err != nil {
+		database.Rollback()
+		metricHandler.AddNewFailure("RESOLVE", err)
+		panic(err)
+	}
+	database.Commit()
+
+	logger.Log(fmt.Sprintf("Resolved %d references", resolved), logger.Info)
+}
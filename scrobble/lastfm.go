@@ -0,0 +1,190 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the export tools floating around write dates in a handful of formats
+var lastfmDateLayouts = []string{
+	"02 Jan 2006 15:04",
+	"2 Jan 2006 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+type lastfmText struct {
+	Text string `json:"#text"`
+	// extended=1 responses name the artist instead
+	Name string `json:"name"`
+}
+
+func (t lastfmText) String() string {
+	if t.Text != "" {
+		return t.Text
+	}
+	return t.Name
+}
+
+type lastfmTrack struct {
+	Name   string     `json:"name"`
+	Artist lastfmText `json:"artist"`
+	Album  lastfmText `json:"album"`
+	Date   *struct {
+		Uts string `json:"uts"`
+	} `json:"date"`
+}
+
+type lastfmPage struct {
+	RecentTracks struct {
+		Track []lastfmTrack `json:"track"`
+	} `json:"recenttracks"`
+}
+
+// ParseLastfmJSON reads a last.fm export made of user.getRecentTracks responses, either a single page,
+// an array of pages or a bare array of tracks. The now playing track has no date and is skipped
+func ParseLastfmJSON(r io.Reader) ([]Scrobble, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := []lastfmTrack{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		page := lastfmPage{}
+		err = json.Unmarshal(data, &page)
+		if err != nil {
+			return nil, err
+		}
+		tracks = page.RecentTracks.Track
+	} else {
+		raw := []json.RawMessage{}
+		err = json.Unmarshal(data, &raw)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range raw {
+			page := lastfmPage{}
+			err = json.Unmarshal(item, &page)
+			if err != nil {
+				return nil, err
+			}
+
+			if page.RecentTracks.Track != nil {
+				tracks = append(tracks, page.RecentTracks.Track...)
+				continue
+			}
+
+			track := lastfmTrack{}
+			err = json.Unmarshal(item, &track)
+			if err != nil {
+				return nil, err
+			}
+			tracks = append(tracks, track)
+		}
+	}
+
+	scrobbles := []Scrobble{}
+	for _, track := range tracks {
+		if track.Date == nil {
+			continue
+		}
+
+		uts, err := strconv.ParseInt(track.Date.Uts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad timestamp %q for %s: %w", track.Date.Uts, track.Name, err)
+		}
+
+		scrobbles = append(scrobbles, Scrobble{
+			ListenedAt: time.Unix(uts, 0).UTC(),
+			ArtistName: track.Artist.String(),
+			TrackName:  track.Name,
+			AlbumName:  track.Album.String(),
+		})
+	}
+
+	return scrobbles, nil
+}
+
+// ParseLastfmCSV reads a last.fm csv export. Files with a header row are read by column name (uts or utc_time, artist,
+// album, track), headerless ones are assumed to be the common artist,album,track,date layout
+func ParseLastfmCSV(r io.Reader) ([]Scrobble, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return []Scrobble{}, nil
+	}
+
+	columns := map[string]int{"artist": 0, "album": 1, "track": 2, "date": 3}
+	header := map[string]int{}
+	for i, name := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	dateColumn := "date"
+	for _, name := range []string{"uts", "utc_time", "date"} {
+		if _, exists := header[name]; exists {
+			dateColumn = name
+			_, hasArtist := header["artist"]
+			_, hasTrack := header["track"]
+			if hasArtist && hasTrack {
+				columns = header
+				rows = rows[1:]
+			}
+			break
+		}
+	}
+
+	scrobbles := []Scrobble{}
+	for line, row := range rows {
+		field := func(name string) string {
+			i, exists := columns[name]
+			if !exists || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		listenedAt, err := parseLastfmDate(field(dateColumn))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", line+1, err)
+		}
+
+		scrobbles = append(scrobbles, Scrobble{
+			ListenedAt: listenedAt,
+			ArtistName: field("artist"),
+			TrackName:  field("track"),
+			AlbumName:  field("album"),
+		})
+	}
+
+	return scrobbles, nil
+}
+
+func parseLastfmDate(date string) (time.Time, error) {
+	if uts, err := strconv.ParseInt(date, 10, 64); err == nil {
+		return time.Unix(uts, 0).UTC(), nil
+	}
+
+	for _, layout := range lastfmDateLayouts {
+		listenedAt, err := time.Parse(layout, date)
+		if err == nil {
+			return listenedAt.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q", date)
+}
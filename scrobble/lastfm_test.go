@@ -0,0 +1,67 @@
+package scrobble
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLastfmCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Headerless", "Artist,Album,Track,01 Jan 2020 10:00\n"},
+		{"Header with unix timestamps", "uts,utc_time,artist,artist_mbid,album,album_mbid,track,track_mbid\n1577872800,\"01 Jan 2020, 10:00\",Artist,,Album,,Track,\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scrobbles, err := ParseLastfmCSV(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(scrobbles) != 1 {
+				t.Fatalf("Expected 1 scrobble, got %d", len(scrobbles))
+			}
+			scrobble := scrobbles[0]
+			if scrobble.ArtistName != "Artist" || scrobble.AlbumName != "Album" || scrobble.TrackName != "Track" || scrobble.ListenedAt.Unix() != 1577872800 {
+				t.Errorf("Unexpected scrobble %+v", scrobble)
+			}
+		})
+	}
+}
+
+func TestParseLastfmJSON(t *testing.T) {
+	page := `{"recenttracks": {"track": [
+		{"name": "Now", "artist": {"#text": "Artist"}, "@attr": {"nowplaying": "true"}},
+		{"name": "Track", "artist": {"#text": "Artist"}, "album": {"#text": "Album"}, "date": {"uts": "1577872800"}}
+	]}}`
+
+	for _, input := range []string{page, "[" + page + "]"} {
+		scrobbles, err := ParseLastfmJSON(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(scrobbles) != 1 || scrobbles[0].TrackName != "Track" || scrobbles[0].ListenedAt.Unix() != 1577872800 {
+			t.Errorf("Expected only the dated track, got %+v", scrobbles)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Song":                             "song",
+		"Song (feat. Someone)":             "song",
+		"Song - 2011 Remaster":             "song",
+		"  Song  [Live]  - Live at Venue ": "song",
+		"Two  Words":                       "two words",
+	}
+
+	for input, expected := range tests {
+		if actual := Normalize(input); actual != expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
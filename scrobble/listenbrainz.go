@@ -0,0 +1,199 @@
+package scrobble
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	ListenBrainzURL = "https://api.listenbrainz.org"
+	// listenbrainz rejects submit-listens requests with more listens than this
+	MaxListensPerPayload = 1000
+	submissionClient     = "spotify-ingest"
+)
+
+type TrackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo AdditionalInfo `json:"additional_info"`
+}
+
+type AdditionalInfo struct {
+	SpotifyID        string `json:"spotify_id,omitempty"`
+	OriginURL        string `json:"origin_url,omitempty"`
+	MusicService     string `json:"music_service,omitempty"`
+	SubmissionClient string `json:"submission_client,omitempty"`
+}
+
+type Listen struct {
+	ListenedAt    int64         `json:"listened_at"`
+	TrackMetadata TrackMetadata `json:"track_metadata"`
+}
+
+// SubmitListensPayload is the body of a listenbrainz submit-listens request
+type SubmitListensPayload struct {
+	ListenType string   `json:"listen_type"`
+	Payload    []Listen `json:"payload"`
+}
+
+// NewListen builds a listen for a spotify track, linking back to it so listenbrainz can map it
+func NewListen(listenedAt time.Time, artistName string, trackName string, albumName string, spotifyID string) Listen {
+	trackURL := fmt.Sprintf("https://open.spotify.com/track/%s", spotifyID)
+	return Listen{
+		ListenedAt: listenedAt.Unix(),
+		TrackMetadata: TrackMetadata{
+			ArtistName:  artistName,
+			TrackName:   trackName,
+			ReleaseName: albumName,
+			AdditionalInfo: AdditionalInfo{
+				SpotifyID:        trackURL,
+				OriginURL:        trackURL,
+				MusicService:     "spotify.com",
+				SubmissionClient: submissionClient,
+			},
+		},
+	}
+}
+
+// NewImportPayloads splits listens into as few import payloads as listenbrainz will accept
+func NewImportPayloads(listens []Listen) []SubmitListensPayload {
+	payloads := []SubmitListensPayload{}
+	for start := 0; start < len(listens); start += MaxListensPerPayload {
+		end := start + MaxListensPerPayload
+		if end > len(listens) {
+			end = len(listens)
+		}
+		payloads = append(payloads, SubmitListensPayload{ListenType: "import", Payload: listens[start:end]})
+	}
+	return payloads
+}
+
+// ParseListenBrainz reads a listenbrainz listens export, either a json array or one listen per line
+func ParseListenBrainz(r io.Reader) ([]Scrobble, error) {
+	reader := bufio.NewReader(r)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return []Scrobble{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listens := []Listen{}
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		err = decoder.Decode(&listens)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for decoder.More() {
+			listen := Listen{}
+			err = decoder.Decode(&listen)
+			if err != nil {
+				return nil, err
+			}
+			listens = append(listens, listen)
+		}
+	}
+
+	scrobbles := []Scrobble{}
+	for _, listen := range listens {
+		scrobbles = append(scrobbles, Scrobble{
+			ListenedAt: time.Unix(listen.ListenedAt, 0).UTC(),
+			ArtistName: listen.TrackMetadata.ArtistName,
+			TrackName:  listen.TrackMetadata.TrackName,
+			AlbumName:  listen.TrackMetadata.ReleaseName,
+			SpotifyID:  spotifyTrackID(listen.TrackMetadata.AdditionalInfo.SpotifyID),
+		})
+	}
+
+	return scrobbles, nil
+}
+
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// spotifyTrackID pulls the track id out of the open.spotify.com url listenbrainz stores
+func spotifyTrackID(spotifyURL string) string {
+	if !strings.Contains(spotifyURL, "/track/") {
+		return ""
+	}
+	id := spotifyURL[strings.LastIndex(spotifyURL, "/")+1:]
+	return strings.SplitN(id, "?", 2)[0]
+}
+
+// Submitter posts payloads to a listenbrainz compatible server, waiting out rate limits and retrying server errors
+type Submitter struct {
+	URL     string
+	Token   string
+	Retries int
+	Client  *http.Client
+}
+
+func NewSubmitter(url string, token string, retries int) Submitter {
+	return Submitter{
+		URL:     strings.TrimSuffix(url, "/"),
+		Token:   token,
+		Retries: retries,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *Submitter) Submit(payload SubmitListensPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s/1/submit-listens", s.URL), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", s.Token))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= s.Retries {
+			return fmt.Errorf("submit-listens failed with %d: %s", resp.StatusCode, string(respBody))
+		}
+
+		wait := time.Duration(attempt+1) * time.Second
+		if resetIn, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Reset-In")); err == nil {
+			wait = time.Duration(resetIn) * time.Second
+		}
+		logger.Log(fmt.Sprintf("submit-listens returned %d, retrying in %s", resp.StatusCode, wait), logger.Warning)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,93 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubmitter_Submit(t *testing.T) {
+	received := []SubmitListensPayload{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/1/submit-listens" || r.Header.Get("Authorization") != "Token abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// rate limit the first attempt to check it gets retried
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Reset-In", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		payload := SubmitListensPayload{}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, payload)
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	listenedAt := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	listens := []Listen{}
+	for i := 0; i < MaxListensPerPayload+1; i++ {
+		listens = append(listens, NewListen(listenedAt.Add(time.Duration(i)*time.Minute), "Artist", "Track", "Album", "abc123"))
+	}
+
+	submitter := NewSubmitter(server.URL+"/", "abc", 1)
+	for _, payload := range NewImportPayloads(listens) {
+		err := submitter.Submit(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != 2 || len(received[0].Payload) != MaxListensPerPayload || len(received[1].Payload) != 1 {
+		t.Fatalf("Expected payloads of %d and 1 listens, got %d payloads", MaxListensPerPayload, len(received))
+	}
+	if received[0].ListenType != "import" || received[0].Payload[0].ListenedAt != listenedAt.Unix() {
+		t.Errorf("Unexpected first listen %+v", received[0].Payload[0])
+	}
+
+	badToken := NewSubmitter(server.URL, "wrong", 3)
+	err := badToken.Submit(NewImportPayloads(listens[:1])[0])
+	if err == nil {
+		t.Error("Expected unauthorized submission to fail without retrying")
+	}
+}
+
+func TestParseListenBrainz(t *testing.T) {
+	listen := `{"listened_at": 1577872800, "track_metadata": {"artist_name": "Artist", "track_name": "Track", "additional_info": {"spotify_id": "https://open.spotify.com/track/abc123"}}}`
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Json array", "[" + listen + "," + listen + "]"},
+		{"One listen per line", listen + "\n" + listen + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scrobbles, err := ParseListenBrainz(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(scrobbles) != 2 {
+				t.Fatalf("Expected 2 scrobbles, got %d", len(scrobbles))
+			}
+			if scrobbles[0].SpotifyID != "abc123" || scrobbles[0].ListenedAt.Unix() != 1577872800 {
+				t.Errorf("Unexpected scrobble %+v", scrobbles[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package scrobble
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Scrobble is one listen from another service, which only knows tracks by name
+type Scrobble struct {
+	ListenedAt time.Time
+	ArtistName string
+	TrackName  string
+	AlbumName  string
+	// SpotifyID is set when the source already recorded which spotify track was played, eg. listenbrainz listens submitted from spotify
+	SpotifyID string
+}
+
+var (
+	bracketed = regexp.MustCompile(`\s*[\(\[][^\)\]]*[\)\]]`)
+	// "Song - Remastered 2011", "Song - Live at Wembley" and the like
+	versionSuffix = regexp.MustCompile(`\s+-\s+.*$`)
+	spaces        = regexp.MustCompile(`\s+`)
+)
+
+// Normalize lowercases a track or artist name and strips the version noise services disagree on,
+// so "Song (feat. X) - 2011 Remaster" and "song" compare equal
+func Normalize(name string) string {
+	name = strings.ToLower(name)
+	name = bracketed.ReplaceAllString(name, "")
+	name = versionSuffix.ReplaceAllString(name, "")
+	name = spaces.ReplaceAllString(name, " ")
+	return strings.TrimSpace(name)
+}
+
+// Key identifies scrobbles of the same track regardless of how each service formats its name
+func (s *Scrobble) Key() (string, string) {
+	return Normalize(s.ArtistName), Normalize(s.TrackName)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"spotify/server"
+	"syscall"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+func serveCommand(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("a", ":8080", "Address to listen on")
+	flags.Parse(args)
+
+	dbAuth, apiKeys := LoadServeEnv()
+	database := mustConnect(dbAuth)
+
+	api := server.NewServer(func(ctx context.Context) (server.ReadDatabase, error) {
+		return database.ReadOnly(ctx)
+	}, apiKeys)
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		logger.Log("Shutting down api server", logger.Info)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Log(fmt.Sprintf("Serving api on %s, openapi document at /openapi.json", *addr), logger.Info)
+	err := httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Schema map[string]interface{}
+
+// OpenAPI describes routes as an openapi 3 document. Response schemas are reflected from each routes example
+// response, named structs become shared components referenced by name
+func OpenAPI(routes []route) Schema {
+	components := Schema{}
+	paths := Schema{}
+
+	errorResponse := Schema{
+		"description": "Error",
+		"content":     Schema{"application/json": Schema{"schema": schemaOf(reflect.TypeOf(ErrorResponse{}), components)}},
+	}
+
+	for _, route := range routes {
+		parameters := []Schema{}
+		for _, param := range route.Params {
+			paramSchema := Schema{"type": "string"}
+			if len(param.Enum) > 0 {
+				paramSchema["enum"] = param.Enum
+			}
+			parameters = append(parameters, Schema{
+				"name":        param.Name,
+				"in":          param.In,
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      paramSchema,
+			})
+		}
+
+		operation := Schema{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"parameters":  parameters,
+			"responses": Schema{
+				"200": Schema{
+					"description": "OK",
+					"content":     Schema{"application/json": Schema{"schema": schemaOf(reflect.TypeOf(route.Response), components)}},
+				},
+				"default": errorResponse,
+			},
+		}
+
+		path, exists := paths[route.Path].(Schema)
+		if !exists {
+			path = Schema{}
+			paths[route.Path] = path
+		}
+		path[strings.ToLower(route.Method)] = operation
+	}
+
+	return Schema{
+		"openapi": "3.0.3",
+		"info": Schema{
+			"title":   "Spotify ingest",
+			"version": "1.0.0",
+		},
+		"security": []Schema{{"apiKey": []string{}}},
+		"paths":    paths,
+		"components": Schema{
+			"schemas": components,
+			"securitySchemes": Schema{
+				"apiKey": Schema{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// operationID turns GET /users/{user}/top-songs into getUsersUserTopSongs
+func operationID(route route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool { return strings.ContainsRune("/{}-", r) }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOf(t reflect.Type, components Schema) Schema {
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), components)
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), components)}
+	case reflect.Struct:
+		name := t.Name()
+		// the export row types share names with some of ours
+		if t.PkgPath() != reflect.TypeOf(route{}).PkgPath() {
+			pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		if _, exists := components[name]; !exists {
+			// claim the name before recursing in case the type refers back to itself
+			components[name] = Schema{}
+			components[name] = structSchema(t, components)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		return Schema{}
+	}
+}
+
+func structSchema(t reflect.Type, components Schema) Schema {
+	properties := Schema{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if !field.IsExported() || tag[0] == "-" {
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, components)
+		if len(tag) == 1 || tag[1] != "omitempty" {
+			required = append(required, name)
+		}
+	}
+
+	return Schema{"type": "object", "properties": properties, "required": required}
+}
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"spotify/compare"
+	"spotify/export"
+	"spotify/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListenLimit  = 50
+	maxListenLimit      = 500
+	defaultCompareLimit = 20
+	dateLayout          = "2006-01-02"
+)
+
+var timePeriods = []string{"short", "medium", "long"}
+
+type param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+func pathParam(name string, description string) param {
+	return param{Name: name, In: "path", Description: description, Required: true}
+}
+
+func queryParam(name string, description string) param {
+	return param{Name: name, In: "query", Description: description}
+}
+
+// route is a handler along with everything needed to describe it in the openapi document,
+// Response is an example of what the handler returns and is only used for its type
+type route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Params   []param
+	Response interface{}
+	Handle   func(db ReadDatabase, r *http.Request) (interface{}, error)
+}
+
+type User struct {
+	Username  string `json:"username"`
+	SpotifyID string `json:"spotify_id"`
+}
+
+type ListensPage struct {
+	Listens []export.Listen `json:"listens"`
+	// Next is the before cursor for the following page, the played_at and id of the last listen, empty on the last page
+	Next string `json:"next"`
+}
+
+type TopSongs struct {
+	SnapshotAt time.Time        `json:"snapshot_at"`
+	TimePeriod string           `json:"time_period"`
+	Songs      []export.TopSong `json:"songs"`
+}
+
+type TopArtists struct {
+	SnapshotAt time.Time          `json:"snapshot_at"`
+	TimePeriod string             `json:"time_period"`
+	Artists    []export.TopArtist `json:"artists"`
+}
+
+type DailyPlays struct {
+	Day   string `json:"day"`
+	Plays int    `json:"plays"`
+}
+
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type EntityRef struct {
+	SpotifyID string `json:"spotify_id"`
+	Name      string `json:"name"`
+}
+
+type Artist struct {
+	SpotifyID  string      `json:"spotify_id"`
+	Name       string      `json:"name"`
+	Thumbnails []Thumbnail `json:"thumbnails"`
+}
+
+type Album struct {
+	SpotifyID  string      `json:"spotify_id"`
+	Name       string      `json:"name"`
+	Artist     EntityRef   `json:"artist"`
+	Thumbnails []Thumbnail `json:"thumbnails"`
+}
+
+type Song struct {
+	SpotifyID string    `json:"spotify_id"`
+	Name      string    `json:"name"`
+	Album     EntityRef `json:"album"`
+	Artist    EntityRef `json:"artist"`
+	// Thumbnails are the albums artwork
+	Thumbnails []Thumbnail `json:"thumbnails"`
+}
+
+func routes() []route {
+	user := pathParam("user", "Username of the user")
+	spotifyID := pathParam("id", "Spotify ID")
+	term := param{Name: "term", In: "query", Description: "Time period the list covers, defaults to short", Enum: timePeriods}
+	at := queryParam("at", "Use the latest snapshot taken on or before this date (YYYY-MM-DD), defaults to now")
+
+	return []route{
+		{"GET", "/users", "List users", nil, []User{}, listUsers},
+		{"GET", "/users/{user}/listens", "A users listens, newest first", []param{
+			user,
+			queryParam("before", "Only listens played before this RFC3339 time, or after the cursor in the previous pages next"),
+			queryParam("limit", "Page size, at most 500, defaults to 50"),
+		}, ListensPage{}, listListens},
+		{"GET", "/users/{user}/top-songs", "A users top songs snapshot", []param{user, term, at}, TopSongs{}, topSongs},
+		{"GET", "/users/{user}/top-artists", "A users top artists snapshot", []param{user, term, at}, TopArtists{}, topArtists},
+		{"GET", "/users/{user}/plays-per-day", "How many listens a user had each day", []param{
+			user,
+			queryParam("from", "First day to count (YYYY-MM-DD), defaults to 30 days ago"),
+			queryParam("to", "Day to count up to, exclusive (YYYY-MM-DD), defaults to tomorrow"),
+		}, []DailyPlays{}, playsPerDay},
+		{"GET", "/compare", "Taste similarity, shared artists and songs and weekly leaderboards between users", []param{
+			queryParam("users", "Comma separated usernames to compare, defaults to every user"),
+			queryParam("from", "First day to compare (YYYY-MM-DD), defaults to 30 days ago"),
+			queryParam("to", "Day to compare up to, exclusive (YYYY-MM-DD), defaults to tomorrow"),
+			queryParam("limit", "How many top items the jaccard scores use and shared items to list, defaults to 20"),
+		}, compare.Comparison{}, compareUsers},
+		{"GET", "/songs/{id}", "Song details", []param{spotifyID}, Song{}, getSong},
+		{"GET", "/albums/{id}", "Album details", []param{spotifyID}, Album{}, getAlbum},
+		{"GET", "/artists/{id}", "Artist details", []param{spotifyID}, Artist{}, getArtist},
+	}
+}
+
+func listUsers(db ReadDatabase, r *http.Request) (interface{}, error) {
+	users, err := db.FetchUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	response := []User{}
+	for _, user := range users {
+		response = append(response, User{Username: user.Username, SpotifyID: user.SpotifyID})
+	}
+	return response, nil
+}
+
+func listListens(db ReadDatabase, r *http.Request) (interface{}, error) {
+	user, err := db.FetchUserByName(r.PathValue("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	before, beforeID := time.Now().UTC(), ""
+	if value := r.URL.Query().Get("before"); value != "" {
+		before, beforeID, err = parseListensCursor(value)
+		if err != nil {
+			return nil, badRequest("before must be an RFC3339 time or a previous pages next")
+		}
+	}
+
+	limit := defaultListenLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListenLimit {
+			return nil, badRequest("limit must be between 1 and %d", maxListenLimit)
+		}
+	}
+
+	rows, err := db.FetchListenRowsByUserID(user.ID, before.UTC(), beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := ListensPage{Listens: []export.Listen{}}
+	for _, row := range rows {
+		page.Listens = append(page.Listens, export.NewListen(row))
+	}
+	if len(rows) == limit {
+		last := rows[len(rows)-1]
+		page.Next = last.PlayedAt.UTC().Format(time.RFC3339Nano) + "," + last.ID
+	}
+	return page, nil
+}
+
+// parseListensCursor splits a listens cursor into the played_at and id of the listen the previous page ended on.
+// Listens played at the same time are told apart by id, so none are skipped between pages. A bare time has no id
+func parseListensCursor(value string) (time.Time, string, error) {
+	at, id, _ := strings.Cut(value, ",")
+	before, err := time.Parse(time.RFC3339Nano, at)
+	return before, id, err
+}
+
+func snapshotQuery(r *http.Request) (string, utils.Time, error) {
+	term := r.URL.Query().Get("term")
+	if term == "" {
+		term = timePeriods[0]
+	}
+	if !slices.Contains(timePeriods, term) {
+		return "", utils.Time{}, badRequest("term must be one of %v", timePeriods)
+	}
+
+	at := time.Now().UTC()
+	if value := r.URL.Query().Get("at"); value != "" {
+		day, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return "", utils.Time{}, badRequest("at must be a date (YYYY-MM-DD)")
+		}
+		// include snapshots taken during the day itself
+		at = day.AddDate(0, 0, 1)
+	}
+
+	return term, utils.Time{Time: at}, nil
+}
+
+func topSongs(db ReadDatabase, r *http.Request) (interface{}, error) {
+	user, err := db.FetchUserByName(r.PathValue("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	term, at, err := snapshotQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.FetchTopSongRowsAt(user.ID, term, at)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, notFound("no top songs snapshot for %s", term)
+	}
+
+	response := TopSongs{SnapshotAt: rows[0].SnapshotAt.Time, TimePeriod: term, Songs: []export.TopSong{}}
+	for _, row := range rows {
+		response.Songs = append(response.Songs, export.NewTopSong(row))
+	}
+	return response, nil
+}
+
+func topArtists(db ReadDatabase, r *http.Request) (interface{}, error) {
+	user, err := db.FetchUserByName(r.PathValue("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	term, at, err := snapshotQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.FetchTopArtistRowsAt(user.ID, term, at)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, notFound("no top artists snapshot for %s", term)
+	}
+
+	response := TopArtists{SnapshotAt: rows[0].SnapshotAt.Time, TimePeriod: term, Artists: []export.TopArtist{}}
+	for _, row := range rows {
+		response.Artists = append(response.Artists, export.NewTopArtist(row))
+	}
+	return response, nil
+}
+
+func playsPerDay(db ReadDatabase, r *http.Request) (interface{}, error) {
+	user, err := db.FetchUserByName(r.PathValue("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, err := dateRangeQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := db.FetchPlaysPerDay(user.ID, utils.Time{Time: from}, utils.Time{Time: to})
+	if err != nil {
+		return nil, err
+	}
+
+	response := []DailyPlays{}
+	for _, day := range days {
+		response = append(response, DailyPlays{Day: day.Day.Format(dateLayout), Plays: day.Plays})
+	}
+	return response, nil
+}
+
+// dateRangeQuery reads the from and to days, defaulting to the last 30 days including today
+func dateRangeQuery(r *http.Request) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -30), today.AddDate(0, 0, 1)
+	for name, value := range map[string]*time.Time{"from": &from, "to": &to} {
+		query := r.URL.Query().Get(name)
+		if query == "" {
+			continue
+		}
+
+		var err error
+		*value, err = time.Parse(dateLayout, query)
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequest("%s must be a date (YYYY-MM-DD)", name)
+		}
+	}
+	return from, to, nil
+}
+
+func compareUsers(db ReadDatabase, r *http.Request) (interface{}, error) {
+	from, to, err := dateRangeQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := defaultCompareLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, badRequest("limit must be a positive number")
+		}
+	}
+
+	usernames := []string{}
+	if value := r.URL.Query().Get("users"); value != "" {
+		usernames = strings.Split(value, ",")
+	} else {
+		users, err := db.FetchUsers()
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	if len(usernames) < 2 {
+		return nil, badRequest("at least two users are needed to compare")
+	}
+
+	return compare.Compare(db, usernames, from, to, limit)
+}
+
+func thumbnails(db ReadDatabase, entityID string) ([]Thumbnail, error) {
+	dbThumbnails, err := db.FetchThumbnailsByEntityID([]interface{}{entityID})
+	if err != nil {
+		return nil, err
+	}
+
+	response := []Thumbnail{}
+	for _, thumbnail := range dbThumbnails {
+		response = append(response, Thumbnail{URL: thumbnail.URL, Width: thumbnail.Width, Height: thumbnail.Height})
+	}
+	return response, nil
+}
+
+func getSong(db ReadDatabase, r *http.Request) (interface{}, error) {
+	songs, err := db.FetchSongsBySpotifyID([]interface{}{r.PathValue("id")})
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 {
+		return nil, notFound("no song %s", r.PathValue("id"))
+	}
+	song := songs[0]
+
+	album, err := db.FetchAlbumByID(song.AlbumID)
+	if err != nil {
+		return nil, err
+	}
+
+	artist, err := db.FetchArtistByID(song.ArtistID)
+	if err != nil {
+		return nil, err
+	}
+
+	albumThumbnails, err := thumbnails(db, album.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Song{
+		SpotifyID:  song.SpotifyID,
+		Name:       song.Name,
+		Album:      EntityRef{SpotifyID: album.SpotifyID, Name: album.Name},
+		Artist:     EntityRef{SpotifyID: artist.SpotifyID, Name: artist.Name},
+		Thumbnails: albumThumbnails,
+	}, nil
+}
+
+func getAlbum(db ReadDatabase, r *http.Request) (interface{}, error) {
+	albums, err := db.FetchAlbumsBySpotifyID([]interface{}{r.PathValue("id")})
+	if err != nil {
+		return nil, err
+	}
+	if len(albums) == 0 {
+		return nil, notFound("no album %s", r.PathValue("id"))
+	}
+	album := albums[0]
+
+	artist, err := db.FetchArtistByID(album.ArtistID)
+	if err != nil {
+		return nil, err
+	}
+
+	albumThumbnails, err := thumbnails(db, album.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Album{
+		SpotifyID:  album.SpotifyID,
+		Name:       album.Name,
+		Artist:     EntityRef{SpotifyID: artist.SpotifyID, Name: artist.Name},
+		Thumbnails: albumThumbnails,
+	}, nil
+}
+
+func getArtist(db ReadDatabase, r *http.Request) (interface{}, error) {
+	artists, err := db.FetchArtistsBySpotifyID([]interface{}{r.PathValue("id")})
+	if err != nil {
+		return nil, err
+	}
+	if len(artists) == 0 {
+		return nil, notFound("no artist %s", r.PathValue("id"))
+	}
+	artist := artists[0]
+
+	artistThumbnails, err := thumbnails(db, artist.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Artist{
+		SpotifyID:  artist.SpotifyID,
+		Name:       artist.Name,
+		Thumbnails: artistThumbnails,
+	}, nil
+}
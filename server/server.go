@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"spotify/models"
+	"spotify/utils"
+	"strings"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// ReadDatabase is everything the api reads, each request gets its own read only transaction
+type ReadDatabase interface {
+	FetchUsers() ([]models.User, error)
+	FetchUserByName(name string) (models.User, error)
+	FetchListenRowsByUserID(userID string, before time.Time, beforeID string, limit int) ([]models.ListenRow, error)
+	FetchTopSongRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopSongRow, error)
+	FetchTopArtistRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopArtistRow, error)
+	FetchPlaysPerDay(userID string, from utils.Time, to utils.Time) ([]models.DailyPlays, error)
+	FetchSongsBySpotifyID(spotifyIDs []interface{}) ([]models.Song, error)
+	FetchAlbumsBySpotifyID(spotifyIDs []interface{}) ([]models.Album, error)
+	FetchArtistsBySpotifyID(spotifyIDs []interface{}) ([]models.Artist, error)
+	FetchAlbumByID(id string) (models.Album, error)
+	FetchArtistByID(id string) (models.Artist, error)
+	FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error)
+	FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error)
+	FetchFirstListensByUserID(userID string) ([]models.FirstListen, error)
+	FetchWeeklyTotals(userID string, from utils.Time, to utils.Time) ([]models.WeeklyTotal, error)
+	Release()
+}
+
+// BeginRead starts a read only transaction for a single request
+type BeginRead func(ctx context.Context) (ReadDatabase, error)
+
+type Server struct {
+	begin   BeginRead
+	apiKeys []string
+	routes  []route
+}
+
+type httpError struct {
+	Status  int
+	Message string
+}
+
+func (e *httpError) Error() string {
+	return e.Message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &httpError{Status: http.StatusNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(begin BeginRead, apiKeys []string) Server {
+	return Server{
+		begin:   begin,
+		apiKeys: apiKeys,
+		routes:  routes(),
+	}
+}
+
+// Handler serves every route behind api key auth, plus the openapi document describing them which needs no key
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range s.routes {
+		mux.Handle(fmt.Sprintf("%s %s", route.Method, route.Path), s.authenticate(s.serve(route)))
+	}
+
+	document := OpenAPI(s.routes)
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, document)
+	})
+
+	return mux
+}
+
+// authenticate accepts a key in either the X-API-Key header or as a bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+
+		for _, apiKey := range s.apiKeys {
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "missing or invalid api key"})
+	})
+}
+
+func (s *Server) serve(route route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db, err := s.begin(r.Context())
+		if err != nil {
+			logger.Log(fmt.Sprintf("Failed to start read transaction, %s", err.Error()), logger.Error)
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "database unavailable"})
+			return
+		}
+		defer db.Release()
+
+		response, err := route.Handle(db, r)
+		if err != nil {
+			httpErr := &httpError{}
+			switch {
+			case errors.As(err, &httpErr):
+				writeJSON(w, httpErr.Status, ErrorResponse{Error: httpErr.Message})
+			case errors.Is(err, sql.ErrNoRows):
+				writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+			default:
+				logger.Log(fmt.Sprintf("%s %s failed, %s", r.Method, r.URL.Path, err.Error()), logger.Error)
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal error"})
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
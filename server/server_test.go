@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+type mockReadDatabase struct {
+	listens  []models.ListenRow
+	released bool
+}
+
+func (db *mockReadDatabase) FetchUsers() ([]models.User, error) {
+	return []models.User{{ID: "1", Username: "user", Password: "secret", SpotifyID: "spotify-user"}}, nil
+}
+
+func (db *mockReadDatabase) FetchUserByName(name string) (models.User, error) {
+	if name != "user" {
+		return models.User{}, sql.ErrNoRows
+	}
+	return models.User{ID: "1", Username: "user"}, nil
+}
+
+func (db *mockReadDatabase) FetchListenRowsByUserID(userID string, before time.Time, beforeID string, limit int) ([]models.ListenRow, error) {
+	rows := []models.ListenRow{}
+	for _, listen := range db.listens {
+		earlier := listen.PlayedAt.Before(before) || (listen.PlayedAt.Equal(before) && listen.ID < beforeID)
+		if earlier && len(rows) < limit {
+			rows = append(rows, listen)
+		}
+	}
+	return rows, nil
+}
+
+func (db *mockReadDatabase) FetchTopSongRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopSongRow, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchTopArtistRowsAt(userID string, timePeriod string, at utils.Time) ([]models.TopArtistRow, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchPlaysPerDay(userID string, from utils.Time, to utils.Time) ([]models.DailyPlays, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchSongsBySpotifyID(spotifyIDs []interface{}) ([]models.Song, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchAlbumsBySpotifyID(spotifyIDs []interface{}) ([]models.Album, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchArtistsBySpotifyID(spotifyIDs []interface{}) ([]models.Artist, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchAlbumByID(id string) (models.Album, error) {
+	return models.Album{}, sql.ErrNoRows
+}
+
+func (db *mockReadDatabase) FetchArtistByID(id string) (models.Artist, error) {
+	return models.Artist{}, sql.ErrNoRows
+}
+
+func (db *mockReadDatabase) FetchThumbnailsByEntityID(entityIDs []interface{}) ([]models.Thumbnail, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchFirstListensByUserID(userID string) ([]models.FirstListen, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) FetchWeeklyTotals(userID string, from utils.Time, to utils.Time) ([]models.WeeklyTotal, error) {
+	return nil, nil
+}
+
+func (db *mockReadDatabase) Release() {
+	db.released = true
+}
+
+func TestServer(t *testing.T) {
+	newest := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	db := &mockReadDatabase{}
+	// the two newest listens were played in the same second, newest first by (played_at, id)
+	db.listens = []models.ListenRow{
+		{ID: "c", PlayedAt: utils.Time{Time: newest}, SongName: "Song"},
+		{ID: "b", PlayedAt: utils.Time{Time: newest}, SongName: "Song"},
+		{ID: "a", PlayedAt: utils.Time{Time: newest.Add(-time.Minute)}, SongName: "Song"},
+	}
+
+	server := NewServer(func(ctx context.Context) (ReadDatabase, error) { return db, nil }, []string{"key"})
+	handler := server.Handler()
+
+	get := func(path string, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{"Missing key", "/users", "", http.StatusUnauthorized},
+		{"Wrong key", "/users", "nope", http.StatusUnauthorized},
+		{"Users", "/users", "key", http.StatusOK},
+		{"Unknown user", "/users/someone/listens", "key", http.StatusNotFound},
+		{"Bad limit", "/users/user/listens?limit=0", "key", http.StatusBadRequest},
+		{"Bad term", "/users/user/top-songs?term=forever", "key", http.StatusBadRequest},
+		{"Compare needs two users", "/compare", "key", http.StatusBadRequest},
+		{"Compare unknown user", "/compare?users=user,someone", "key", http.StatusNotFound},
+		{"Openapi needs no key", "/openapi.json", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := get(test.path, test.key)
+			if recorder.Code != test.status {
+				t.Errorf("Expected %d, got %d: %s", test.status, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("Listens are paged by played_at", func(t *testing.T) {
+		first := ListensPage{}
+		json.Unmarshal(get("/users/user/listens?limit=2", "key").Body.Bytes(), &first)
+		if len(first.Listens) != 2 || first.Next == "" {
+			t.Fatalf("Expected a full first page with a cursor, got %+v", first)
+		}
+
+		second := ListensPage{}
+		json.Unmarshal(get("/users/user/listens?limit=2&before="+first.Next, "key").Body.Bytes(), &second)
+		if len(second.Listens) != 1 || second.Next != "" || !second.Listens[0].PlayedAt.Equal(db.listens[2].PlayedAt.Time) {
+			t.Errorf("Expected the last listen and no cursor, got %+v", second)
+		}
+	})
+
+	t.Run("Listens played in the same second aren't skipped between pages", func(t *testing.T) {
+		seen, next := 0, ""
+		for page := 0; page < len(db.listens)+1; page++ {
+			listens := ListensPage{}
+			json.Unmarshal(get("/users/user/listens?limit=1&before="+next, "key").Body.Bytes(), &listens)
+			seen += len(listens.Listens)
+			if next = listens.Next; next == "" {
+				break
+			}
+		}
+		if seen != len(db.listens) {
+			t.Errorf("Expected all %d listens to be paged through, got %d", len(db.listens), seen)
+		}
+	})
+
+	if !db.released {
+		t.Error("Expected the read transaction to be released")
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	document := OpenAPI(routes())
+
+	paths := document["paths"].(Schema)
+	for _, route := range routes() {
+		if _, exists := paths[route.Path].(Schema)["get"]; !exists {
+			t.Errorf("Expected %s to be documented", route.Path)
+		}
+	}
+
+	schemas := document["components"].(Schema)["schemas"].(Schema)
+	for _, name := range []string{"User", "ListensPage", "ExportListen", "Song", "ErrorResponse"} {
+		if _, exists := schemas[name]; !exists {
+			t.Errorf("Expected a %s component schema", name)
+		}
+	}
+
+	user := schemas["User"].(Schema)["properties"].(Schema)
+	if _, exists := user["password"]; exists || len(user) != 2 {
+		t.Errorf("Expected only username and spotify_id on users, got %v", user)
+	}
+}
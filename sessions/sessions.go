@@ -0,0 +1,166 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	// SessionGap is the longest silence between one listen ending and the next starting within a session
+	SessionGap = 30 * time.Minute
+	// skipTolerance allows for the few seconds between tracks spotify spends buffering or crossfading before a
+	// listen that ended early is counted as skipped
+	skipTolerance = 10 * time.Second
+)
+
+type SessionsDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	FetchSessionsStaleFrom(userID string) (utils.NullTime, error)
+	FetchListeningSessionBefore(userID string, at utils.Time) (models.ListeningSession, error)
+	FetchSessionListensFrom(userID string, from utils.Time) ([]models.SessionListen, error)
+	DeleteListeningSessionsFrom(userID string, from utils.Time) error
+	Commit()
+	Rollback()
+}
+
+// Sessionizer groups a users listens into listening_sessions and estimates how long each listen played for
+type Sessionizer struct {
+	database SessionsDatabase
+}
+
+func NewSessionizer(database SessionsDatabase) Sessionizer {
+	return Sessionizer{database: database}
+}
+
+// Refresh recomputes sessions from the earliest listen added since the last run. The session that listen falls in
+// (or follows) is rebuilt too, since new listens can extend it or cut its last listen short
+func (s *Sessionizer) Refresh(userID string) error {
+	staleFrom, err := s.database.FetchSessionsStaleFrom(userID)
+	if err != nil {
+		s.database.Rollback()
+		return err
+	}
+
+	if !staleFrom.Valid {
+		logger.Log("Listening sessions are up to date", logger.Debug)
+		return nil
+	}
+
+	from := staleFrom.Time
+	previous, err := s.database.FetchListeningSessionBefore(userID, staleFrom.Time)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.database.Rollback()
+		return err
+	}
+	if err == nil {
+		from = previous.StartedAt
+	}
+
+	err = s.refreshFrom(userID, from)
+	if err != nil {
+		s.database.Rollback()
+		return err
+	}
+
+	s.database.Commit()
+	return nil
+}
+
+func (s *Sessionizer) refreshFrom(userID string, from utils.Time) error {
+	listens, err := s.database.FetchSessionListensFrom(userID, from)
+	if err != nil {
+		return err
+	}
+
+	err = s.database.DeleteListeningSessionsFrom(userID, from)
+	if err != nil {
+		return err
+	}
+
+	sessions, estimates := Sessionize(userID, listens)
+	logger.Log(fmt.Sprintf("Recomputed %d listening sessions from %d listens since %s", len(sessions), len(listens), from.Format(time.RFC3339)), logger.Info)
+
+	for _, rows := range []struct {
+		model  models.Model
+		values []interface{}
+	}{
+		{&models.ListeningSession{}, reflectAll(sessions)},
+		{&models.ListenEstimate{}, reflectAll(estimates)},
+	} {
+		if len(rows.values) == 0 {
+			continue
+		}
+
+		columns := len(utils.ReflectColumns(rows.model))
+		for _, chunk := range utils.ChunkSlice(rows.values, columns*1000) {
+			err = s.database.Create(rows.model, chunk)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func reflectAll[T any](rows []T) []interface{} {
+	values := []interface{}{}
+	for _, row := range rows {
+		values = append(values, utils.ReflectValues(row)...)
+	}
+	return values
+}
+
+// Sessionize walks listens in played_at order. A listen whose next listen started before it could have finished was
+// cut short, it played until the next one started and counts as skipped when that was well short of its duration
+func Sessionize(userID string, listens []models.SessionListen) ([]models.ListeningSession, []models.ListenEstimate) {
+	sessions := []models.ListeningSession{}
+	estimates := []models.ListenEstimate{}
+
+	var current *models.ListeningSession
+	for i, listen := range listens {
+		played, skipped := estimate(listen, listens[i+1:])
+		endedAt := listen.PlayedAt.Add(time.Duration(played) * time.Millisecond)
+
+		if current == nil || listen.PlayedAt.Sub(current.EndedAt.Time) > SessionGap {
+			sessions = append(sessions, models.NewListeningSession(userID, listen.PlayedAt))
+			current = &sessions[len(sessions)-1]
+		}
+
+		current.Listens++
+		current.EstimatedMs += played
+		if skipped {
+			current.Skips++
+		}
+		if endedAt.After(current.EndedAt.Time) {
+			current.EndedAt = utils.Time{Time: endedAt}
+		}
+
+		estimates = append(estimates, models.NewListenEstimate(listen.RecentListenID, current.ID, played, skipped))
+	}
+
+	return sessions, estimates
+}
+
+// estimate returns how long a listen played for, given the listens after it
+func estimate(listen models.SessionListen, next []models.SessionListen) (int64, bool) {
+	if listen.HasDetail {
+		return listen.MsPlayed, listen.Skipped
+	}
+
+	if len(next) == 0 {
+		return listen.DurationMs, false
+	}
+
+	untilNext := next[0].PlayedAt.Sub(listen.PlayedAt.Time).Milliseconds()
+	if untilNext >= listen.DurationMs {
+		return listen.DurationMs, false
+	}
+	return untilNext, untilNext < listen.DurationMs-skipTolerance.Milliseconds()
+}
@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+func TestSessionize(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	listen := func(id string, offset time.Duration) models.SessionListen {
+		return models.SessionListen{RecentListenID: id, PlayedAt: utils.Time{Time: start.Add(offset)}, DurationMs: 180000}
+	}
+
+	withDetail := listen("e", 3*time.Hour)
+	withDetail.HasDetail, withDetail.MsPlayed, withDetail.Skipped = true, 5000, true
+
+	listens := []models.SessionListen{
+		listen("a", 0),
+		// a was cut short after 30 seconds
+		listen("b", 30*time.Second),
+		// b finished, then a short pause
+		listen("c", 30*time.Second+3*time.Minute+5*time.Second),
+		// next one starts an hour after c ended
+		listen("d", 2*time.Hour),
+		withDetail,
+	}
+
+	sessions, estimates := Sessionize("user", listens)
+
+	if len(sessions) != 3 {
+		t.Fatalf("Expected 3 sessions, got %+v", sessions)
+	}
+	first := sessions[0]
+	if first.Listens != 3 || first.Skips != 1 || first.EstimatedMs != 30000+180000+180000 {
+		t.Errorf("Unexpected first session %+v", first)
+	}
+	if expectedEnd := start.Add(30*time.Second + 6*time.Minute + 5*time.Second); !first.EndedAt.Equal(expectedEnd) {
+		t.Errorf("Expected the first session to end at %s, got %s", expectedEnd, first.EndedAt)
+	}
+
+	expected := []struct {
+		played  int64
+		skipped bool
+		session string
+	}{
+		{30000, true, sessions[0].ID},
+		{180000, false, sessions[0].ID},
+		{180000, false, sessions[0].ID},
+		{180000, false, sessions[1].ID},
+		{5000, true, sessions[2].ID},
+	}
+	for i, e := range expected {
+		actual := estimates[i]
+		if actual.EstimatedMsPlayed != e.played || actual.Skipped != e.skipped || actual.ListeningSessionID != e.session {
+			t.Errorf("Expected listen %s to be %+v, got %+v", listens[i].RecentListenID, e, actual)
+		}
+	}
+}
+
+func TestEstimateWithinTolerance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	listen := models.SessionListen{PlayedAt: utils.Time{Time: start}, DurationMs: 180000}
+	next := models.SessionListen{PlayedAt: utils.Time{Time: start.Add(175 * time.Second)}}
+
+	played, skipped := estimate(listen, []models.SessionListen{next})
+	if played != 175000 || skipped {
+		t.Errorf("Expected a crossfaded end to be a partial play but not a skip, got %d %t", played, skipped)
+	}
+}
@@ -1,21 +1,124 @@
 package main
 
-import "spotify/models"
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"spotify/sessions"
+	"spotify/stats"
+	"strings"
+	"time"
 
-// func
-// OnNewEntity *func(model models.Model)
-// OnFinish    *func(stats SpotifyIngestStats)
+	"github.com/batzz-00/goutils/logger"
+)
 
-type StatsHandler struct {
-	entities map[string]int
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+func statsCommand(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	user := flags.String("u", "", "Username to compute stats for")
+	from := flags.String("from", "", "Start of the window (YYYY-MM-DD), defaults to 30 days ago")
+	to := flags.String("to", "", "End of the window, exclusive (YYYY-MM-DD), defaults to tomorrow")
+	limit := flags.Int("n", 10, "Number of top songs, albums and artists to list")
+	asJSON := flags.Bool("json", false, "Print the summary as json")
+	flags.Parse(args)
+
+	if *user == "" {
+		log.Fatalf("UserID must be specified!")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if *from == "" {
+		*from = today.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if *to == "" {
+		*to = today.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	fromTime, toTime := mustParseDateRange(*from, *to)
+	if *asJSON {
+		silenceLogger()
+	}
+
+	env := LoadEnv(*user)
+	database := mustConnectDatabase(env)
+
+	dbUser, err := database.FetchUserByName(*user)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %s", *user, err.Error())
+	}
+
+	dailyStats := stats.NewStats(&database)
+	err = dailyStats.Refresh(dbUser.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	summary, err := dailyStats.Summarize(dbUser.ID, fromTime.Time, toTime.Time, *limit)
+	if err != nil {
+		panic(err)
+	}
+	database.Rollback()
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(summary)
+		return
+	}
+
+	printSummary(*user, summary)
+}
+
+// refreshStats brings the users daily stats up to date after an ingest, a failure here doesn't undo the ingest
+func refreshStats(dailyStats stats.Stats, userID string) {
+	err := dailyStats.Refresh(userID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Failed to refresh daily stats, %s", err.Error()), logger.Error)
+	}
 }
 
-func NewStatsHandler() StatsHandler {
-	return StatsHandler{
-		entities: make(map[string]int),
+// refreshSessions regroups the users new listens into sessions after an ingest, ahead of the stats which use the
+// estimated play times
+func refreshSessions(sessionizer sessions.Sessionizer, userID string) {
+	err := sessionizer.Refresh(userID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Failed to refresh listening sessions, %s", err.Error()), logger.Error)
 	}
 }
 
-func (s *StatsHandler) OnNewEntity(model models.Model) {
-	s.entities[model.TableName()] += 1
+func printSummary(user string, summary stats.Summary) {
+	fmt.Printf("%s, %s to %s\n\n", user, summary.From.Format("2006-01-02"), summary.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Printf("Plays:          %d\n", summary.Plays)
+	fmt.Printf("Minutes:        %.0f\n", summary.Minutes)
+	fmt.Printf("New songs:      %d\n", summary.NewSongs)
+	fmt.Printf("New artists:    %d\n", summary.NewArtists)
+	fmt.Printf("Longest streak: %d days\n", summary.LongestStreak)
+	fmt.Printf("Current streak: %d days\n", summary.CurrentStreak)
+
+	for _, top := range []struct {
+		title string
+		items []stats.TopItem
+	}{
+		{"Top songs", summary.TopSongs},
+		{"Top albums", summary.TopAlbums},
+		{"Top artists", summary.TopArtists},
+	} {
+		fmt.Printf("\n%s\n", top.title)
+		for i, item := range top.items {
+			fmt.Printf("%3d. %s (%d plays, %.0f minutes)\n", i+1, item.Name, item.Plays, item.Minutes)
+		}
+	}
+
+	fmt.Printf("\nPlays by hour (utc)\n     ")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Printf("%4d", hour)
+	}
+	fmt.Println()
+	for weekday, hours := range summary.Heatmap {
+		cells := []string{}
+		for _, plays := range hours {
+			cells = append(cells, fmt.Sprintf("%4d", plays))
+		}
+		fmt.Printf("%s  %s\n", weekdays[weekday], strings.Join(cells, ""))
+	}
 }
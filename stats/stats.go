@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"spotify/models"
+	"spotify/utils"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const day = 24 * time.Hour
+
+type StatsDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	FetchStaleStatsDays(userID string) ([]utils.Time, error)
+	FetchStatsListensBetween(userID string, from utils.Time, to utils.Time) ([]models.StatsListen, error)
+	FetchFirstListensByUserID(userID string) ([]models.FirstListen, error)
+	DeleteUserStatsBetween(userID string, from utils.Time, to utils.Time) error
+	FetchUserDailyStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyStat, error)
+	FetchUserDailyHourStats(userID string, from utils.Time, to utils.Time) ([]models.UserDailyHourStat, error)
+	FetchTopItems(userID string, itemType string, from utils.Time, to utils.Time, limit int) ([]models.TopItemRow, error)
+	Commit()
+	Rollback()
+}
+
+// Stats keeps the user_daily_stats tables up to date and summarises windows of them
+type Stats struct {
+	database StatsDatabase
+}
+
+func NewStats(database StatsDatabase) Stats {
+	return Stats{database: database}
+}
+
+// Refresh recomputes only the days that have had listens added, or their play times re-estimated or imported, since
+// they were last computed, which after a normal ingest is just today (and maybe yesterday), but after a history import
+// can be years of days
+func (s *Stats) Refresh(userID string) error {
+	computedAt := utils.NewTime()
+	staleDays, err := s.database.FetchStaleStatsDays(userID)
+	if err != nil {
+		s.database.Rollback()
+		return err
+	}
+
+	if len(staleDays) == 0 {
+		logger.Log("Daily stats are up to date", logger.Debug)
+		return nil
+	}
+
+	logger.Log(fmt.Sprintf("Recomputing daily stats for %d days", len(staleDays)), logger.Info)
+	firstListens, err := s.database.FetchFirstListensByUserID(userID)
+	if err != nil {
+		s.database.Rollback()
+		return err
+	}
+
+	for _, run := range dayRuns(staleDays) {
+		err = s.refreshRun(userID, run[0], run[1], firstListens, computedAt)
+		if err != nil {
+			s.database.Rollback()
+			return err
+		}
+	}
+
+	s.database.Commit()
+	return nil
+}
+
+func (s *Stats) refreshRun(userID string, from time.Time, to time.Time, firstListens []models.FirstListen, computedAt utils.Time) error {
+	listens, err := s.database.FetchStatsListensBetween(userID, utils.Time{Time: from}, utils.Time{Time: to})
+	if err != nil {
+		return err
+	}
+
+	err = s.database.DeleteUserStatsBetween(userID, utils.Time{Time: from}, utils.Time{Time: to})
+	if err != nil {
+		return err
+	}
+
+	days, hours, items := rollup(userID, listens, firstListens, computedAt)
+	for _, rows := range []struct {
+		model  models.Model
+		values []interface{}
+	}{
+		{&models.UserDailyStat{}, reflectAll(days)},
+		{&models.UserDailyHourStat{}, reflectAll(hours)},
+		{&models.UserDailyItemStat{}, reflectAll(items)},
+	} {
+		if len(rows.values) == 0 {
+			continue
+		}
+
+		// keep well under postgres' limit of 65535 parameters per statement
+		columns := len(utils.ReflectColumns(rows.model))
+		for _, chunk := range utils.ChunkSlice(rows.values, columns*1000) {
+			err = s.database.Create(rows.model, chunk)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func reflectAll[T any](rows []T) []interface{} {
+	values := []interface{}{}
+	for _, row := range rows {
+		values = append(values, utils.ReflectValues(row)...)
+	}
+	return values
+}
+
+// dayRuns groups sorted days into [from, to) ranges of consecutive days
+func dayRuns(days []utils.Time) [][2]time.Time {
+	runs := [][2]time.Time{}
+	for _, d := range days {
+		start := d.UTC().Truncate(day)
+		if len(runs) > 0 && runs[len(runs)-1][1].Equal(start) {
+			runs[len(runs)-1][1] = start.Add(day)
+			continue
+		}
+		runs = append(runs, [2]time.Time{start, start.Add(day)})
+	}
+	return runs
+}
+
+// rollup turns listens into per day totals, per hour counts and per item totals. A listen counts as a discovery
+// when it is the first time the user ever played that song or artist
+func rollup(userID string, listens []models.StatsListen, firstListens []models.FirstListen, computedAt utils.Time) ([]models.UserDailyStat, []models.UserDailyHourStat, []models.UserDailyItemStat) {
+	firsts := map[string]time.Time{}
+	for _, first := range firstListens {
+		firsts[first.ItemType+first.ItemID] = first.PlayedAt.Time
+	}
+
+	sort.Slice(listens, func(i, j int) bool {
+		return listens[i].PlayedAt.Before(listens[j].PlayedAt.Time)
+	})
+
+	days := []models.UserDailyStat{}
+	dayIndex := map[time.Time]int{}
+	hourPlays := map[time.Time]map[int]int{}
+	items := []models.UserDailyItemStat{}
+	itemIndex := map[string]int{}
+
+	for _, listen := range listens {
+		playedAt := listen.PlayedAt.UTC()
+		listenDay := playedAt.Truncate(day)
+
+		i, exists := dayIndex[listenDay]
+		if !exists {
+			i = len(days)
+			dayIndex[listenDay] = i
+			days = append(days, models.NewUserDailyStat(userID, listenDay, computedAt))
+			hourPlays[listenDay] = map[int]int{}
+		}
+		days[i].Plays++
+		days[i].EstimatedMs += listen.EstimatedMs
+		hourPlays[listenDay][playedAt.Hour()]++
+
+		if first, exists := firsts[models.StatsItemSong+listen.SongID]; exists && first.Equal(listen.PlayedAt.Time) {
+			days[i].NewSongs++
+		}
+		if first, exists := firsts[models.StatsItemArtist+listen.ArtistID]; exists && first.Equal(listen.PlayedAt.Time) {
+			days[i].NewArtists++
+			// only the first play of the first song is the discovery
+			delete(firsts, models.StatsItemArtist+listen.ArtistID)
+		}
+
+		for itemType, itemID := range map[string]string{models.StatsItemSong: listen.SongID, models.StatsItemAlbum: listen.AlbumID, models.StatsItemArtist: listen.ArtistID} {
+			key := fmt.Sprintf("%s|%s|%s", listenDay, itemType, itemID)
+			j, exists := itemIndex[key]
+			if !exists {
+				j = len(items)
+				itemIndex[key] = j
+				items = append(items, models.NewUserDailyItemStat(userID, listenDay, itemType, itemID))
+			}
+			items[j].Plays++
+			items[j].EstimatedMs += listen.EstimatedMs
+		}
+	}
+
+	hours := []models.UserDailyHourStat{}
+	for _, dailyStat := range days {
+		for _, hour := range utils.MapOrderedKeys(hourPlays[dailyStat.Day.Time]) {
+			hours = append(hours, models.NewUserDailyHourStat(userID, dailyStat.Day.Time, hour, hourPlays[dailyStat.Day.Time][hour]))
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Day.Equal(items[j].Day.Time) {
+			return items[i].Day.Before(items[j].Day.Time)
+		}
+		if items[i].ItemType != items[j].ItemType {
+			return items[i].ItemType < items[j].ItemType
+		}
+		return items[i].ItemID < items[j].ItemID
+	})
+
+	return days, hours, items
+}
@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"spotify/models"
+	"spotify/utils"
+	"testing"
+	"time"
+)
+
+func listen(playedAt time.Time, songID string, artistID string) models.StatsListen {
+	return models.StatsListen{PlayedAt: utils.Time{Time: playedAt}, SongID: songID, AlbumID: "album-" + songID, ArtistID: artistID, EstimatedMs: 180000}
+}
+
+func TestRollup(t *testing.T) {
+	dayOne := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dayTwo := dayOne.Add(day)
+	listens := []models.StatsListen{
+		listen(dayTwo.Add(9*time.Hour), "a", "x"),
+		listen(dayOne.Add(23*time.Hour), "a", "x"),
+		listen(dayOne.Add(23*time.Hour+5*time.Minute), "b", "x"),
+		listen(dayTwo.Add(9*time.Hour+5*time.Minute), "c", "y"),
+	}
+	firstListens := []models.FirstListen{
+		{ItemType: models.StatsItemSong, ItemID: "a", PlayedAt: listens[1].PlayedAt},
+		{ItemType: models.StatsItemSong, ItemID: "b", PlayedAt: listens[2].PlayedAt},
+		{ItemType: models.StatsItemArtist, ItemID: "x", PlayedAt: listens[1].PlayedAt},
+		// c and y were first played before this window
+		{ItemType: models.StatsItemSong, ItemID: "c", PlayedAt: utils.Time{Time: dayOne.Add(-day)}},
+		{ItemType: models.StatsItemArtist, ItemID: "y", PlayedAt: utils.Time{Time: dayOne.Add(-day)}},
+	}
+
+	days, hours, items := rollup("user", listens, firstListens, utils.Time{Time: dayTwo})
+
+	if len(days) != 2 {
+		t.Fatalf("Expected 2 days, got %d", len(days))
+	}
+	expectedDays := []struct {
+		day        time.Time
+		plays      int
+		newSongs   int
+		newArtists int
+	}{
+		{dayOne, 2, 2, 1},
+		{dayTwo, 2, 0, 0},
+	}
+	for i, expected := range expectedDays {
+		actual := days[i]
+		if !actual.Day.Equal(expected.day) || actual.Plays != expected.plays || actual.NewSongs != expected.newSongs || actual.NewArtists != expected.newArtists || actual.EstimatedMs != int64(expected.plays)*180000 {
+			t.Errorf("Expected day %+v, got %+v", expected, actual)
+		}
+	}
+
+	if len(hours) != 2 || hours[0].Hour != 23 || hours[0].Plays != 2 || hours[1].Hour != 9 || hours[1].Plays != 2 {
+		t.Errorf("Expected 2 plays at 23:00 on day one and 2 at 09:00 on day two, got %+v", hours)
+	}
+
+	artistPlays := map[string]int{}
+	for _, item := range items {
+		if item.ItemType == models.StatsItemArtist {
+			artistPlays[item.Day.Format("2006-01-02")+item.ItemID] += item.Plays
+		}
+	}
+	if artistPlays["2024-01-01x"] != 2 || artistPlays["2024-01-02x"] != 1 || artistPlays["2024-01-02y"] != 1 {
+		t.Errorf("Unexpected artist plays %v", artistPlays)
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	played := func(offsets ...int) []time.Time {
+		days := []time.Time{}
+		for _, offset := range offsets {
+			days = append(days, start.Add(time.Duration(offset)*day))
+		}
+		return days
+	}
+
+	tests := []struct {
+		name            string
+		playedDays      []time.Time
+		lastDay         time.Time
+		expectedLongest int
+		expectedCurrent int
+	}{
+		{"No plays", played(), start, 0, 0},
+		{"Streak up to the last day", played(0, 1, 3, 4, 5), start.Add(5 * day), 3, 3},
+		{"Streak broken before the last day", played(0, 1, 2, 4), start.Add(5 * day), 3, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			longest, current := streaks(test.playedDays, test.lastDay)
+			if longest != test.expectedLongest || current != test.expectedCurrent {
+				t.Errorf("Expected streaks %d/%d, got %d/%d", test.expectedLongest, test.expectedCurrent, longest, current)
+			}
+		})
+	}
+}
+
+func TestDayRuns(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := []utils.Time{{Time: start}, {Time: start.Add(day)}, {Time: start.Add(3 * day)}}
+
+	runs := dayRuns(days)
+	if len(runs) != 2 || !runs[0][1].Equal(start.Add(2*day)) || !runs[1][0].Equal(start.Add(3*day)) {
+		t.Errorf("Expected runs of days 1-2 and 4, got %v", runs)
+	}
+}
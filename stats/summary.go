@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"spotify/models"
+	"spotify/utils"
+	"time"
+)
+
+type TopItem struct {
+	Name      string  `json:"name"`
+	SpotifyID string  `json:"spotify_id"`
+	Plays     int     `json:"plays"`
+	Minutes   float64 `json:"minutes"`
+}
+
+// Summary is a users listening over a window, worked out from the daily stats tables
+type Summary struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Plays   int       `json:"plays"`
+	Minutes float64   `json:"minutes"`
+	// HourOfDay and DayOfWeek count plays by utc hour and by weekday (sunday first)
+	HourOfDay     [24]int    `json:"hour_of_day"`
+	DayOfWeek     [7]int     `json:"day_of_week"`
+	Heatmap       [7][24]int `json:"heatmap"`
+	LongestStreak int        `json:"longest_streak"`
+	// CurrentStreak is how many days in a row up to the end of the window had plays
+	CurrentStreak int       `json:"current_streak"`
+	NewSongs      int       `json:"new_songs"`
+	NewArtists    int       `json:"new_artists"`
+	TopSongs      []TopItem `json:"top_songs"`
+	TopAlbums     []TopItem `json:"top_albums"`
+	TopArtists    []TopItem `json:"top_artists"`
+}
+
+// Summarize reads back the users daily stats for [from, to), run Refresh first for them to include the latest listens
+func (s *Stats) Summarize(userID string, from time.Time, to time.Time, limit int) (Summary, error) {
+	summary := Summary{From: from, To: to}
+	dbFrom, dbTo := utils.Time{Time: from}, utils.Time{Time: to}
+
+	days, err := s.database.FetchUserDailyStats(userID, dbFrom, dbTo)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	playedDays := []time.Time{}
+	var estimatedMs int64
+	for _, dailyStat := range days {
+		summary.Plays += dailyStat.Plays
+		summary.NewSongs += dailyStat.NewSongs
+		summary.NewArtists += dailyStat.NewArtists
+		estimatedMs += dailyStat.EstimatedMs
+		if dailyStat.Plays > 0 {
+			playedDays = append(playedDays, dailyStat.Day.Time)
+		}
+	}
+	summary.Minutes = msToMinutes(estimatedMs)
+	summary.LongestStreak, summary.CurrentStreak = streaks(playedDays, to.Add(-day))
+
+	hours, err := s.database.FetchUserDailyHourStats(userID, dbFrom, dbTo)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	for _, hour := range hours {
+		weekday := hour.Day.UTC().Weekday()
+		summary.HourOfDay[hour.Hour] += hour.Plays
+		summary.DayOfWeek[weekday] += hour.Plays
+		summary.Heatmap[weekday][hour.Hour] += hour.Plays
+	}
+
+	for itemType, top := range map[string]*[]TopItem{
+		models.StatsItemSong:   &summary.TopSongs,
+		models.StatsItemAlbum:  &summary.TopAlbums,
+		models.StatsItemArtist: &summary.TopArtists,
+	} {
+		items, err := s.database.FetchTopItems(userID, itemType, dbFrom, dbTo, limit)
+		if err != nil {
+			return Summary{}, err
+		}
+
+		*top = []TopItem{}
+		for _, item := range items {
+			*top = append(*top, TopItem{Name: item.Name, SpotifyID: item.SpotifyID, Plays: item.Plays, Minutes: msToMinutes(item.EstimatedMs)})
+		}
+	}
+
+	return summary, nil
+}
+
+func msToMinutes(ms int64) float64 {
+	return float64(ms/1000) / 60
+}
+
+// streaks returns the longest run of consecutive days in playedDays, and the run ending on lastDay (0 if lastDay had no plays)
+func streaks(playedDays []time.Time, lastDay time.Time) (int, int) {
+	longest, current := 0, 0
+	var previous time.Time
+	for _, playedDay := range playedDays {
+		playedDay = playedDay.UTC().Truncate(day)
+		if !previous.IsZero() && playedDay.Sub(previous) == day {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+		previous = playedDay
+	}
+
+	if previous.IsZero() || !previous.Equal(lastDay.UTC().Truncate(day)) {
+		current = 0
+	}
+
+	return longest, current
+}
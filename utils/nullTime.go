@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// NullTime is a Time that is written as NULL until it has been set
+type NullTime struct {
+	Time
+	Valid bool
+}
+
+func NewNullTime(t time.Time) NullTime {
+	return NullTime{
+		Time:  Time{t},
+		Valid: true,
+	}
+}
+
+func (t NullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time.Value()
+}
+
+func (t *NullTime) Scan(value interface{}) error {
+	if value == nil {
+		*t = NullTime{}
+		return nil
+	}
+
+	t.Valid = true
+	return t.Time.Scan(value)
+}
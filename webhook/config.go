@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventRunFinished      = "run_finished"
+	EventRunFailed        = "run_failed"
+	EventTopArtistChanged = "top_artist_changed"
+	EventArtistDiscovered = "artist_discovered"
+	EventPlayMilestone    = "play_milestone"
+)
+
+var events = map[string]bool{
+	EventRunFinished:      true,
+	EventRunFailed:        true,
+	EventTopArtistChanged: true,
+	EventArtistDiscovered: true,
+	EventPlayMilestone:    true,
+}
+
+// Webhook is one url to post events to, Secret signs each payload so the receiver can check it came from us
+type Webhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	// PlayMilestone fires play_milestone each time a song reaches another multiple of this many plays, eg. 100
+	PlayMilestone int `json:"play_milestone"`
+}
+
+func (w Webhook) Subscribed(event string) bool {
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfig reads the webhooks from a json file holding a list of them
+func LoadConfig(path string) ([]Webhook, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []Webhook{}
+	err = json.Unmarshal(bytes, &webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook without a url")
+		}
+		for _, event := range webhook.Events {
+			if !events[event] {
+				return nil, fmt.Errorf("webhook %s subscribes to unknown event %s", webhook.URL, event)
+			}
+		}
+		if webhook.Subscribed(EventPlayMilestone) && webhook.PlayMilestone < 1 {
+			return nil, fmt.Errorf("webhook %s subscribes to %s without a play_milestone", webhook.URL, EventPlayMilestone)
+		}
+	}
+	return webhooks, nil
+}
@@ -0,0 +1,296 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"spotify/ingest"
+	"spotify/models"
+	"spotify/utils"
+	"sync"
+	"time"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+const (
+	EventHeader     = "X-Spotify-Event"
+	SignatureHeader = "X-Spotify-Signature"
+)
+
+type WebhookDatabase interface {
+	Create(model models.Model, values []interface{}) error
+	FetchSongPlays(userID string, songIDs []interface{}) ([]models.SongPlays, error)
+	Commit()
+}
+
+// Payload is the json body posted to a webhook
+type Payload struct {
+	Event  string      `json:"event"`
+	RunID  string      `json:"run_id"`
+	UserID string      `json:"user_id"`
+	SentAt time.Time   `json:"sent_at"`
+	Data   interface{} `json:"data"`
+}
+
+type RunData struct {
+	Stats ingest.SpotifyIngestStats `json:"stats"`
+	Error string                    `json:"error,omitempty"`
+}
+
+type ArtistData struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SpotifyID string `json:"spotify_id"`
+}
+
+type TopArtistData struct {
+	ArtistData
+	Term         string `json:"term"`
+	PreviousRank int    `json:"previous_rank,omitempty"`
+}
+
+type MilestoneData struct {
+	SongID    string `json:"song_id"`
+	Name      string `json:"name"`
+	SpotifyID string `json:"spotify_id"`
+	Plays     int    `json:"plays"`
+	Milestone int    `json:"milestone"`
+}
+
+// Dispatcher collects what an ingest did from its events, then posts it to every webhook subscribed once the run is
+// over. Each delivery is logged to webhook_deliveries whether or not it got through
+type Dispatcher struct {
+	database  WebhookDatabase
+	webhooks  []Webhook
+	Client    *http.Client
+	Retries   int
+	RetryWait time.Duration
+
+	lock       sync.Mutex
+	run        *ingest.RunFinished
+	artists    map[string]models.Artist
+	discovered []string
+	topArtists []models.TopListChange
+	listens    map[string]int
+}
+
+func NewDispatcher(database WebhookDatabase, webhooks []Webhook) *Dispatcher {
+	return &Dispatcher{
+		database:  database,
+		webhooks:  webhooks,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		Retries:   4,
+		RetryWait: time.Second,
+		artists:   map[string]models.Artist{},
+		listens:   map[string]int{},
+	}
+}
+
+// HandleEvent collects the events webhooks are interested in, subscribe it to the ingests event bus
+func (d *Dispatcher) HandleEvent(event ingest.Event) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	switch e := event.(type) {
+	case ingest.EntityCreated:
+		switch model := e.Model.(type) {
+		case *models.Artist:
+			_, seen := d.artists[model.ID]
+			d.artists[model.ID] = *model
+			if e.New && !seen {
+				d.discovered = append(d.discovered, model.ID)
+			}
+		case *models.RecentListen:
+			if e.New {
+				d.listens[model.SongID]++
+			}
+		case *models.TopListChange:
+			// changes are only recorded for movement against the terms previous snapshot, so a change to rank one is
+			// always a new top artist. There's no snapshot to move against on a users first run, so it sends none, and an
+			// artist new to the list has no previous rank
+			if model.ListType == models.TopListArtist && model.Rank.Valid && model.Rank.Int32 == 1 {
+				d.topArtists = append(d.topArtists, *model)
+			}
+		}
+	case ingest.RunFinished:
+		d.run = &e
+	}
+}
+
+// Dispatch posts everything collected to the subscribed webhooks, then writes the delivery log. Call it once the
+// run has been committed, or rolled back when it failed
+func (d *Dispatcher) Dispatch() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.run == nil {
+		logger.Log("No finished run to dispatch webhooks for", logger.Debug)
+		return nil
+	}
+
+	plays, err := d.songPlays()
+	if err != nil {
+		return err
+	}
+
+	deliveryValues := []interface{}{}
+	for _, webhook := range d.webhooks {
+		for _, payload := range d.payloads(webhook, plays) {
+			delivery := d.deliver(webhook, payload)
+			deliveryValues = append(deliveryValues, utils.ReflectValues(delivery)...)
+		}
+	}
+
+	if len(deliveryValues) == 0 {
+		return nil
+	}
+
+	err = d.database.Create(&models.WebhookDelivery{}, deliveryValues)
+	if err != nil {
+		return err
+	}
+	d.database.Commit()
+	return nil
+}
+
+// songPlays looks up the all time plays of each song listened to during the run, only when a webhook wants
+// milestones and the run committed its listens
+func (d *Dispatcher) songPlays() ([]models.SongPlays, error) {
+	if d.run.Err != nil || len(d.listens) == 0 {
+		return nil, nil
+	}
+
+	wanted := false
+	for _, webhook := range d.webhooks {
+		wanted = wanted || webhook.Subscribed(EventPlayMilestone)
+	}
+	if !wanted {
+		return nil, nil
+	}
+
+	songIDs := []interface{}{}
+	for _, songID := range utils.MapOrderedKeys(d.listens) {
+		songIDs = append(songIDs, songID)
+	}
+	return d.database.FetchSongPlays(d.run.UserID, songIDs)
+}
+
+func (d *Dispatcher) payloads(webhook Webhook, plays []models.SongPlays) []Payload {
+	payloads := []Payload{}
+	add := func(event string, data interface{}) {
+		if webhook.Subscribed(event) {
+			payloads = append(payloads, Payload{Event: event, RunID: d.run.RunID, UserID: d.run.UserID, SentAt: utils.NewTime().Time, Data: data})
+		}
+	}
+
+	if d.run.Err != nil {
+		add(EventRunFailed, RunData{Stats: d.run.Stats, Error: d.run.Err.Error()})
+		return payloads
+	}
+	add(EventRunFinished, RunData{Stats: d.run.Stats})
+
+	// entities are published as they're built, before they're inserted, so nothing is sent for a step that was rolled
+	// back under the best-effort policy
+	failed := map[string]bool{}
+	for _, step := range d.run.Stats.FailedSteps {
+		failed[step.Name] = true
+	}
+
+	if !failed["artists"] {
+		for _, id := range d.discovered {
+			add(EventArtistDiscovered, artistData(d.artists[id]))
+		}
+	}
+
+	if !failed["top_artists"] {
+		for _, change := range d.topArtists {
+			data := TopArtistData{ArtistData: artistData(d.artists[change.ItemID]), Term: change.TimePeriod, PreviousRank: int(change.PreviousRank.Int32)}
+			data.ID = change.ItemID
+			add(EventTopArtistChanged, data)
+		}
+	}
+
+	if webhook.PlayMilestone > 0 && !failed["recent_listens"] {
+		for _, song := range plays {
+			// a milestone was reached if the run's listens took the song past another multiple
+			milestone := song.Plays / webhook.PlayMilestone * webhook.PlayMilestone
+			if milestone > 0 && song.Plays-d.listens[song.SongID] < milestone {
+				add(EventPlayMilestone, MilestoneData{SongID: song.SongID, Name: song.Name, SpotifyID: song.SpotifyID, Plays: song.Plays, Milestone: milestone})
+			}
+		}
+	}
+
+	return payloads
+}
+
+func artistData(artist models.Artist) ArtistData {
+	return ArtistData{ID: artist.ID, Name: artist.Name, SpotifyID: artist.SpotifyID}
+}
+
+// deliver posts the payload, retrying connection failures, rate limits and server errors with a doubling wait
+func (d *Dispatcher) deliver(webhook Webhook, payload Payload) models.WebhookDelivery {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery := models.NewWebhookDelivery(payload.RunID, webhook.URL, payload.Event, "")
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	delivery := models.NewWebhookDelivery(payload.RunID, webhook.URL, payload.Event, string(body))
+	for attempt := 0; ; attempt++ {
+		delivery.Attempts = attempt + 1
+		status, retryable, err := d.post(webhook, payload.Event, body)
+		if status != 0 {
+			delivery.StatusCode = sql.NullInt32{Int32: int32(status), Valid: true}
+		}
+		if err == nil {
+			delivery.Error = ""
+			delivery.DeliveredAt = utils.NewNullTime(utils.NewTime().Time)
+			return delivery
+		}
+
+		delivery.Error = err.Error()
+		if !retryable || attempt >= d.Retries {
+			logger.Log(fmt.Sprintf("Failed to deliver %s to webhook %s after %d attempts, %s", payload.Event, webhook.URL, delivery.Attempts, err.Error()), logger.Warning)
+			return delivery
+		}
+		time.Sleep(d.RetryWait << attempt)
+	}
+}
+
+func (d *Dispatcher) post(webhook Webhook, event string, body []byte) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return resp.StatusCode, retryable, fmt.Errorf("POST returned %s", resp.Status)
+	}
+	return resp.StatusCode, false, nil
+}
+
+// Sign is the signature header sent with a body, the hex hmac-sha256 of it keyed by the webhooks secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"spotify/ingest"
+	"spotify/models"
+	"spotify/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockWebhookDatabase struct {
+	plays      []models.SongPlays
+	deliveries []interface{}
+	commits    int
+}
+
+func (db *mockWebhookDatabase) Create(model models.Model, values []interface{}) error {
+	db.deliveries = values
+	return nil
+}
+
+func (db *mockWebhookDatabase) FetchSongPlays(userID string, songIDs []interface{}) ([]models.SongPlays, error) {
+	return db.plays, nil
+}
+
+func (db *mockWebhookDatabase) Commit() {
+	db.commits++
+}
+
+type received struct {
+	event   string
+	payload Payload
+}
+
+func TestDispatch(t *testing.T) {
+	lock := sync.Mutex{}
+	attempts := 0
+	deliveries := []received{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		// the first attempt at anything fails, to be retried
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		payload := Payload{}
+		json.Unmarshal(body, &payload)
+		deliveries = append(deliveries, received{event: r.Header.Get(EventHeader), payload: payload})
+	}))
+	defer server.Close()
+
+	db := mockWebhookDatabase{plays: []models.SongPlays{
+		{SongID: "song-reached", Name: "Reached", Plays: 101},
+		{SongID: "song-not-reached", Name: "Not reached", Plays: 99},
+	}}
+	dispatcher := NewDispatcher(&db, []Webhook{
+		{URL: server.URL, Secret: "secret", Events: []string{EventRunFinished, EventArtistDiscovered, EventTopArtistChanged, EventPlayMilestone}, PlayMilestone: 100},
+		{URL: server.URL + "/failures", Secret: "secret", Events: []string{EventRunFailed}},
+	})
+	dispatcher.RetryWait = time.Millisecond
+
+	bus := ingest.NewEventBus()
+	bus.Subscribe(dispatcher.HandleEvent)
+
+	existing := models.NewArtist("Existing", "existing-spotify-id", false)
+	discovered := models.NewArtist("Discovered", "discovered-spotify-id", true)
+	bus.Publish(ingest.EntityCreated{Model: &existing, New: false})
+	bus.Publish(ingest.EntityCreated{Model: &discovered, New: true})
+
+	change := models.NewTopListChange("user", models.TopListArtist, "short", existing.ID, "snapshot", 2, 1, models.TopListMovementUp)
+	bus.Publish(ingest.EntityCreated{Model: &change, New: true})
+
+	for _, songID := range []string{"song-reached", "song-reached", "song-not-reached"} {
+		listen := models.NewRecentListen(songID, "user", time.Now())
+		bus.Publish(ingest.EntityCreated{Model: &listen, New: true})
+	}
+	bus.Publish(ingest.RunFinished{RunID: "run", UserID: "user", Stats: ingest.NewSpotifyIngestStats()})
+
+	err := dispatcher.Dispatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{EventRunFinished, EventArtistDiscovered, EventTopArtistChanged, EventPlayMilestone}
+	if len(deliveries) != len(expected) {
+		t.Fatalf("Expected %v to be delivered, got %+v", expected, deliveries)
+	}
+	for i, event := range expected {
+		if deliveries[i].event != event || deliveries[i].payload.Event != event || deliveries[i].payload.RunID != "run" {
+			t.Errorf("Expected delivery %d to be %s, got %+v", i, event, deliveries[i])
+		}
+	}
+
+	artist := deliveries[1].payload.Data.(map[string]interface{})
+	if artist["name"] != "Discovered" {
+		t.Errorf("Expected only the new artist to be discovered, got %v", artist)
+	}
+
+	top := deliveries[2].payload.Data.(map[string]interface{})
+	if top["name"] != "Existing" || top["previous_rank"] != float64(2) {
+		t.Errorf("Expected the existing artist to be the new top artist, got %v", top)
+	}
+
+	milestone := deliveries[3].payload.Data.(map[string]interface{})
+	if milestone["song_id"] != "song-reached" || milestone["milestone"] != float64(100) {
+		t.Errorf("Expected the 100th play milestone of song-reached, got %v", milestone)
+	}
+
+	columns := utils.ReflectColumns(&models.WebhookDelivery{})
+	if len(db.deliveries) != len(columns)*len(expected) || db.commits != 1 {
+		t.Fatalf("Expected %d deliveries logged and committed, got %d values and %d commits", len(expected), len(db.deliveries), db.commits)
+	}
+	for i, column := range columns {
+		if column == "attempts" && db.deliveries[i] != 2 {
+			t.Errorf("Expected the first delivery to take 2 attempts, got %v", db.deliveries[i])
+		}
+	}
+}
+
+func TestDispatch_failedRun(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	db := mockWebhookDatabase{}
+	dispatcher := NewDispatcher(&db, []Webhook{
+		{URL: server.URL + "/finished", Events: []string{EventRunFinished, EventPlayMilestone}, PlayMilestone: 1},
+		{URL: server.URL + "/failed", Events: []string{EventRunFailed}},
+	})
+	dispatcher.RetryWait = time.Millisecond
+
+	listen := models.NewRecentListen("song", "user", time.Now())
+	dispatcher.HandleEvent(ingest.EntityCreated{Model: &listen, New: true})
+	dispatcher.HandleEvent(ingest.RunFinished{RunID: "run", UserID: "user", Err: errors.New("ingest failed")})
+
+	err := dispatcher.Dispatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// client errors aren't retried
+	if len(paths) != 1 || paths[0] != "/failed" {
+		t.Errorf("Expected a single attempt at the failed run webhook, got %v", paths)
+	}
+
+	columns := utils.ReflectColumns(&models.WebhookDelivery{})
+	for i, column := range columns {
+		if column == "delivered_at" && db.deliveries[i].(utils.NullTime).Valid {
+			t.Errorf("Expected the rejected delivery not to be marked delivered, got %v", db.deliveries[i])
+		}
+	}
+}
+
+func TestDispatch_partialRun(t *testing.T) {
+	events := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events = append(events, r.Header.Get(EventHeader))
+	}))
+	defer server.Close()
+
+	db := mockWebhookDatabase{plays: []models.SongPlays{{SongID: "song", Plays: 1}}}
+	dispatcher := NewDispatcher(&db, []Webhook{
+		{URL: server.URL, Events: []string{EventRunFinished, EventArtistDiscovered, EventTopArtistChanged, EventPlayMilestone}, PlayMilestone: 1},
+	})
+
+	discovered := models.NewArtist("Discovered", "discovered-spotify-id", true)
+	dispatcher.HandleEvent(ingest.EntityCreated{Model: &discovered, New: true})
+	change := models.NewTopListChange("user", models.TopListArtist, "short", discovered.ID, "snapshot", 0, 1, models.TopListMovementNew)
+	dispatcher.HandleEvent(ingest.EntityCreated{Model: &change, New: true})
+	listen := models.NewRecentListen("song", "user", time.Now())
+	dispatcher.HandleEvent(ingest.EntityCreated{Model: &listen, New: true})
+
+	stats := ingest.NewSpotifyIngestStats()
+	stats.FailedSteps = []ingest.FailedStep{{Name: "artists"}, {Name: "top_artists"}, {Name: "recent_listens"}}
+	dispatcher.HandleEvent(ingest.RunFinished{RunID: "run", UserID: "user", Stats: stats})
+
+	err := dispatcher.Dispatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0] != EventRunFinished {
+		t.Errorf("Expected nothing from the rolled back steps to be sent, got %v", events)
+	}
+}
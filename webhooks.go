@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"spotify/database"
+	"spotify/webhook"
+
+	"github.com/batzz-00/goutils/logger"
+)
+
+// mustOpenDispatcher returns a webhook dispatcher when webhooks are configured, or nil when they aren't
+func mustOpenDispatcher(db *database.Database, env SpotifyIngestEnv) *webhook.Dispatcher {
+	if env.WebhookConfig == "" {
+		return nil
+	}
+
+	webhooks, err := webhook.LoadConfig(env.WebhookConfig)
+	if err != nil {
+		logger.Log("Failed to load webhook config", logger.Error)
+		panic(err)
+	}
+	return webhook.NewDispatcher(db, webhooks)
+}
+
+// dispatchWebhooks posts the runs events to the configured webhooks, a failure here doesn't undo the ingest
+func dispatchWebhooks(dispatcher *webhook.Dispatcher) {
+	if dispatcher == nil {
+		return
+	}
+
+	logger.Log("Dispatching webhooks", logger.Info)
+	err := dispatcher.Dispatch()
+	if err != nil {
+		logger.Log(fmt.Sprintf("Failed to dispatch webhooks, %s", err.Error()), logger.Error)
+	}
+}